@@ -16,6 +16,20 @@ limitations under the License.
 
 package v1beta1
 
+const (
+	// PinnedRevisionCondition is the condition type set when the
+	// Kustomization is pinned to a specific source revision.
+	PinnedRevisionCondition string = "PinnedRevision"
+
+	// ReconcileBudgetExceededCondition is the condition type set when the
+	// last reconciliation took longer than the reconcile budget.
+	ReconcileBudgetExceededCondition string = "ReconcileBudgetExceeded"
+
+	// DegradedCondition is the condition type set when the last reconciliation
+	// failed while the Ready condition is kept within the failure grace.
+	DegradedCondition string = "Degraded"
+)
+
 const (
 	// PruneFailedReason represents the fact that the
 	// pruning of the Kustomization failed.
@@ -36,4 +50,52 @@ const (
 	// ValidationFailedReason represents the fact that the
 	// validation of the Kustomization manifests has failed.
 	ValidationFailedReason string = "ValidationFailed"
+
+	// PreconditionNotMetReason represents the fact that
+	// one of the preconditions of the Kustomization is not met.
+	PreconditionNotMetReason string = "PreconditionNotMet"
+
+	// MissingRequiredMetadataReason represents the fact that
+	// the Kustomization lacks labels or annotations required by the controller.
+	MissingRequiredMetadataReason string = "MissingRequiredMetadata"
+
+	// RevisionPinnedReason represents the fact that the Kustomization
+	// is reconciled from the revision specified in SourceRevision.
+	RevisionPinnedReason string = "RevisionPinned"
+
+	// PinnedRevisionNotFoundReason represents the fact that the source
+	// artifact is not the artifact of the revision specified in SourceRevision.
+	PinnedRevisionNotFoundReason string = "PinnedRevisionNotFound"
+
+	// DriftDetectedReason represents the fact that objects on the cluster
+	// differ from the manifests of a ReportOnly Kustomization.
+	DriftDetectedReason string = "DriftDetected"
+
+	// InSyncReason represents the fact that the objects on the cluster
+	// match the manifests of a ReportOnly Kustomization.
+	InSyncReason string = "InSync"
+
+	// BudgetExceededReason represents the fact that the
+	// reconciliation took longer than the reconcile budget.
+	BudgetExceededReason string = "BudgetExceeded"
+
+	// OwnershipConflictReason represents the fact that objects of the
+	// Kustomization are managed by another Kustomization.
+	OwnershipConflictReason string = "OwnershipConflict"
+
+	// EmptyRenderDetectedReason represents the fact that the
+	// build of the Kustomization produced no objects.
+	EmptyRenderDetectedReason string = "EmptyRenderDetected"
+
+	// CRDVersionConflictReason represents the fact that a CRD of the
+	// Kustomization would stop serving a version stored on the cluster.
+	CRDVersionConflictReason string = "CRDVersionConflict"
+
+	// ClusterUnreachableReason represents the fact that the API server
+	// of the remote cluster targeted by the KubeConfig is unreachable.
+	ClusterUnreachableReason string = "ClusterUnreachable"
+
+	// QuotaExceededReason represents the fact that the build of the
+	// Kustomization exceeds the object quotas of its namespace.
+	QuotaExceededReason string = "QuotaExceeded"
 )
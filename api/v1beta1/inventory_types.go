@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// ResourceInventory contains a list of Kubernetes resource object references
+// that have been applied by a Kustomization.
+type ResourceInventory struct {
+	// Entries of Kubernetes resource object references.
+	// +required
+	Entries []ResourceRef `json:"entries"`
+}
+
+// ResourceRef contains the information necessary to locate
+// a resource within a cluster.
+type ResourceRef struct {
+	// ID is the string representation of the Kubernetes resource object's metadata,
+	// in the format '<namespace>_<name>_<group>_<kind>'.
+	// +required
+	ID string `json:"id"`
+
+	// Version is the API version of the Kubernetes resource object's kind.
+	// +required
+	Version string `json:"v"`
+}
+
+// ResourceOrigin contains the source file a Kubernetes object is declared in.
+type ResourceOrigin struct {
+	// ID is the object reference in the ResourceRef ID format.
+	// +required
+	ID string `json:"id"`
+
+	// Path of the file relative to the source artifact root.
+	// +required
+	Path string `json:"path"`
+}
+
+// NewInventory returns the inventory of the Kubernetes objects in the
+// given multi-doc YAML, the IDs match the cli-utils ObjMetadata encoding.
+func NewInventory(manifests []byte) (*ResourceInventory, error) {
+	inventory := ResourceInventory{
+		Entries: []ResourceRef{},
+	}
+
+	reader := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifests), 2048)
+	for {
+		var obj unstructured.Unstructured
+		err := reader.Decode(&obj)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if obj.IsList() {
+			err := obj.EachListItem(func(item runtime.Object) error {
+				inventory.addEntry(item.(*unstructured.Unstructured))
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		} else if obj.Object != nil {
+			inventory.addEntry(&obj)
+		}
+	}
+
+	return &inventory, nil
+}
+
+func (i *ResourceInventory) addEntry(item *unstructured.Unstructured) {
+	gvk := item.GroupVersionKind()
+	i.Entries = append(i.Entries, ResourceRef{
+		ID: fmt.Sprintf("%s_%s_%s_%s",
+			item.GetNamespace(),
+			strings.ReplaceAll(item.GetName(), ":", "__"),
+			gvk.Group,
+			gvk.Kind),
+		Version: gvk.Version,
+	})
+}
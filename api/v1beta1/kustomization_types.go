@@ -40,13 +40,19 @@ type KustomizationSpec struct {
 	// +optional
 	DependsOn []dependency.CrossNamespaceDependencyReference `json:"dependsOn,omitempty"`
 
+	// DependsOnHelmReleases contains references to HelmReleases, reconciled by
+	// helm-controller, that must be ready before this Kustomization can be reconciled.
+	// +optional
+	DependsOnHelmReleases []dependency.CrossNamespaceDependencyReference `json:"dependsOnHelmReleases,omitempty"`
+
 	// Decrypt Kubernetes secrets before applying them on the cluster.
 	// +optional
 	Decryption *Decryption `json:"decryption,omitempty"`
 
 	// The interval at which to reconcile the Kustomization.
-	// +required
-	Interval metav1.Duration `json:"interval"`
+	// When not specified, the controller uses the --default-interval flag value.
+	// +optional
+	Interval metav1.Duration `json:"interval,omitempty"`
 
 	// The interval at which to retry a previously failed reconciliation.
 	// When not specified, the controller uses the KustomizationSpec.Interval
@@ -65,18 +71,248 @@ type KustomizationSpec struct {
 	// +optional
 	Path string `json:"path,omitempty"`
 
+	// SourceStripComponents is the number of leading path components removed
+	// from the artifact files when extracting it, as in 'tar --strip-components'.
+	// The Path is relative to the stripped artifact root.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	SourceStripComponents int `json:"sourceStripComponents,omitempty"`
+
+	// IncludeHiddenFiles includes the files and directories whose name starts
+	// with a dot, such as '.github', when generating the kustomization.yaml.
+	// Defaults to false.
+	// +optional
+	IncludeHiddenFiles bool `json:"includeHiddenFiles,omitempty"`
+
 	// Prune enables garbage collection.
 	// +required
 	Prune bool `json:"prune"`
 
+	// A list of objects that are never deleted by the garbage collector,
+	// regardless of their labels. The namespace must be left empty for
+	// cluster scoped objects.
+	// +optional
+	PruneExclude []meta.NamespacedObjectKindReference `json:"pruneExclude,omitempty"`
+
+	// AdoptSelector selects live objects that are labeled for garbage collection
+	// and added to the inventory at the first reconciliation, without being applied.
+	// +optional
+	AdoptSelector *AdoptSelector `json:"adoptSelector,omitempty"`
+
+	// AllowCRDPrune allows the garbage collector to delete CustomResourceDefinitions,
+	// which removes all the custom resources of that kind from the cluster.
+	// When not enabled, CRDs are skipped during garbage collection. Defaults to false.
+	// +optional
+	AllowCRDPrune *bool `json:"allowCRDPrune,omitempty"`
+
+	// AllowCRDVersionRemoval allows applying CustomResourceDefinitions that
+	// no longer serve a version stored on the cluster. When not enabled, the
+	// reconciliation fails without applying any object. Defaults to false.
+	// +optional
+	AllowCRDVersionRemoval *bool `json:"allowCRDVersionRemoval,omitempty"`
+
+	// AllowEmptyRender allows the build to produce no objects. When not enabled,
+	// an empty build output fails the reconciliation without garbage collecting
+	// the objects previously applied. Defaults to false.
+	// +optional
+	AllowEmptyRender *bool `json:"allowEmptyRender,omitempty"`
+
+	// PruneLimit is the maximum number of objects deleted by the garbage
+	// collector in a reconciliation. Defaults to no limit.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PruneLimit int `json:"pruneLimit,omitempty"`
+
+	// PruneLimitPolicy tells the garbage collector what to do when there are
+	// more objects to delete than the PruneLimit. 'Refuse' deletes no objects
+	// and fails the reconciliation, 'Partial' deletes PruneLimit objects at
+	// each reconciliation until all are deleted. Defaults to 'Refuse'.
+	// +kubebuilder:validation:Enum=Refuse;Partial
+	// +optional
+	PruneLimitPolicy string `json:"pruneLimitPolicy,omitempty"`
+
+	// PruneAfterStable defers the garbage collection of the objects removed
+	// from the source until the new revision has been applied and healthy
+	// for this duration.
+	// +optional
+	PruneAfterStable *metav1.Duration `json:"pruneAfterStable,omitempty"`
+
+	// PruneOnPartialFailure enables the garbage collection when the apply
+	// failed for some objects. Defaults to false, skipping the garbage
+	// collection until all the objects of the revision are applied.
+	// +optional
+	PruneOnPartialFailure *bool `json:"pruneOnPartialFailure,omitempty"`
+
+	// ReconcileOrder is the order in which the Apply, Prune and HealthCheck
+	// phases run. Apply must come first, as the objects can't be garbage
+	// collected or health checked before being applied.
+	// Defaults to Apply, Prune, HealthCheck.
+	// +kubebuilder:validation:MaxItems=3
+	// +optional
+	ReconcileOrder []string `json:"reconcileOrder,omitempty"`
+
+	// ApplyBatchSize is the maximum number of objects applied at once.
+	// When specified, one batch of objects is applied per reconciliation and
+	// the progress is reported in status. Defaults to applying all objects at once.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ApplyBatchSize int `json:"applyBatchSize,omitempty"`
+
+	// ApplyConcurrency is the maximum number of batches of objects applied
+	// in parallel. The objects are grouped in tiers applied in order, such as
+	// namespaces before workloads, and only the objects of the same tier are
+	// applied in parallel. Cannot be combined with ApplyBatchSize.
+	// Defaults to applying the objects sequentially.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ApplyConcurrency int `json:"applyConcurrency,omitempty"`
+
+	// AtomicApply enables a server-side dry-run of all the objects before
+	// applying them, the objects are applied only if the dry-run succeeds
+	// for every object. Defaults to false.
+	// +optional
+	AtomicApply *bool `json:"atomicApply,omitempty"`
+
+	// ApplyOrder is a list of objects applied first, in the order of the list,
+	// before the other objects. Objects not found in the build output are ignored.
+	// +optional
+	ApplyOrder []meta.NamespacedObjectKindReference `json:"applyOrder,omitempty"`
+
 	// A list of resources to be included in the health assessment.
 	// +optional
 	HealthChecks []meta.NamespacedObjectKindReference `json:"healthChecks,omitempty"`
 
+	// A list of objects to be included in the health assessment with the
+	// condition type and status they must have to be considered healthy.
+	// +optional
+	HealthCheckConditions []HealthCheckCondition `json:"healthCheckConditions,omitempty"`
+
 	// A list of images used to override or set the name and tag for container images.
 	// +optional
 	Images []Image `json:"images,omitempty"`
 
+	// A list of replica counts used to override or set the replicas of the
+	// objects with the given names.
+	// +optional
+	Replicas []Replica `json:"replicas,omitempty"`
+
+	// Patches is a list of strategic merge or JSON6902 patches, appended to
+	// the patches of the kustomization.yaml. The patches without a target
+	// are applied as strategic merge patches.
+	// +optional
+	Patches []Patch `json:"patches,omitempty"`
+
+	// CommonLabels are merged into the commonLabels of the kustomization.yaml,
+	// overriding the values of the same keys. The labels of the
+	// kustomize.toolkit.fluxcd.io group are reserved and ignored.
+	// +optional
+	CommonLabels map[string]string `json:"commonLabels,omitempty"`
+
+	// CommonAnnotations are merged into the commonAnnotations of the
+	// kustomization.yaml, overriding the values of the same keys. The
+	// annotations of the kustomize.toolkit.fluxcd.io group are reserved and ignored.
+	// +optional
+	CommonAnnotations map[string]string `json:"commonAnnotations,omitempty"`
+
+	// NamePrefix is prepended to the namePrefix of the kustomization.yaml.
+	// +optional
+	NamePrefix string `json:"namePrefix,omitempty"`
+
+	// NameSuffix is appended to the nameSuffix of the kustomization.yaml.
+	// +optional
+	NameSuffix string `json:"nameSuffix,omitempty"`
+
+	// ConfigTransformers is a list of kustomize transformer configuration files,
+	// such as nameReference configs, added to the configurations of the
+	// kustomization.yaml. The paths are relative to the Path.
+	// +optional
+	ConfigTransformers []string `json:"configTransformers,omitempty"`
+
+	// Components is a list of kustomize components added to the components
+	// of the kustomization.yaml, either paths relative to the Path or remote
+	// git URLs.
+	// +optional
+	Components []string `json:"components,omitempty"`
+
+	// A list of registry prefixes to be rewritten in all container images,
+	// applied after the Images overrides.
+	// +optional
+	ImageRegistryRewrite []ImageRegistryRewrite `json:"imageRegistryRewrite,omitempty"`
+
+	// A list of label and annotation transformers applied in order to the
+	// rendered objects, after the transformer that sets the garbage collection labels.
+	// +optional
+	MetadataTransformers []MetadataTransformer `json:"metadataTransformers,omitempty"`
+
+	// PostBuild describes the changes made to the objects after the kustomize build.
+	// +optional
+	PostBuild *PostBuild `json:"postBuild,omitempty"`
+
+	// AnnotateGeneration sets the 'kustomize.toolkit.fluxcd.io/generation'
+	// annotation to the Kustomization generation on the applied objects.
+	// The annotation is not part of the manifests checksum.
+	// +optional
+	AnnotateGeneration bool `json:"annotateGeneration,omitempty"`
+
+	// PropagateLabelsToPodTemplate sets the 'kustomize.toolkit.fluxcd.io/name'
+	// and 'kustomize.toolkit.fluxcd.io/namespace' labels in the pod template of
+	// the workloads. The label selectors of the workloads are left unchanged.
+	// +optional
+	PropagateLabelsToPodTemplate bool `json:"propagateLabelsToPodTemplate,omitempty"`
+
+	// PreserveAnnotations is a list of annotation keys that are copied from the
+	// live objects to the applied ones, so that the values set by other
+	// controllers are not overwritten.
+	// +optional
+	PreserveAnnotations []string `json:"preserveAnnotations,omitempty"`
+
+	// PreserveHPAReplicas sets the replicas of the Deployments and StatefulSets
+	// targeted by a HorizontalPodAutoscaler to their live value, so that the
+	// apply doesn't reset the replicas managed by the autoscaler.
+	// +optional
+	PreserveHPAReplicas bool `json:"preserveHPAReplicas,omitempty"`
+
+	// OwnershipConflictPolicy tells the controller what to do when objects of
+	// the manifests are managed by another Kustomization. 'Warn' reports the
+	// conflicts and applies the objects, 'Fail' fails the reconciliation
+	// without applying any object. Defaults to 'Warn'.
+	// +kubebuilder:validation:Enum=Warn;Fail
+	// +optional
+	OwnershipConflictPolicy string `json:"ownershipConflictPolicy,omitempty"`
+
+	// DroppedResourcesPolicy enables the detection of the objects of the
+	// manifests listed in the kustomization.yaml that are missing from the
+	// build output. 'Warn' reports the missing objects, 'Fail' fails the
+	// reconciliation. Defaults to no detection.
+	// +kubebuilder:validation:Enum=Warn;Fail
+	// +optional
+	DroppedResourcesPolicy string `json:"droppedResourcesPolicy,omitempty"`
+
+	// MissingNamespacePolicy enables the detection of the namespaced objects
+	// without namespace when no TargetNamespace is set, as these objects are
+	// applied into the default namespace. 'Warn' reports the objects, 'Fail'
+	// fails the reconciliation. Defaults to no detection.
+	// +kubebuilder:validation:Enum=Warn;Fail
+	// +optional
+	MissingNamespacePolicy string `json:"missingNamespacePolicy,omitempty"`
+
+	// ChecksumExclude is a list of resources left out of the manifests checksum,
+	// these resources are still applied. Changes limited to these resources
+	// don't update the checksum label of the applied objects.
+	// +optional
+	ChecksumExclude []ResourceKindReference `json:"checksumExclude,omitempty"`
+
+	// ForceReconcileToken is included in the manifests checksum, changing
+	// its value results in a new checksum and in all the objects being
+	// applied again, without changes to the source.
+	// +optional
+	ForceReconcileToken string `json:"forceReconcileToken,omitempty"`
+
+	// A list of conditions on cluster objects that must be met before
+	// the Kustomization is applied.
+	// +optional
+	Preconditions []Precondition `json:"preconditions,omitempty"`
+
 	// The name of the Kubernetes service account to impersonate
 	// when reconciling this Kustomization.
 	// +optional
@@ -86,11 +322,43 @@ type KustomizationSpec struct {
 	// +required
 	SourceRef CrossNamespaceSourceReference `json:"sourceRef"`
 
+	// ArtifactURL is the HTTP address of a tarball containing the kustomization
+	// file. When specified, the artifact is downloaded from this address instead
+	// of the SourceRef, which is not resolved. Intended for testing and for
+	// bootstrapping clusters before source-controller is running, the controller
+	// must be started with --enable-artifact-url.
+	// +optional
+	ArtifactURL string `json:"artifactURL,omitempty"`
+
+	// SourceRevision pins the Kustomization to a specific revision of the
+	// source e.g. 'main/<commit-sha>'. When specified, the controller builds
+	// the source artifact only when it is at this revision, and ignores the
+	// other revisions.
+	// +optional
+	SourceRevision string `json:"sourceRevision,omitempty"`
+
+	// SourceSettlePeriod is the time a new source revision must remain the
+	// latest before it is built, so that the revisions superseded within the
+	// period are skipped. Defaults to zero, building every revision.
+	// +optional
+	SourceSettlePeriod *metav1.Duration `json:"sourceSettlePeriod,omitempty"`
+
 	// This flag tells the controller to suspend subsequent kustomize executions,
 	// it does not apply to already started executions. Defaults to false.
 	// +optional
 	Suspend bool `json:"suspend,omitempty"`
 
+	// ReportOnly tells the controller to build the manifests and compare them
+	// with the objects on the cluster without applying or pruning anything.
+	// The result is recorded in the DriftReport status field. Defaults to false.
+	// +optional
+	ReportOnly bool `json:"reportOnly,omitempty"`
+
+	// ReportOrigins tells the controller to record the source file of each
+	// rendered object in the Origins status field. Defaults to false.
+	// +optional
+	ReportOrigins bool `json:"reportOrigins,omitempty"`
+
 	// TargetNamespace sets or overrides the namespace in the
 	// kustomization.yaml file.
 	// +kubebuilder:validation:MinLength=1
@@ -99,14 +367,55 @@ type KustomizationSpec struct {
 	// +optional
 	TargetNamespace string `json:"targetNamespace,omitempty"`
 
+	// TargetNamespaceExclude is a list of resources the TargetNamespace
+	// override does not apply to, these resources keep the namespace
+	// defined in their manifests.
+	// +optional
+	TargetNamespaceExclude []ResourceKindReference `json:"targetNamespaceExclude,omitempty"`
+
+	// TargetNamespaceSelector selects the namespaces the objects are applied
+	// into, the namespaced objects are rendered once per matching namespace.
+	// Mutually exclusive with TargetNamespace.
+	// +optional
+	TargetNamespaceSelector *metav1.LabelSelector `json:"targetNamespaceSelector,omitempty"`
+
+	// CreateNamespace adds the TargetNamespace to the applied objects, the
+	// namespace is garbage collected with the Kustomization. Defaults to false.
+	// +optional
+	CreateNamespace bool `json:"createNamespace,omitempty"`
+
+	// NamespaceLabels are the labels set on the namespace created
+	// with CreateNamespace e.g. the Pod Security admission labels.
+	// +optional
+	NamespaceLabels map[string]string `json:"namespaceLabels,omitempty"`
+
+	// NamespaceAnnotations are the annotations set on the
+	// namespace created with CreateNamespace.
+	// +optional
+	NamespaceAnnotations map[string]string `json:"namespaceAnnotations,omitempty"`
+
+	// ReconcileBudget is the expected maximum duration of a reconciliation.
+	// When a reconciliation takes longer, the controller emits an event and sets
+	// the ReconcileBudgetExceeded condition, without aborting the reconciliation.
+	// Defaults to the controller --default-reconcile-budget flag value.
+	// +optional
+	ReconcileBudget *metav1.Duration `json:"reconcileBudget,omitempty"`
+
+	// FailureGrace keeps the Ready condition of the last successful reconciliation
+	// for a number of consecutive failures or a period of time, the failures are
+	// reported with the Degraded condition in the meantime.
+	// +optional
+	FailureGrace *FailureGrace `json:"failureGrace,omitempty"`
+
 	// Timeout for validation, apply and health checking operations.
 	// Defaults to 'Interval' duration.
 	// +optional
 	Timeout *metav1.Duration `json:"timeout,omitempty"`
 
 	// Validate the Kubernetes objects before applying them on the cluster.
-	// The validation strategy can be 'client' (local dry-run), 'server' (APIServer dry-run) or 'none'.
-	// +kubebuilder:validation:Enum=none;client;server
+	// The validation strategy can be 'client' (local dry-run), 'server' (APIServer dry-run),
+	// 'server-apply' (APIServer server-side apply dry-run) or 'none'.
+	// +kubebuilder:validation:Enum=none;client;server;server-apply
 	// +optional
 	Validation string `json:"validation,omitempty"`
 }
@@ -138,6 +447,190 @@ type Image struct {
 	NewTag string `json:"newTag"`
 }
 
+// Replica contains the name of an object and the replica count set on it.
+type Replica struct {
+	// Name of the Deployment, StatefulSet, ReplicaSet or ReplicationController.
+	// +required
+	Name string `json:"name"`
+
+	// Count is the number of replicas.
+	// +kubebuilder:validation:Minimum=0
+	// +required
+	Count int64 `json:"count"`
+}
+
+// Patch contains an inline strategic merge or JSON6902 patch,
+// and the target the patch is applied to.
+type Patch struct {
+	// Patch contains the strategic merge patch or the JSON6902 patch,
+	// in YAML or JSON format.
+	// +required
+	Patch string `json:"patch"`
+
+	// Target selects the objects the patch is applied to.
+	// Required for JSON6902 patches.
+	// +optional
+	Target *PatchSelector `json:"target,omitempty"`
+}
+
+// PatchSelector selects the objects a patch is applied to.
+type PatchSelector struct {
+	// Group of the objects.
+	// +optional
+	Group string `json:"group,omitempty"`
+
+	// Version of the objects.
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Kind of the objects.
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// Namespace of the objects.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Name of the objects.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// AnnotationSelector is a label selector matched against the
+	// annotations of the objects.
+	// +optional
+	AnnotationSelector string `json:"annotationSelector,omitempty"`
+
+	// LabelSelector is a label selector matched against the
+	// labels of the objects.
+	// +optional
+	LabelSelector string `json:"labelSelector,omitempty"`
+}
+
+// ImageRegistryRewrite contains the registry prefix that will replace the
+// original prefix of container images.
+type ImageRegistryRewrite struct {
+	// From is the image prefix to be replaced e.g. 'docker.io' or 'ghcr.io/org'.
+	// Images without a registry are matched as 'docker.io' images.
+	// +required
+	From string `json:"from"`
+
+	// To is the prefix used to replace the original one e.g. 'registry.internal'.
+	// +required
+	To string `json:"to"`
+}
+
+// MetadataTransformer configures a kustomize builtin transformer
+// that sets labels or annotations on the rendered objects.
+type MetadataTransformer struct {
+	// Kind of the builtin transformer.
+	// +kubebuilder:validation:Enum=LabelTransformer;AnnotationsTransformer
+	// +required
+	Kind string `json:"kind"`
+
+	// Values are the labels or annotations set by the transformer.
+	// +required
+	Values map[string]string `json:"values"`
+
+	// FieldSpecs are the fields the values are set in, e.g. the pod template
+	// labels of Deployments. Defaults to the object metadata labels or annotations.
+	// +optional
+	FieldSpecs []FieldSpec `json:"fieldSpecs,omitempty"`
+}
+
+// FieldSpec selects a field of the objects of a kind.
+type FieldSpec struct {
+	// Group of the objects, matches all groups when not specified.
+	// +optional
+	Group string `json:"group,omitempty"`
+
+	// Version of the objects, matches all versions when not specified.
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Kind of the objects, matches all kinds when not specified.
+	// +optional
+	Kind string `json:"kind,omitempty"`
+
+	// Path of the field e.g. 'spec/template/metadata/labels'.
+	// +required
+	Path string `json:"path"`
+
+	// Create the field if it's not present on the object.
+	// +optional
+	Create bool `json:"create,omitempty"`
+}
+
+// AdoptSelector selects the live objects adopted by a Kustomization.
+type AdoptSelector struct {
+	// Kinds of the adopted objects, the API version defaults to 'v1'.
+	// When the name is specified, only the object with that name is adopted.
+	// +required
+	Kinds []ResourceKindReference `json:"kinds"`
+
+	// LabelSelector the adopted objects must match.
+	// +required
+	LabelSelector metav1.LabelSelector `json:"labelSelector"`
+
+	// Namespace of the adopted objects, all namespaces are
+	// searched when not specified.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// PostBuild describes the changes made to the objects after the kustomize
+// build and before they are applied on the cluster.
+type PostBuild struct {
+	// RemoveAnnotations is a list of annotation keys removed from the objects.
+	// The annotations of the kustomize.toolkit.fluxcd.io group can't be removed.
+	// +optional
+	RemoveAnnotations []string `json:"removeAnnotations,omitempty"`
+
+	// RemoveLabels is a list of label keys removed from the objects.
+	// The labels of the kustomize.toolkit.fluxcd.io group can't be removed.
+	// +optional
+	RemoveLabels []string `json:"removeLabels,omitempty"`
+}
+
+// Precondition references a Kubernetes object and the value a JSONPath
+// expression must evaluate to on that object.
+type Precondition struct {
+	// Reference of the object the expression is evaluated against,
+	// the object must be in the Kustomization namespace.
+	// +required
+	ObjectRef meta.NamespacedObjectKindReference `json:"objectRef"`
+
+	// JSONPath expression evaluated against the object, e.g. '{.data.enabled}'.
+	// +required
+	JSONPath string `json:"jsonPath"`
+
+	// Value the JSONPath expression is expected to evaluate to.
+	// +required
+	Value string `json:"value"`
+
+	// Watch tells the controller to reconcile the Kustomization as soon as
+	// the referenced object changes, instead of waiting for the retry interval.
+	// +optional
+	Watch bool `json:"watch,omitempty"`
+}
+
+// HealthCheckCondition defines the condition an object
+// must have to pass the health assessment.
+type HealthCheckCondition struct {
+	// Reference of the object to check.
+	// +required
+	ObjectRef meta.NamespacedObjectKindReference `json:"objectRef"`
+
+	// Type of the condition e.g. 'Progressing'.
+	// +required
+	Type string `json:"type"`
+
+	// Status the condition must have, defaults to 'True'.
+	// +kubebuilder:validation:Enum=True;False;Unknown
+	// +kubebuilder:default:=True
+	// +optional
+	Status metav1.ConditionStatus `json:"status,omitempty"`
+}
+
 // KubeConfig references a Kubernetes secret that contains a kubeconfig file.
 type KubeConfig struct {
 	// SecretRef holds the name to a secret that contains a 'value' key with
@@ -150,6 +643,23 @@ type KubeConfig struct {
 	// the Kustomization.
 	// +required
 	SecretRef meta.LocalObjectReference `json:"secretRef,omitempty"`
+
+	// Context is the name of the kubeconfig context used to reconcile
+	// the Kustomization. Defaults to the current-context of the kubeconfig.
+	// +optional
+	Context string `json:"context,omitempty"`
+
+	// CASecretRef holds the name to a secret that contains a 'ca.crt' key
+	// with a PEM encoded CA bundle, added to the certificate authorities
+	// of the kubeconfig cluster. It must be in the same namespace as the
+	// Kustomization.
+	// +optional
+	CASecretRef *meta.LocalObjectReference `json:"caSecretRef,omitempty"`
+
+	// InsecureSkipTLSVerify disables the verification of the remote cluster
+	// API server certificate, it should only be used for test clusters.
+	// +optional
+	InsecureSkipTLSVerify bool `json:"insecureSkipTLSVerify,omitempty"`
 }
 
 // KustomizationStatus defines the observed state of a kustomization.
@@ -175,6 +685,209 @@ type KustomizationStatus struct {
 	// The last successfully applied revision metadata.
 	// +optional
 	Snapshot *Snapshot `json:"snapshot,omitempty"`
+
+	// ResourceCount is the number of Kubernetes objects produced
+	// by the last successful kustomize build.
+	// +optional
+	ResourceCount int `json:"resourceCount,omitempty"`
+
+	// LastReconcileDuration is the duration of the last reconciliation.
+	// +optional
+	LastReconcileDuration *metav1.Duration `json:"lastReconcileDuration,omitempty"`
+
+	// DriftReport is the result of the last comparison of the manifests
+	// with the cluster state, set when ReportOnly is enabled.
+	// +optional
+	DriftReport *DriftReport `json:"driftReport,omitempty"`
+
+	// Inventory contains the list of Kubernetes resource object references
+	// that have been successfully applied.
+	// +optional
+	Inventory *ResourceInventory `json:"inventory,omitempty"`
+
+	// ConsecutiveFailures is the number of failed reconciliations
+	// since the last successful one.
+	// +optional
+	ConsecutiveFailures int `json:"consecutiveFailures,omitempty"`
+
+	// FailingSince is the time of the first failed reconciliation
+	// since the last successful one.
+	// +optional
+	FailingSince *metav1.Time `json:"failingSince,omitempty"`
+
+	// Origins lists the source file of each object of the last
+	// kustomize build, set when ReportOrigins is enabled.
+	// +optional
+	Origins []ResourceOrigin `json:"origins,omitempty"`
+
+	// StableSince is the time since the objects of the
+	// last applied revision have been healthy.
+	// +optional
+	StableSince *metav1.Time `json:"stableSince,omitempty"`
+
+	// EffectiveSpec holds the values the controller resolved from the spec
+	// and its defaults at the last reconciliation.
+	// +optional
+	EffectiveSpec *EffectiveSpec `json:"effectiveSpec,omitempty"`
+
+	// LastReconcileDiff summarizes the changes of the rendered objects
+	// compared to the last applied ones, set after each build.
+	// +optional
+	LastReconcileDiff *ReconcileDiff `json:"lastReconcileDiff,omitempty"`
+
+	// TargetNamespaces are the namespaces matching the TargetNamespaceSelector
+	// at the last reconciliation.
+	// +optional
+	TargetNamespaces []string `json:"targetNamespaces,omitempty"`
+
+	// PendingRevision is the latest source revision waiting for the
+	// SourceSettlePeriod to elapse, the last settled revision being
+	// LastAttemptedRevision.
+	// +optional
+	PendingRevision string `json:"pendingRevision,omitempty"`
+
+	// ApplyProgress is the number of objects applied so far, set while
+	// the batches of objects are applied, one per reconciliation.
+	// +optional
+	ApplyProgress *ApplyProgress `json:"applyProgress,omitempty"`
+}
+
+// FailureGrace defines for how long failed reconciliations are tolerated
+// before the Kustomization is marked as not ready. The grace ends when
+// either the number of failures or the duration is exceeded.
+type FailureGrace struct {
+	// Failures is the number of consecutive failed reconciliations tolerated.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Failures int `json:"failures,omitempty"`
+
+	// Duration is the time failed reconciliations are tolerated for,
+	// from the first failure since the last successful reconciliation.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+}
+
+// DriftReport summarizes how the objects on the cluster
+// differ from the manifests of a source revision.
+type DriftReport struct {
+	// Revision is the source revision the cluster state was compared with.
+	// +required
+	Revision string `json:"revision"`
+
+	// InSync is the number of objects that match the manifests.
+	// +required
+	InSync int `json:"inSync"`
+
+	// Drifted lists the objects whose fields differ from the manifests,
+	// in the <kind>/<namespace>/<name> format.
+	// +optional
+	Drifted []string `json:"drifted,omitempty"`
+
+	// Missing lists the objects of the manifests that are not found
+	// on the cluster, in the <kind>/<namespace>/<name> format.
+	// +optional
+	Missing []string `json:"missing,omitempty"`
+}
+
+// ApplyProgress records the progress of the apply of a build
+// whose objects are applied in batches.
+type ApplyProgress struct {
+	// Checksum is the checksum of the build being applied.
+	// +required
+	Checksum string `json:"checksum"`
+
+	// Applied is the number of objects applied.
+	// +required
+	Applied int `json:"applied"`
+
+	// Total is the number of objects of the build.
+	// +required
+	Total int `json:"total"`
+}
+
+// ReconcileDiff counts the objects of a source revision that are added,
+// modified, removed or unchanged compared to the last applied objects.
+type ReconcileDiff struct {
+	// Revision is the source revision of the rendered objects.
+	// +required
+	Revision string `json:"revision"`
+
+	// Added is the number of objects missing from the last applied ones.
+	// +optional
+	Added int `json:"added,omitempty"`
+
+	// Modified is the number of objects whose manifest changed.
+	// +optional
+	Modified int `json:"modified,omitempty"`
+
+	// Removed is the number of last applied objects missing from the revision.
+	// +optional
+	Removed int `json:"removed,omitempty"`
+
+	// Unchanged is the number of objects whose manifest didn't change.
+	// +optional
+	Unchanged int `json:"unchanged,omitempty"`
+}
+
+// EffectiveSpec is the configuration used by the controller to reconcile a
+// Kustomization, after resolving the source and applying the defaults.
+// Secrets are referenced by name only, their contents are never recorded.
+type EffectiveSpec struct {
+	// Source is the resolved source in the <kind>/<namespace>/<name> format,
+	// or the ArtifactURL when specified.
+	// +required
+	Source string `json:"source"`
+
+	// Revision of the source artifact.
+	// +optional
+	Revision string `json:"revision,omitempty"`
+
+	// Path to the kustomization relative to the artifact root,
+	// after stripping the path components.
+	// +required
+	Path string `json:"path"`
+
+	// TargetNamespace set on the objects.
+	// +optional
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+
+	// ServiceAccountName impersonated when applying the objects.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// KubeConfigSecretName is the name of the secret holding the
+	// kubeconfig of the remote cluster.
+	// +optional
+	KubeConfigSecretName string `json:"kubeConfigSecretName,omitempty"`
+
+	// DecryptionProvider used to decrypt the manifests.
+	// +optional
+	DecryptionProvider string `json:"decryptionProvider,omitempty"`
+
+	// Interval at which the Kustomization is reconciled.
+	// +required
+	Interval metav1.Duration `json:"interval"`
+
+	// RetryInterval at which failed reconciliations are retried.
+	// +required
+	RetryInterval metav1.Duration `json:"retryInterval"`
+
+	// Timeout for the apply and health checking operations.
+	// +required
+	Timeout metav1.Duration `json:"timeout"`
+
+	// Validation mode of the manifests.
+	// +optional
+	Validation string `json:"validation,omitempty"`
+
+	// Prune is true when garbage collection is enabled.
+	// +required
+	Prune bool `json:"prune"`
+
+	// BuildFlags are the `kustomize build` flags matching the options
+	// the manifests were built with.
+	// +optional
+	BuildFlags string `json:"buildFlags,omitempty"`
 }
 
 // KustomizationProgressing resets the conditions of the given Kustomization to a single
@@ -250,6 +963,51 @@ func (in *Kustomization) GetStatusConditions() *[]metav1.Condition {
 	return &in.Status.Conditions
 }
 
+const (
+	// PruneLimitRefuse tells the garbage collector to delete no objects
+	// when there are more objects to delete than the PruneLimit.
+	PruneLimitRefuse string = "Refuse"
+	// PruneLimitPartial tells the garbage collector to delete PruneLimit
+	// objects when there are more objects to delete.
+	PruneLimitPartial string = "Partial"
+)
+
+const (
+	// ApplyPhase applies the objects to the cluster.
+	ApplyPhase string = "Apply"
+	// PrunePhase garbage collects the objects removed from the source.
+	PrunePhase string = "Prune"
+	// HealthCheckPhase assesses the health of the applied objects.
+	HealthCheckPhase string = "HealthCheck"
+)
+
+const (
+	// OwnershipConflictWarn tells the controller to report the objects
+	// managed by another Kustomization and to apply them.
+	OwnershipConflictWarn string = "Warn"
+	// OwnershipConflictFail tells the controller to fail the reconciliation
+	// when objects are managed by another Kustomization.
+	OwnershipConflictFail string = "Fail"
+)
+
+const (
+	// DroppedResourcesWarn tells the controller to report the objects
+	// missing from the build output.
+	DroppedResourcesWarn string = "Warn"
+	// DroppedResourcesFail tells the controller to fail the reconciliation
+	// when objects are missing from the build output.
+	DroppedResourcesFail string = "Fail"
+)
+
+const (
+	// MissingNamespaceWarn tells the controller to report the
+	// namespaced objects without namespace.
+	MissingNamespaceWarn string = "Warn"
+	// MissingNamespaceFail tells the controller to fail the reconciliation
+	// when namespaced objects have no namespace.
+	MissingNamespaceFail string = "Fail"
+)
+
 const (
 	// GitRepositoryIndexKey is the key used for indexing kustomizations
 	// based on their Git sources.
@@ -257,6 +1015,9 @@ const (
 	// BucketIndexKey is the key used for indexing kustomizations
 	// based on their S3 sources.
 	BucketIndexKey string = ".metadata.bucket"
+	// PreconditionIndexKey is the key used for indexing kustomizations
+	// based on the objects their watched preconditions reference.
+	PreconditionIndexKey string = ".metadata.precondition"
 )
 
 // +genclient
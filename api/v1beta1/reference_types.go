@@ -36,3 +36,19 @@ type CrossNamespaceSourceReference struct {
 	// +optional
 	Namespace string `json:"namespace,omitempty"`
 }
+
+// ResourceKindReference contains enough information to let you locate
+// a Kubernetes object produced by the kustomize build.
+type ResourceKindReference struct {
+	// API version of the referent, when not specified any version matches
+	// +optional
+	APIVersion string `json:"apiVersion,omitempty"`
+
+	// Kind of the referent
+	// +required
+	Kind string `json:"kind"`
+
+	// Name of the referent, when not specified all objects of the kind match
+	// +optional
+	Name string `json:"name,omitempty"`
+}
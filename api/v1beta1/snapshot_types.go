@@ -101,6 +101,36 @@ func (s *Snapshot) addEntry(item *unstructured.Unstructured) {
 	}
 }
 
+// WithEntriesOf returns a copy of the snapshot that also tracks the kinds
+// and namespaces of the given snapshot, the checksum is left unchanged.
+func (s *Snapshot) WithEntriesOf(other *Snapshot) *Snapshot {
+	out := s.DeepCopy()
+	if other == nil {
+		return out
+	}
+	for _, entry := range other.Entries {
+		for gvk, kind := range entry.Kinds {
+			out.addKind(entry.Namespace, gvk, kind)
+		}
+	}
+	return out
+}
+
+func (s *Snapshot) addKind(namespace, gvk, kind string) {
+	for _, tracker := range s.Entries {
+		if tracker.Namespace == namespace {
+			tracker.Kinds[gvk] = kind
+			return
+		}
+	}
+	s.Entries = append(s.Entries, SnapshotEntry{
+		Namespace: namespace,
+		Kinds: map[string]string{
+			gvk: kind,
+		},
+	})
+}
+
 func (s *Snapshot) NonNamespacedKinds() []schema.GroupVersionKind {
 	kinds := make([]schema.GroupVersionKind, 0)
 
@@ -27,6 +27,42 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AdoptSelector) DeepCopyInto(out *AdoptSelector) {
+	*out = *in
+	if in.Kinds != nil {
+		in, out := &in.Kinds, &out.Kinds
+		*out = make([]ResourceKindReference, len(*in))
+		copy(*out, *in)
+	}
+	in.LabelSelector.DeepCopyInto(&out.LabelSelector)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdoptSelector.
+func (in *AdoptSelector) DeepCopy() *AdoptSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(AdoptSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApplyProgress) DeepCopyInto(out *ApplyProgress) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApplyProgress.
+func (in *ApplyProgress) DeepCopy() *ApplyProgress {
+	if in == nil {
+		return nil
+	}
+	out := new(ApplyProgress)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CrossNamespaceSourceReference) DeepCopyInto(out *CrossNamespaceSourceReference) {
 	*out = *in
@@ -62,6 +98,100 @@ func (in *Decryption) DeepCopy() *Decryption {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriftReport) DeepCopyInto(out *DriftReport) {
+	*out = *in
+	if in.Drifted != nil {
+		in, out := &in.Drifted, &out.Drifted
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Missing != nil {
+		in, out := &in.Missing, &out.Missing
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriftReport.
+func (in *DriftReport) DeepCopy() *DriftReport {
+	if in == nil {
+		return nil
+	}
+	out := new(DriftReport)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EffectiveSpec) DeepCopyInto(out *EffectiveSpec) {
+	*out = *in
+	out.Interval = in.Interval
+	out.RetryInterval = in.RetryInterval
+	out.Timeout = in.Timeout
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EffectiveSpec.
+func (in *EffectiveSpec) DeepCopy() *EffectiveSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EffectiveSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailureGrace) DeepCopyInto(out *FailureGrace) {
+	*out = *in
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailureGrace.
+func (in *FailureGrace) DeepCopy() *FailureGrace {
+	if in == nil {
+		return nil
+	}
+	out := new(FailureGrace)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FieldSpec) DeepCopyInto(out *FieldSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FieldSpec.
+func (in *FieldSpec) DeepCopy() *FieldSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FieldSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HealthCheckCondition) DeepCopyInto(out *HealthCheckCondition) {
+	*out = *in
+	out.ObjectRef = in.ObjectRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HealthCheckCondition.
+func (in *HealthCheckCondition) DeepCopy() *HealthCheckCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(HealthCheckCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Image) DeepCopyInto(out *Image) {
 	*out = *in
@@ -77,10 +207,30 @@ func (in *Image) DeepCopy() *Image {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageRegistryRewrite) DeepCopyInto(out *ImageRegistryRewrite) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageRegistryRewrite.
+func (in *ImageRegistryRewrite) DeepCopy() *ImageRegistryRewrite {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageRegistryRewrite)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KubeConfig) DeepCopyInto(out *KubeConfig) {
 	*out = *in
 	out.SecretRef = in.SecretRef
+	if in.CASecretRef != nil {
+		in, out := &in.CASecretRef, &out.CASecretRef
+		*out = new(meta.LocalObjectReference)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubeConfig.
@@ -160,6 +310,11 @@ func (in *KustomizationSpec) DeepCopyInto(out *KustomizationSpec) {
 		*out = make([]dependency.CrossNamespaceDependencyReference, len(*in))
 		copy(*out, *in)
 	}
+	if in.DependsOnHelmReleases != nil {
+		in, out := &in.DependsOnHelmReleases, &out.DependsOnHelmReleases
+		*out = make([]dependency.CrossNamespaceDependencyReference, len(*in))
+		copy(*out, *in)
+	}
 	if in.Decryption != nil {
 		in, out := &in.Decryption, &out.Decryption
 		*out = new(Decryption)
@@ -174,19 +329,181 @@ func (in *KustomizationSpec) DeepCopyInto(out *KustomizationSpec) {
 	if in.KubeConfig != nil {
 		in, out := &in.KubeConfig, &out.KubeConfig
 		*out = new(KubeConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PruneExclude != nil {
+		in, out := &in.PruneExclude, &out.PruneExclude
+		*out = make([]meta.NamespacedObjectKindReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.AdoptSelector != nil {
+		in, out := &in.AdoptSelector, &out.AdoptSelector
+		*out = new(AdoptSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AllowCRDPrune != nil {
+		in, out := &in.AllowCRDPrune, &out.AllowCRDPrune
+		*out = new(bool)
 		**out = **in
 	}
+	if in.AllowCRDVersionRemoval != nil {
+		in, out := &in.AllowCRDVersionRemoval, &out.AllowCRDVersionRemoval
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AllowEmptyRender != nil {
+		in, out := &in.AllowEmptyRender, &out.AllowEmptyRender
+		*out = new(bool)
+		**out = **in
+	}
+	if in.PruneAfterStable != nil {
+		in, out := &in.PruneAfterStable, &out.PruneAfterStable
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.PruneOnPartialFailure != nil {
+		in, out := &in.PruneOnPartialFailure, &out.PruneOnPartialFailure
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ReconcileOrder != nil {
+		in, out := &in.ReconcileOrder, &out.ReconcileOrder
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AtomicApply != nil {
+		in, out := &in.AtomicApply, &out.AtomicApply
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ApplyOrder != nil {
+		in, out := &in.ApplyOrder, &out.ApplyOrder
+		*out = make([]meta.NamespacedObjectKindReference, len(*in))
+		copy(*out, *in)
+	}
 	if in.HealthChecks != nil {
 		in, out := &in.HealthChecks, &out.HealthChecks
 		*out = make([]meta.NamespacedObjectKindReference, len(*in))
 		copy(*out, *in)
 	}
+	if in.HealthCheckConditions != nil {
+		in, out := &in.HealthCheckConditions, &out.HealthCheckConditions
+		*out = make([]HealthCheckCondition, len(*in))
+		copy(*out, *in)
+	}
 	if in.Images != nil {
 		in, out := &in.Images, &out.Images
 		*out = make([]Image, len(*in))
 		copy(*out, *in)
 	}
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = make([]Replica, len(*in))
+		copy(*out, *in)
+	}
+	if in.Patches != nil {
+		in, out := &in.Patches, &out.Patches
+		*out = make([]Patch, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.CommonLabels != nil {
+		in, out := &in.CommonLabels, &out.CommonLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.CommonAnnotations != nil {
+		in, out := &in.CommonAnnotations, &out.CommonAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ConfigTransformers != nil {
+		in, out := &in.ConfigTransformers, &out.ConfigTransformers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Components != nil {
+		in, out := &in.Components, &out.Components
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ImageRegistryRewrite != nil {
+		in, out := &in.ImageRegistryRewrite, &out.ImageRegistryRewrite
+		*out = make([]ImageRegistryRewrite, len(*in))
+		copy(*out, *in)
+	}
+	if in.MetadataTransformers != nil {
+		in, out := &in.MetadataTransformers, &out.MetadataTransformers
+		*out = make([]MetadataTransformer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PostBuild != nil {
+		in, out := &in.PostBuild, &out.PostBuild
+		*out = new(PostBuild)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PreserveAnnotations != nil {
+		in, out := &in.PreserveAnnotations, &out.PreserveAnnotations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ChecksumExclude != nil {
+		in, out := &in.ChecksumExclude, &out.ChecksumExclude
+		*out = make([]ResourceKindReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Preconditions != nil {
+		in, out := &in.Preconditions, &out.Preconditions
+		*out = make([]Precondition, len(*in))
+		copy(*out, *in)
+	}
 	out.SourceRef = in.SourceRef
+	if in.SourceSettlePeriod != nil {
+		in, out := &in.SourceSettlePeriod, &out.SourceSettlePeriod
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.TargetNamespaceExclude != nil {
+		in, out := &in.TargetNamespaceExclude, &out.TargetNamespaceExclude
+		*out = make([]ResourceKindReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.TargetNamespaceSelector != nil {
+		in, out := &in.TargetNamespaceSelector, &out.TargetNamespaceSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NamespaceLabels != nil {
+		in, out := &in.NamespaceLabels, &out.NamespaceLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.NamespaceAnnotations != nil {
+		in, out := &in.NamespaceAnnotations, &out.NamespaceAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ReconcileBudget != nil {
+		in, out := &in.ReconcileBudget, &out.ReconcileBudget
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.FailureGrace != nil {
+		in, out := &in.FailureGrace, &out.FailureGrace
+		*out = new(FailureGrace)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Timeout != nil {
 		in, out := &in.Timeout, &out.Timeout
 		*out = new(v1.Duration)
@@ -220,6 +537,54 @@ func (in *KustomizationStatus) DeepCopyInto(out *KustomizationStatus) {
 		*out = new(Snapshot)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.LastReconcileDuration != nil {
+		in, out := &in.LastReconcileDuration, &out.LastReconcileDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.DriftReport != nil {
+		in, out := &in.DriftReport, &out.DriftReport
+		*out = new(DriftReport)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Inventory != nil {
+		in, out := &in.Inventory, &out.Inventory
+		*out = new(ResourceInventory)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FailingSince != nil {
+		in, out := &in.FailingSince, &out.FailingSince
+		*out = (*in).DeepCopy()
+	}
+	if in.Origins != nil {
+		in, out := &in.Origins, &out.Origins
+		*out = make([]ResourceOrigin, len(*in))
+		copy(*out, *in)
+	}
+	if in.StableSince != nil {
+		in, out := &in.StableSince, &out.StableSince
+		*out = (*in).DeepCopy()
+	}
+	if in.EffectiveSpec != nil {
+		in, out := &in.EffectiveSpec, &out.EffectiveSpec
+		*out = new(EffectiveSpec)
+		**out = **in
+	}
+	if in.LastReconcileDiff != nil {
+		in, out := &in.LastReconcileDiff, &out.LastReconcileDiff
+		*out = new(ReconcileDiff)
+		**out = **in
+	}
+	if in.TargetNamespaces != nil {
+		in, out := &in.TargetNamespaces, &out.TargetNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ApplyProgress != nil {
+		in, out := &in.ApplyProgress, &out.ApplyProgress
+		*out = new(ApplyProgress)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KustomizationStatus.
@@ -232,6 +597,204 @@ func (in *KustomizationStatus) DeepCopy() *KustomizationStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetadataTransformer) DeepCopyInto(out *MetadataTransformer) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.FieldSpecs != nil {
+		in, out := &in.FieldSpecs, &out.FieldSpecs
+		*out = make([]FieldSpec, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataTransformer.
+func (in *MetadataTransformer) DeepCopy() *MetadataTransformer {
+	if in == nil {
+		return nil
+	}
+	out := new(MetadataTransformer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Patch) DeepCopyInto(out *Patch) {
+	*out = *in
+	if in.Target != nil {
+		in, out := &in.Target, &out.Target
+		*out = new(PatchSelector)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Patch.
+func (in *Patch) DeepCopy() *Patch {
+	if in == nil {
+		return nil
+	}
+	out := new(Patch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PatchSelector) DeepCopyInto(out *PatchSelector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PatchSelector.
+func (in *PatchSelector) DeepCopy() *PatchSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(PatchSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostBuild) DeepCopyInto(out *PostBuild) {
+	*out = *in
+	if in.RemoveAnnotations != nil {
+		in, out := &in.RemoveAnnotations, &out.RemoveAnnotations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RemoveLabels != nil {
+		in, out := &in.RemoveLabels, &out.RemoveLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostBuild.
+func (in *PostBuild) DeepCopy() *PostBuild {
+	if in == nil {
+		return nil
+	}
+	out := new(PostBuild)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Precondition) DeepCopyInto(out *Precondition) {
+	*out = *in
+	out.ObjectRef = in.ObjectRef
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Precondition.
+func (in *Precondition) DeepCopy() *Precondition {
+	if in == nil {
+		return nil
+	}
+	out := new(Precondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReconcileDiff) DeepCopyInto(out *ReconcileDiff) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReconcileDiff.
+func (in *ReconcileDiff) DeepCopy() *ReconcileDiff {
+	if in == nil {
+		return nil
+	}
+	out := new(ReconcileDiff)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Replica) DeepCopyInto(out *Replica) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Replica.
+func (in *Replica) DeepCopy() *Replica {
+	if in == nil {
+		return nil
+	}
+	out := new(Replica)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceOrigin) DeepCopyInto(out *ResourceOrigin) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceOrigin.
+func (in *ResourceOrigin) DeepCopy() *ResourceOrigin {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceOrigin)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceInventory) DeepCopyInto(out *ResourceInventory) {
+	*out = *in
+	if in.Entries != nil {
+		in, out := &in.Entries, &out.Entries
+		*out = make([]ResourceRef, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceInventory.
+func (in *ResourceInventory) DeepCopy() *ResourceInventory {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceInventory)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceKindReference) DeepCopyInto(out *ResourceKindReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceKindReference.
+func (in *ResourceKindReference) DeepCopy() *ResourceKindReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceKindReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceRef) DeepCopyInto(out *ResourceRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceRef.
+func (in *ResourceRef) DeepCopy() *ResourceRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Snapshot) DeepCopyInto(out *Snapshot) {
 	*out = *in
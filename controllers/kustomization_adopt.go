@@ -0,0 +1,150 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// adoptObjects labels the live objects selected by the AdoptSelector for
+// garbage collection with the given checksum, and returns them in the
+// multi-doc YAML format so that they are added to the snapshot and inventory.
+// The adoption is one-shot, it only happens at the first reconciliation,
+// before any revision is applied. The objects managed by another
+// Kustomization are skipped.
+func (r *KustomizationReconciler) adoptObjects(ctx context.Context, kubeClient client.Client,
+	kustomization kustomizev1.Kustomization, checksum string) ([]byte, error) {
+	selector := kustomization.Spec.AdoptSelector
+	if selector == nil || kustomization.Status.LastAppliedRevision != "" {
+		return nil, nil
+	}
+	log := logr.FromContext(ctx)
+
+	labelSelector, err := metav1.LabelSelectorAsSelector(&selector.LabelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid adopt label selector: %w", err)
+	}
+
+	name, namespace := kustomization.GetName(), kustomization.GetNamespace()
+	nameKey := fmt.Sprintf("%s/name", kustomizev1.GroupVersion.Group)
+	namespaceKey := fmt.Sprintf("%s/namespace", kustomizev1.GroupVersion.Group)
+
+	var manifests []byte
+	adopted := 0
+	for _, kind := range selector.Kinds {
+		apiVersion := kind.APIVersion
+		if apiVersion == "" {
+			apiVersion = "v1"
+		}
+		gvk := schema.FromAPIVersionAndKind(apiVersion, kind.Kind+"List")
+
+		ulist := &unstructured.UnstructuredList{}
+		ulist.SetGroupVersionKind(gvk)
+		opts := []client.ListOption{client.MatchingLabelsSelector{Selector: labelSelector}}
+		if selector.Namespace != "" {
+			opts = append(opts, client.InNamespace(selector.Namespace))
+		}
+		if err := kubeClient.List(ctx, ulist, opts...); err != nil {
+			return nil, fmt.Errorf("unable to list %s objects to adopt: %w", kind.Kind, err)
+		}
+
+		for _, item := range ulist.Items {
+			if kind.Name != "" && item.GetName() != kind.Name {
+				continue
+			}
+			labels := item.GetLabels()
+			if owner, ok := labels[nameKey]; ok && (owner != name || labels[namespaceKey] != namespace) {
+				log.Info(fmt.Sprintf("Adoption skipped %s, managed by Kustomization %s/%s",
+					objectName(item), labels[namespaceKey], owner))
+				continue
+			}
+
+			patch := client.MergeFrom(item.DeepCopy())
+			if labels == nil {
+				labels = make(map[string]string)
+			}
+			for k, v := range gcLabels(name, namespace, checksum) {
+				labels[k] = v
+			}
+			item.SetLabels(labels)
+			if err := kubeClient.Patch(ctx, &item, patch); err != nil {
+				return nil, fmt.Errorf("unable to adopt %s: %w", objectName(item), err)
+			}
+			log.Info(fmt.Sprintf("Adopted %s", objectName(item)))
+
+			ref := map[string]interface{}{
+				"apiVersion": item.GetAPIVersion(),
+				"kind":       item.GetKind(),
+				"metadata": map[string]interface{}{
+					"name":      item.GetName(),
+					"namespace": item.GetNamespace(),
+				},
+			}
+			data, err := yaml.Marshal(ref)
+			if err != nil {
+				return nil, err
+			}
+			if len(manifests) > 0 {
+				manifests = append(manifests, []byte("---\n")...)
+			}
+			manifests = append(manifests, data...)
+			adopted++
+		}
+	}
+
+	log.Info(fmt.Sprintf("Adopted %d objects matching '%s'", adopted, labelSelector.String()))
+	return manifests, nil
+}
+
+// withAdoptedObjects returns the snapshot and inventory extended with the
+// adopted objects, the objects already in the inventory are not repeated.
+func withAdoptedObjects(snapshot *kustomizev1.Snapshot, inventory *kustomizev1.ResourceInventory,
+	manifests []byte) (*kustomizev1.Snapshot, *kustomizev1.ResourceInventory, error) {
+	adoptedSnapshot, err := kustomizev1.NewSnapshot(manifests, snapshot.Checksum)
+	if err != nil {
+		return nil, nil, err
+	}
+	adoptedInventory, err := kustomizev1.NewInventory(manifests)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := inventory.DeepCopy()
+	for _, entry := range adoptedInventory.Entries {
+		found := false
+		for _, e := range out.Entries {
+			if e.ID == entry.ID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out.Entries = append(out.Entries, entry)
+		}
+	}
+	return snapshot.WithEntriesOf(adoptedSnapshot), out, nil
+}
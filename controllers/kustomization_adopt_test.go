@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var _ = Describe("adoptObjects", func() {
+	var (
+		namespace     *corev1.Namespace
+		directClient  client.Client
+		kustomization kustomizev1.Kustomization
+	)
+
+	configMap := func(name string, labels map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace.Name, Labels: labels},
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		directClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+		Expect(err).NotTo(HaveOccurred())
+
+		namespace = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "adopt-" + randStringRunes(5)},
+		}
+		Expect(directClient.Create(context.Background(), namespace)).To(Succeed())
+
+		Expect(directClient.Create(context.Background(), configMap("legacy", map[string]string{"app": "legacy"}))).To(Succeed())
+		Expect(directClient.Create(context.Background(), configMap("other", map[string]string{"app": "other"}))).To(Succeed())
+		Expect(directClient.Create(context.Background(), configMap("managed", map[string]string{
+			"app":                                   "legacy",
+			"kustomize.toolkit.fluxcd.io/name":      "infra",
+			"kustomize.toolkit.fluxcd.io/namespace": "flux-system",
+		}))).To(Succeed())
+
+		kustomization = kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "flux-system"},
+			Spec: kustomizev1.KustomizationSpec{
+				AdoptSelector: &kustomizev1.AdoptSelector{
+					Kinds:         []kustomizev1.ResourceKindReference{{Kind: "ConfigMap"}},
+					LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "legacy"}},
+					Namespace:     namespace.Name,
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		Expect(directClient.Delete(context.Background(), namespace)).To(Succeed())
+	})
+
+	It("labels the selected objects for garbage collection", func() {
+		r := &KustomizationReconciler{}
+		manifests, err := r.adoptObjects(context.Background(), directClient, kustomization, "abc")
+		Expect(err).NotTo(HaveOccurred())
+
+		var cm corev1.ConfigMap
+		Expect(directClient.Get(context.Background(), types.NamespacedName{Name: "legacy", Namespace: namespace.Name}, &cm)).To(Succeed())
+		Expect(cm.GetLabels()).To(HaveKeyWithValue("kustomize.toolkit.fluxcd.io/name", "apps"))
+		Expect(cm.GetLabels()).To(HaveKeyWithValue("kustomize.toolkit.fluxcd.io/checksum", "abc"))
+
+		Expect(directClient.Get(context.Background(), types.NamespacedName{Name: "managed", Namespace: namespace.Name}, &cm)).To(Succeed())
+		Expect(cm.GetLabels()).To(HaveKeyWithValue("kustomize.toolkit.fluxcd.io/name", "infra"))
+
+		snapshot, inventory, err := withAdoptedObjects(&kustomizev1.Snapshot{Checksum: "abc", Entries: []kustomizev1.SnapshotEntry{}},
+			&kustomizev1.ResourceInventory{Entries: []kustomizev1.ResourceRef{}}, manifests)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(inventory.Entries).To(Equal([]kustomizev1.ResourceRef{{ID: namespace.Name + "_legacy__ConfigMap", Version: "v1"}}))
+		Expect(snapshot.Entries).To(HaveLen(1))
+		Expect(snapshot.Entries[0].Namespace).To(Equal(namespace.Name))
+	})
+
+	It("adopts only at the first reconciliation", func() {
+		kustomization.Status.LastAppliedRevision = "main/abc"
+		r := &KustomizationReconciler{}
+		manifests, err := r.adoptObjects(context.Background(), directClient, kustomization, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifests).To(BeEmpty())
+
+		var cm corev1.ConfigMap
+		Expect(directClient.Get(context.Background(), types.NamespacedName{Name: "legacy", Namespace: namespace.Name}, &cm)).To(Succeed())
+		Expect(cm.GetLabels()).NotTo(HaveKey("kustomize.toolkit.fluxcd.io/name"))
+	})
+})
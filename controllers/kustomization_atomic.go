@@ -0,0 +1,74 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// dryRunAll runs a server-side dry-run apply of all the objects of the
+// manifests file, regardless of the validation mode, and returns an error
+// listing the objects rejected by the API server if any.
+func (r *KustomizationReconciler) dryRunAll(ctx context.Context, kustomization kustomizev1.Kustomization,
+	imp *KustomizeImpersonation, dirPath string) error {
+	manifestsFile := fmt.Sprintf("%s.yaml", kustomization.GetUID())
+	if isEmptyFile(filepath.Join(dirPath, manifestsFile)) {
+		return nil
+	}
+
+	timeout := kustomization.GetTimeout() + (time.Second * 1)
+	applyCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := fmt.Sprintf("cd %s && kubectl apply -f %s --timeout=%s --dry-run=server --cache-dir=/tmp",
+		dirPath, manifestsFile, kustomization.GetTimeout().String())
+
+	if kustomization.Spec.KubeConfig != nil {
+		kubeConfig, err := imp.WriteKubeConfig(ctx)
+		if err != nil {
+			return err
+		}
+		cmd = fmt.Sprintf("%s --kubeconfig=%s", cmd, kubeConfig)
+	}
+
+	command := exec.CommandContext(applyCtx, "/bin/sh", "-c", cmd)
+	output, err := command.CombinedOutput()
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("dry-run timeout: %w", err)
+		}
+		failures := parseDryRunFailures(output)
+		if len(failures) == 0 {
+			return fmt.Errorf("dry-run failed, no objects were applied: %s", string(output))
+		}
+		return fmt.Errorf("dry-run failed for %d objects, no objects were applied: %s",
+			len(failures), strings.Join(failures, "; "))
+	}
+
+	(logr.FromContext(ctx)).V(1).Info("Dry-run succeeded for all objects")
+	return nil
+}
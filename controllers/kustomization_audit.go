@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+	"github.com/fluxcd/kustomize-controller/internal/audit"
+)
+
+type auditContextKey struct{}
+
+// auditRecorder collects the changes made by a reconciliation,
+// the objects may be applied concurrently.
+type auditRecorder struct {
+	mu    sync.Mutex
+	entry audit.Entry
+}
+
+// withAuditRecorder returns a context that collects the changes made with it.
+func withAuditRecorder(ctx context.Context, kustomization kustomizev1.Kustomization,
+	revision, identity string) (context.Context, *auditRecorder) {
+	rec := &auditRecorder{entry: audit.Entry{
+		Kustomization: fmt.Sprintf("%s/%s", kustomization.GetNamespace(), kustomization.GetName()),
+		Revision:      revision,
+		Identity:      identity,
+	}}
+	return context.WithValue(ctx, auditContextKey{}, rec), rec
+}
+
+// auditRecorderFrom returns the recorder of the context, or nil.
+func auditRecorderFrom(ctx context.Context) *auditRecorder {
+	rec, _ := ctx.Value(auditContextKey{}).(*auditRecorder)
+	return rec
+}
+
+// applied records the objects created or configured by kubectl apply.
+func (a *auditRecorder) applied(resources map[string]string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for obj, action := range resources {
+		switch action {
+		case "created":
+			a.entry.Created = append(a.entry.Created, obj)
+		case "configured":
+			a.entry.Updated = append(a.entry.Updated, obj)
+		}
+	}
+}
+
+// pruned records the objects deleted by the garbage collector.
+func (a *auditRecorder) pruned(objects []string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entry.Deleted = append(a.entry.Deleted, objects...)
+}
+
+// auditIdentity returns the identity the objects are applied with.
+func auditIdentity(kustomization kustomizev1.Kustomization, imp *KustomizeImpersonation) string {
+	if kustomization.Spec.KubeConfig != nil {
+		return fmt.Sprintf("kubeconfig:%s/%s", kustomization.GetNamespace(), kustomization.Spec.KubeConfig.SecretRef.Name)
+	}
+	if sa := imp.ServiceAccountName(); sa != "" {
+		return fmt.Sprintf("system:serviceaccount:%s:%s", kustomization.GetNamespace(), sa)
+	}
+	return "kustomize-controller"
+}
+
+// recordAudit writes the changes collected by the recorder to the audit log.
+func (r *KustomizationReconciler) recordAudit(ctx context.Context, rec *auditRecorder) {
+	if r.AuditLogger == nil {
+		return
+	}
+	rec.mu.Lock()
+	entry := rec.entry
+	rec.mu.Unlock()
+
+	entry.Timestamp = time.Now().UTC()
+	sort.Strings(entry.Created)
+	sort.Strings(entry.Updated)
+	sort.Strings(entry.Deleted)
+	if err := r.AuditLogger.Record(entry); err != nil {
+		(logr.FromContext(ctx)).Error(err, "unable to write the audit log entry")
+	}
+}
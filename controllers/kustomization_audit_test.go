@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+	"github.com/fluxcd/kustomize-controller/internal/audit"
+)
+
+var _ = Describe("recordAudit", func() {
+	It("writes the changes of the reconciliation as a JSON line", func() {
+		kustomization := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "flux-system"},
+			Spec:       kustomizev1.KustomizationSpec{ServiceAccountName: "reconciler"},
+		}
+		imp := NewKustomizeImpersonation(kustomization, nil, nil, "", "", "")
+
+		var buf bytes.Buffer
+		r := &KustomizationReconciler{AuditLogger: audit.NewLogger(&buf)}
+		ctx, rec := withAuditRecorder(context.Background(), kustomization, "main/abc", auditIdentity(kustomization, imp))
+		auditRecorderFrom(ctx).applied(map[string]string{
+			"secret/db-credentials":   "configured",
+			"deployment.apps/backend": "created",
+			"service/backend":         "unchanged",
+		})
+		auditRecorderFrom(ctx).pruned([]string{"ConfigMap/apps/legacy"})
+		r.recordAudit(ctx, rec)
+
+		Expect(bytes.Count(buf.Bytes(), []byte("\n"))).To(Equal(1))
+		var entry audit.Entry
+		Expect(json.Unmarshal(buf.Bytes(), &entry)).To(Succeed())
+		Expect(entry.Kustomization).To(Equal("flux-system/apps"))
+		Expect(entry.Revision).To(Equal("main/abc"))
+		Expect(entry.Identity).To(Equal("system:serviceaccount:flux-system:reconciler"))
+		Expect(entry.Created).To(Equal([]string{"deployment.apps/backend"}))
+		Expect(entry.Updated).To(Equal([]string{"secret/db-credentials"}))
+		Expect(entry.Deleted).To(Equal([]string{"ConfigMap/apps/legacy"}))
+		Expect(entry.Timestamp.IsZero()).To(BeFalse())
+	})
+
+	It("ignores the contexts without a recorder", func() {
+		Expect(func() {
+			auditRecorderFrom(context.Background()).applied(map[string]string{"service/backend": "created"})
+		}).NotTo(Panic())
+	})
+})
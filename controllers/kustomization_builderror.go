@@ -0,0 +1,167 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+// maxInvalidManifests is the maximum number of invalid manifests
+// reported in a build error.
+const maxInvalidManifests = 5
+
+var yamlLineRegexp = regexp.MustCompile(`line (\d+): (.*)$`)
+
+// buildError is a kustomize build error whose message refers to the files
+// relative to the artifact root, with the location of the invalid manifests.
+type buildError struct {
+	msg string
+	err error
+}
+
+func (e *buildError) Error() string {
+	return e.msg
+}
+
+func (e *buildError) Unwrap() error {
+	return e.err
+}
+
+// explainBuildError makes the paths of a build error relative to the artifact
+// root and, for YAML errors, adds the file and line of the manifests that fail
+// to parse. When the error names some of the invalid files, only those are
+// reported.
+func explainBuildError(buildErr error, fs filesys.FileSystem, rootPath, dirPath string) error {
+	msg := strings.ReplaceAll(buildErr.Error(), rootPath+string(filepath.Separator), "")
+	if !strings.Contains(msg, "yaml: ") {
+		return &buildError{msg: msg, err: buildErr}
+	}
+
+	invalid, err := invalidManifests(fs, rootPath)
+	if err != nil || len(invalid) == 0 {
+		return &buildError{msg: msg, err: buildErr}
+	}
+
+	var named []manifestError
+	for _, m := range invalid {
+		if strings.Contains(msg, m.path) || strings.Contains(msg, filepath.Base(m.path)) {
+			named = append(named, m)
+		}
+	}
+	if len(named) > 0 {
+		invalid = named
+	}
+	if len(invalid) > maxInvalidManifests {
+		invalid = invalid[:maxInvalidManifests]
+	}
+
+	locations := make([]string, 0, len(invalid))
+	for _, m := range invalid {
+		locations = append(locations, m.String())
+	}
+	return &buildError{
+		msg: fmt.Sprintf("%s; invalid manifests: %s", msg, strings.Join(locations, "; ")),
+		err: buildErr,
+	}
+}
+
+// manifestError is the location of a YAML parse error.
+type manifestError struct {
+	path string
+	line int
+	msg  string
+}
+
+func (m manifestError) String() string {
+	if m.line > 0 {
+		return fmt.Sprintf("%s:%d: %s", m.path, m.line, m.msg)
+	}
+	return fmt.Sprintf("%s: %s", m.path, m.msg)
+}
+
+// invalidManifests returns the YAML files under rootPath that fail to parse,
+// with the line of the error in the file. The paths are relative to rootPath.
+func invalidManifests(fs filesys.FileSystem, rootPath string) ([]manifestError, error) {
+	var invalid []manifestError
+	err := fs.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if strings.HasPrefix(info.Name(), ".") && path != rootPath {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ext := filepath.Ext(path); ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+
+		data, err := fs.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(rootPath, path)
+		if err != nil {
+			return err
+		}
+		if m := parseManifest(data); m != nil {
+			m.path = rel
+			invalid = append(invalid, *m)
+		}
+		return nil
+	})
+	return invalid, err
+}
+
+// parseManifest returns the first parse error of the YAML documents of a
+// manifest, with the line number in the manifest, or nil if it is valid.
+func parseManifest(data []byte) *manifestError {
+	lines := strings.Split(string(data), "\n")
+	start := 0
+	for i := 0; i <= len(lines); i++ {
+		if i < len(lines) && !isDocumentSeparator(lines[i]) {
+			continue
+		}
+		doc := strings.Join(lines[start:i], "\n")
+		var obj interface{}
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			m := &manifestError{msg: err.Error()}
+			if match := yamlLineRegexp.FindStringSubmatch(err.Error()); match != nil {
+				line, _ := strconv.Atoi(match[1])
+				m.line = start + line
+				m.msg = match[2]
+			}
+			return m
+		}
+		start = i + 1
+	}
+	return nil
+}
+
+// isDocumentSeparator returns true if the line separates two YAML documents.
+func isDocumentSeparator(line string) bool {
+	return line == "---" || strings.HasPrefix(line, "--- ")
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/api/filesys"
+)
+
+var _ = Describe("explainBuildError", func() {
+	var fs filesys.FileSystem
+
+	BeforeEach(func() {
+		fs = filesys.MakeFsInMemory()
+		Expect(fs.MkdirAll("/repo/app")).To(Succeed())
+		Expect(fs.WriteFile("/repo/app/kustomization.yaml", []byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- configmap.yaml
+- deployment.yaml
+`))).To(Succeed())
+		Expect(fs.WriteFile("/repo/app/configmap.yaml", []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+`))).To(Succeed())
+		Expect(fs.WriteFile("/repo/app/deployment.yaml", []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  labels: app: web
+`))).To(Succeed())
+	})
+
+	It("adds the file and line of the invalid manifests", func() {
+		buildErr := errors.New("kustomize build failed: accumulating resources: yaml: line 5: mapping values are not allowed in this context")
+		err := explainBuildError(buildErr, fs, "/repo", "/repo/app")
+		Expect(errors.Is(err, buildErr)).To(BeTrue())
+		Expect(err.Error()).To(HaveSuffix("invalid manifests: app/deployment.yaml:10: mapping values are not allowed in this context"))
+	})
+
+	It("makes the paths relative to the artifact root", func() {
+		buildErr := errors.New("kustomize build failed: accumulating resources from '/repo/app/missing.yaml': file not found")
+		err := explainBuildError(buildErr, fs, "/repo", "/repo/app")
+		Expect(errors.Is(err, buildErr)).To(BeTrue())
+		Expect(err.Error()).To(Equal("kustomize build failed: accumulating resources from 'app/missing.yaml': file not found"))
+	})
+
+	It("reports only the invalid manifests named in the error", func() {
+		Expect(fs.WriteFile("/repo/app/other.yaml", []byte("key: [value\n"))).To(Succeed())
+		buildErr := errors.New("MalformedYAMLError: yaml: line 5: mapping values are not allowed in this context in File: deployment.yaml")
+		err := explainBuildError(buildErr, fs, "/repo", "/repo/app")
+		Expect(err.Error()).To(ContainSubstring("app/deployment.yaml:10"))
+		Expect(err.Error()).NotTo(ContainSubstring("app/other.yaml"))
+	})
+})
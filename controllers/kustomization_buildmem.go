@@ -0,0 +1,155 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/kustomize/api/filesys"
+)
+
+// buildHeapLimiter cancels the kustomize builds in progress when the
+// controller heap grows past the heap limit. The heap is shared by all the
+// reconciliations, the limiter protects the controller from running out of
+// memory, it doesn't measure the memory used by a single build.
+type buildHeapLimiter struct {
+	// heapLimit is the heap size in bytes at which the builds are cancelled, zero disables the limit.
+	heapLimit int64
+
+	// checkInterval is the interval at which the heap size is sampled.
+	checkInterval time.Duration
+}
+
+// guard returns a file system for the builds of a reconciliation and a
+// function releasing it. Once the heap exceeds the limit, every operation
+// of the returned file system fails, which cancels the builds reading it at
+// their next file access, in the calling goroutine. The release function
+// must be called when the builds are done, it is safe to call it more than once.
+func (l *buildHeapLimiter) guard(fs filesys.FileSystem) (filesys.FileSystem, func()) {
+	if l == nil || l.heapLimit <= 0 {
+		return fs, func() {}
+	}
+
+	lfs := &heapLimitedFS{FileSystem: fs, done: make(chan struct{})}
+	go func() {
+		ticker := time.NewTicker(l.checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-lfs.done:
+				return
+			case <-ticker.C:
+				var stats runtime.MemStats
+				runtime.ReadMemStats(&stats)
+				if heap := int64(stats.HeapAlloc); heap > l.heapLimit {
+					lfs.abort(fmt.Errorf("kustomize build cancelled, the controller heap of %s exceeds the build heap limit of %s",
+						resource.NewQuantity(heap, resource.BinarySI).String(),
+						resource.NewQuantity(l.heapLimit, resource.BinarySI).String()))
+					return
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return lfs, func() { once.Do(func() { close(lfs.done) }) }
+}
+
+// heapLimitedFS is a file system whose operations fail once aborted.
+type heapLimitedFS struct {
+	filesys.FileSystem
+
+	done chan struct{}
+
+	mu  sync.RWMutex
+	err error
+}
+
+func (fs *heapLimitedFS) abort(err error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.err = err
+}
+
+// Err returns the error the file system was aborted with, if any.
+func (fs *heapLimitedFS) Err() error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	return fs.err
+}
+
+func (fs *heapLimitedFS) Create(path string) (filesys.File, error) {
+	if err := fs.Err(); err != nil {
+		return nil, err
+	}
+	return fs.FileSystem.Create(path)
+}
+
+func (fs *heapLimitedFS) Open(path string) (filesys.File, error) {
+	if err := fs.Err(); err != nil {
+		return nil, err
+	}
+	return fs.FileSystem.Open(path)
+}
+
+func (fs *heapLimitedFS) ReadFile(path string) ([]byte, error) {
+	if err := fs.Err(); err != nil {
+		return nil, err
+	}
+	return fs.FileSystem.ReadFile(path)
+}
+
+func (fs *heapLimitedFS) WriteFile(path string, data []byte) error {
+	if err := fs.Err(); err != nil {
+		return err
+	}
+	return fs.FileSystem.WriteFile(path, data)
+}
+
+func (fs *heapLimitedFS) Glob(pattern string) ([]string, error) {
+	if err := fs.Err(); err != nil {
+		return nil, err
+	}
+	return fs.FileSystem.Glob(pattern)
+}
+
+func (fs *heapLimitedFS) Walk(path string, walkFn filepath.WalkFunc) error {
+	if err := fs.Err(); err != nil {
+		return err
+	}
+	return fs.FileSystem.Walk(path, walkFn)
+}
+
+// heapLimitError returns the error the file system was aborted with when
+// err is not nil, so that the build failures caused by the heap limit are
+// reported as such instead of as missing or unreadable files.
+func heapLimitError(fs filesys.FileSystem, err error) error {
+	if err == nil {
+		return nil
+	}
+	if lfs, ok := fs.(*heapLimitedFS); ok {
+		if lerr := lfs.Err(); lerr != nil {
+			return lerr
+		}
+	}
+	return err
+}
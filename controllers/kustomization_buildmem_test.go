@@ -0,0 +1,85 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/api/filesys"
+)
+
+var _ = Describe("buildHeapLimiter", func() {
+	writeKustomization := func(fs filesys.FileSystem, dirPath string) {
+		Expect(fs.WriteFile(filepath.Join(dirPath, "kustomization.yaml"), []byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- configmap.yaml
+`))).To(Succeed())
+		Expect(fs.WriteFile(filepath.Join(dirPath, "configmap.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+`))).To(Succeed())
+	}
+
+	It("returns the file system unchanged without a limit", func() {
+		fs := filesys.MakeFsInMemory()
+		l := &buildHeapLimiter{checkInterval: time.Millisecond}
+		guarded, release := l.guard(fs)
+		defer release()
+		Expect(guarded).To(BeIdenticalTo(fs))
+	})
+
+	It("cancels the builds when the heap exceeds the limit", func() {
+		fs := filesys.MakeFsInMemory()
+		Expect(fs.MkdirAll("/app")).To(Succeed())
+		writeKustomization(fs, "/app")
+
+		l := &buildHeapLimiter{heapLimit: 1, checkInterval: time.Millisecond}
+		guarded, release := l.guard(fs)
+		defer release()
+
+		Eventually(func() error {
+			_, err := guarded.ReadFile("/app/configmap.yaml")
+			return err
+		}, time.Second, time.Millisecond).Should(MatchError(ContainSubstring("exceeds the build heap limit of 1")))
+
+		_, err := buildKustomization(guarded, "/app")
+		Expect(err).To(MatchError(ContainSubstring("kustomize build cancelled")))
+		Expect(heapLimitError(guarded, errors.New("missing kustomization.yaml"))).
+			To(MatchError(ContainSubstring("kustomize build cancelled")))
+	})
+
+	It("builds within the limit", func() {
+		fs := filesys.MakeFsInMemory()
+		Expect(fs.MkdirAll("/app")).To(Succeed())
+		writeKustomization(fs, "/app")
+
+		l := &buildHeapLimiter{heapLimit: 1 << 40, checkInterval: time.Millisecond}
+		guarded, release := l.guard(fs)
+		m, err := buildKustomization(guarded, "/app")
+		release()
+		release()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Resources()).To(HaveLen(1))
+		Expect(heapLimitError(guarded, nil)).To(BeNil())
+	})
+})
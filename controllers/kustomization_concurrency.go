@@ -0,0 +1,164 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// applyTiers lists the kinds that must be applied before the other objects,
+// in order. The kinds missing from the list are applied in the tier before
+// the last one, the last tier holds the admission webhooks.
+var applyTiers = [][]string{
+	{"Namespace", "ResourceQuota", "StorageClass", "CustomResourceDefinition"},
+	{"ServiceAccount", "PodSecurityPolicy", "Role", "ClusterRole", "RoleBinding", "ClusterRoleBinding",
+		"ConfigMap", "Secret", "Endpoints", "Service", "LimitRange", "PriorityClass",
+		"PersistentVolume", "PersistentVolumeClaim"},
+	nil,
+	{"MutatingWebhookConfiguration", "ValidatingWebhookConfiguration"},
+}
+
+// applyTier returns the index of the tier of the kind in applyTiers.
+func applyTier(kind string) int {
+	for i, kinds := range applyTiers {
+		for _, k := range kinds {
+			if k == kind {
+				return i
+			}
+		}
+	}
+	return len(applyTiers) - 2
+}
+
+// checkApplyOptions returns an error when the Kustomization sets both
+// ApplyConcurrency and ApplyBatchSize, the concurrent apply runs all the
+// tiers in one reconciliation and can't resume from the apply progress.
+func checkApplyOptions(kustomization kustomizev1.Kustomization) error {
+	if kustomization.Spec.ApplyConcurrency > 1 && kustomization.Spec.ApplyBatchSize > 0 {
+		return fmt.Errorf("applyConcurrency and applyBatchSize are mutually exclusive")
+	}
+	return nil
+}
+
+// applyConcurrently applies the tiers of objects in order, the objects of
+// the same tier are split evenly between up to ApplyConcurrency workers
+// applying them in parallel. The errors of all the failed batches of a tier
+// are returned.
+func (r *KustomizationReconciler) applyConcurrently(ctx context.Context, kustomization kustomizev1.Kustomization,
+	imp *KustomizeImpersonation, dirPath, manifestsFile string) (string, error) {
+	tiers, total, err := splitManifestsInTiers(dirPath, manifestsFile, kustomization.Spec.ApplyConcurrency)
+	if err != nil {
+		return "", fmt.Errorf("splitting manifests in tiers failed: %w", err)
+	}
+
+	changeSet := ""
+	applied := 0
+	for _, batches := range tiers {
+		if err := ctx.Err(); err != nil {
+			return "", fmt.Errorf("apply aborted after %d of %d objects: %w", applied, total, err)
+		}
+
+		var (
+			mu     sync.Mutex
+			wg     sync.WaitGroup
+			failed []string
+		)
+		sem := make(chan struct{}, kustomization.Spec.ApplyConcurrency)
+		for _, batch := range batches {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(batch manifestsBatch) {
+				defer func() {
+					<-sem
+					wg.Done()
+				}()
+				batchChangeSet, err := r.applyManifests(ctx, kustomization, imp, dirPath, batch.file)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					failed = append(failed, err.Error())
+					return
+				}
+				changeSet += batchChangeSet
+				applied += batch.size
+			}(batch)
+		}
+		wg.Wait()
+
+		if len(failed) > 0 {
+			return "", fmt.Errorf("%d of %d batches failed (applied %d of %d objects): %s",
+				len(failed), len(batches), applied, total, strings.Join(failed, "; "))
+		}
+	}
+	return changeSet, nil
+}
+
+// splitManifestsInTiers groups the objects of the multi-doc YAML manifests
+// file by apply tier, and writes the objects of each tier in one file per worker.
+// It returns the batches of the non-empty tiers, in order, and the total
+// number of objects.
+func splitManifestsInTiers(dirPath, manifestsFile string, workers int) ([][]manifestsBatch, int, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dirPath, manifestsFile))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	manifests, err := parseManifests(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	docs := make([][]manifest, len(applyTiers))
+	for _, m := range manifests {
+		tier := applyTier(m.object.GetKind())
+		docs[tier] = append(docs[tier], m)
+	}
+
+	base := strings.TrimSuffix(manifestsFile, filepath.Ext(manifestsFile))
+	var tiers [][]manifestsBatch
+	for i, tierDocs := range docs {
+		if len(tierDocs) == 0 {
+			continue
+		}
+		size := (len(tierDocs) + workers - 1) / workers
+
+		var batches []manifestsBatch
+		for j := 0; j < len(tierDocs); j += size {
+			end := j + size
+			if end > len(tierDocs) {
+				end = len(tierDocs)
+			}
+			batch := manifestsBatch{
+				file: fmt.Sprintf("%s.tier-%d-%d.yaml", base, i, len(batches)),
+				size: end - j,
+			}
+			if err := ioutil.WriteFile(filepath.Join(dirPath, batch.file), joinManifests(tierDocs[j:end]), os.ModePerm); err != nil {
+				return nil, 0, err
+			}
+			batches = append(batches, batch)
+		}
+		tiers = append(tiers, batches)
+	}
+	return tiers, len(manifests), nil
+}
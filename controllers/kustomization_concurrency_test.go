@@ -0,0 +1,99 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var _ = Describe("splitManifestsInTiers", func() {
+	var dirPath string
+
+	BeforeEach(func() {
+		var err error
+		dirPath, err = ioutil.TempDir("", "split-tiers")
+		Expect(err).NotTo(HaveOccurred())
+
+		manifests := ""
+		for _, kind := range []string{"Deployment", "Namespace", "ConfigMap", "ValidatingWebhookConfiguration"} {
+			for i := 0; i < 3; i++ {
+				manifests += fmt.Sprintf("---\napiVersion: v1\nkind: %s\nmetadata:\n  name: %s-%d\n", kind, kind, i)
+			}
+		}
+		Expect(ioutil.WriteFile(filepath.Join(dirPath, "manifests.yaml"), []byte(manifests), 0644)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dirPath)
+	})
+
+	sizes := func(tiers [][]manifestsBatch) [][]int {
+		var result [][]int
+		for _, batches := range tiers {
+			var tier []int
+			for _, batch := range batches {
+				tier = append(tier, batch.size)
+			}
+			result = append(result, tier)
+		}
+		return result
+	}
+
+	It("groups the objects by tier and splits them between the workers", func() {
+		tiers, total, err := splitManifestsInTiers(dirPath, "manifests.yaml", 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(total).To(Equal(12))
+		Expect(sizes(tiers)).To(Equal([][]int{{2, 1}, {2, 1}, {2, 1}, {2, 1}}))
+
+		data, err := ioutil.ReadFile(filepath.Join(dirPath, tiers[0][0].file))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring("kind: Namespace"))
+		data, err = ioutil.ReadFile(filepath.Join(dirPath, tiers[2][1].file))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring("name: Deployment-2"))
+	})
+
+	It("splits the tiers between the workers", func() {
+		tiers, _, err := splitManifestsInTiers(dirPath, "manifests.yaml", 4)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sizes(tiers)).To(Equal([][]int{{1, 1, 1}, {1, 1, 1}, {1, 1, 1}, {1, 1, 1}}))
+	})
+})
+
+var _ = Describe("checkApplyOptions", func() {
+	It("rejects the concurrent apply in batches", func() {
+		k := kustomizev1.Kustomization{
+			Spec: kustomizev1.KustomizationSpec{ApplyConcurrency: 4, ApplyBatchSize: 100},
+		}
+		Expect(checkApplyOptions(k)).To(MatchError("applyConcurrency and applyBatchSize are mutually exclusive"))
+
+		k.Spec.ApplyConcurrency = 1
+		Expect(checkApplyOptions(k)).To(Succeed())
+
+		k.Spec.ApplyConcurrency = 4
+		k.Spec.ApplyBatchSize = 0
+		Expect(checkApplyOptions(k)).To(Succeed())
+	})
+})
@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/fluxcd/pkg/runtime/events"
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// checkOwnershipConflicts reports the objects of the manifests file managed by
+// another Kustomization, and returns an error when the OwnershipConflictPolicy
+// is 'Fail'.
+func (r *KustomizationReconciler) checkOwnershipConflicts(ctx context.Context, kubeClient client.Client,
+	kustomization kustomizev1.Kustomization, revision, dirPath string) error {
+	manifests, err := ioutil.ReadFile(filepath.Join(dirPath, fmt.Sprintf("%s.yaml", kustomization.GetUID())))
+	if err != nil {
+		return err
+	}
+
+	conflicts, err := ownershipConflicts(ctx, kubeClient, manifests, kustomization.GetName(), kustomization.GetNamespace())
+	if err != nil {
+		return fmt.Errorf("ownership conflicts lookup failed: %w", err)
+	}
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	msg := fmt.Sprintf("objects managed by another Kustomization: %s", strings.Join(conflicts, ", "))
+	if kustomization.Spec.OwnershipConflictPolicy == kustomizev1.OwnershipConflictFail {
+		return errors.New(msg)
+	}
+
+	(logr.FromContext(ctx)).Info(msg)
+	r.event(ctx, kustomization, revision, events.EventSeverityError, msg, nil)
+	return nil
+}
+
+// ownershipConflicts returns the objects of the multi-doc YAML that exist on
+// the cluster with the labels of another Kustomization, in the
+// '<kind>/<namespace>/<name> (Kustomization <namespace>/<name>)' format.
+func ownershipConflicts(ctx context.Context, kubeClient client.Client, manifests []byte, name, namespace string) ([]string, error) {
+	nameKey := fmt.Sprintf("%s/name", kustomizev1.GroupVersion.Group)
+	namespaceKey := fmt.Sprintf("%s/namespace", kustomizev1.GroupVersion.Group)
+
+	objects, err := parseManifests(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []string
+	for _, m := range objects {
+		obj := m.object
+		if obj.Object == nil {
+			continue
+		}
+
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		err := kubeClient.Get(ctx, types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}, live)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to get %s: %w", objectName(obj), err)
+		}
+
+		labels := live.GetLabels()
+		owner, ok := labels[nameKey]
+		if !ok {
+			continue
+		}
+		if owner != name || labels[namespaceKey] != namespace {
+			conflicts = append(conflicts, fmt.Sprintf("%s (Kustomization %s/%s)",
+				objectName(obj), labels[namespaceKey], owner))
+		}
+	}
+	return conflicts, nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("ownershipConflicts", func() {
+	var (
+		namespace    *corev1.Namespace
+		directClient client.Client
+	)
+
+	BeforeEach(func() {
+		var err error
+		directClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+		Expect(err).NotTo(HaveOccurred())
+
+		namespace = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "conflicts-" + randStringRunes(5)},
+		}
+		Expect(directClient.Create(context.Background(), namespace)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(directClient.Delete(context.Background(), namespace)).To(Succeed())
+	})
+
+	It("reports the objects managed by another Kustomization", func() {
+		for name, owner := range map[string]string{"owned": "apps", "other": "infra"} {
+			Expect(directClient.Create(context.Background(), &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: namespace.Name,
+					Labels:    selectorLabels(owner, "flux-system"),
+				},
+			})).To(Succeed())
+		}
+		Expect(directClient.Create(context.Background(), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "unmanaged", Namespace: namespace.Name},
+		})).To(Succeed())
+
+		var manifests string
+		for _, name := range []string{"owned", "other", "unmanaged", "new"} {
+			manifests += fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %s
+  namespace: %s
+`, name, namespace.Name)
+		}
+
+		conflicts, err := ownershipConflicts(context.Background(), directClient, []byte(manifests), "apps", "flux-system")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conflicts).To(Equal([]string{
+			fmt.Sprintf("ConfigMap/%s/other (Kustomization flux-system/infra)", namespace.Name),
+		}))
+	})
+})
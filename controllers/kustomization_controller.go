@@ -21,7 +21,6 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -53,32 +52,80 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/resmap"
 
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+	"github.com/fluxcd/kustomize-controller/internal/audit"
+	kmetrics "github.com/fluxcd/kustomize-controller/internal/metrics"
 )
 
 // +kubebuilder:rbac:groups=kustomize.toolkit.fluxcd.io,resources=kustomizations,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=kustomize.toolkit.fluxcd.io,resources=kustomizations/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=kustomize.toolkit.fluxcd.io,resources=kustomizations/finalizers,verbs=get;create;update;patch;delete
 // +kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=buckets;gitrepositories,verbs=get;list;watch
+// +kubebuilder:rbac:groups=helm.toolkit.fluxcd.io,resources=helmreleases,verbs=get;list;watch
 // +kubebuilder:rbac:groups=source.toolkit.fluxcd.io,resources=buckets/status;gitrepositories/status,verbs=get
-// +kubebuilder:rbac:groups="",resources=secrets;serviceaccounts,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps;namespaces;secrets;serviceaccounts,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
+// applyBatchInterval is the delay before the next batch of objects is applied.
+const applyBatchInterval = time.Second
+
 // KustomizationReconciler reconciles a Kustomization object
 type KustomizationReconciler struct {
 	client.Client
 	requeueDependency     time.Duration
+	defaultServiceAccount string
+	requiredLabels        []string
+	requiredAnnotations   []string
+	reconcileBudget       time.Duration
+	defaultInterval       time.Duration
+	defaultTimeout        time.Duration
+	minInterval           time.Duration
+	downloadTimeout       time.Duration
+	downloadRetries       int
+	downloadBackoff       time.Duration
+	unreachableInterval   time.Duration
+	objectQuotas          []ObjectQuota
+	buildHeapLimiter      *buildHeapLimiter
+	readinessRulesRef     types.NamespacedName
+	apiReader             client.Reader
+	controller            controller.Controller
+	preconditionWatches   preconditionWatches
+	validatedManifests    validatedManifests
+	userAgent             string
+	maxScanDepth          int
+	enableArtifactURL     bool
 	Scheme                *runtime.Scheme
 	EventRecorder         kuberecorder.EventRecorder
 	ExternalEventRecorder *events.Recorder
 	MetricsRecorder       *metrics.Recorder
+	PruneMetricsRecorder  *kmetrics.PruneRecorder
+	AuditLogger           *audit.Logger
 	StatusPoller          *polling.StatusPoller
 }
 
 type KustomizationReconcilerOptions struct {
-	MaxConcurrentReconciles   int
-	DependencyRequeueInterval time.Duration
+	MaxConcurrentReconciles    int
+	DependencyRequeueInterval  time.Duration
+	DefaultServiceAccount      string
+	RequiredLabels             []string
+	RequiredAnnotations        []string
+	DefaultReconcileBudget     time.Duration
+	DefaultInterval            time.Duration
+	DefaultTimeout             time.Duration
+	MinInterval                time.Duration
+	ArtifactDownloadTimeout    time.Duration
+	ArtifactDownloadRetries    int
+	ClusterUnreachableInterval time.Duration
+	ObjectQuotas               []ObjectQuota
+	BuildHeapLimit             int64
+	ReadinessRulesConfigMap    string
+	RuntimeNamespace           string
+	MaxPreconditionWatches     int
+	UserAgent                  string
+	MaxScanDepth               int
+	EnableArtifactURL          bool
 }
 
 func (r *KustomizationReconciler) SetupWithManager(mgr ctrl.Manager, opts KustomizationReconcilerOptions) error {
@@ -94,9 +141,37 @@ func (r *KustomizationReconciler) SetupWithManager(mgr ctrl.Manager, opts Kustom
 		return fmt.Errorf("failed setting index fields: %w", err)
 	}
 
-	r.requeueDependency = opts.DependencyRequeueInterval
+	// Index the Kustomizations by the objects their watched preconditions reference.
+	if err := mgr.GetCache().IndexField(context.TODO(), &kustomizev1.Kustomization{}, kustomizev1.PreconditionIndexKey,
+		r.indexByPrecondition); err != nil {
+		return fmt.Errorf("failed setting index fields: %w", err)
+	}
 
-	return ctrl.NewControllerManagedBy(mgr).
+	r.requeueDependency = opts.DependencyRequeueInterval
+	r.defaultServiceAccount = opts.DefaultServiceAccount
+	r.requiredLabels = opts.RequiredLabels
+	r.requiredAnnotations = opts.RequiredAnnotations
+	r.reconcileBudget = opts.DefaultReconcileBudget
+	r.defaultInterval = opts.DefaultInterval
+	r.defaultTimeout = opts.DefaultTimeout
+	r.minInterval = opts.MinInterval
+	r.downloadTimeout = opts.ArtifactDownloadTimeout
+	r.downloadRetries = opts.ArtifactDownloadRetries
+	r.downloadBackoff = time.Second
+	r.unreachableInterval = opts.ClusterUnreachableInterval
+	r.objectQuotas = opts.ObjectQuotas
+	r.buildHeapLimiter = &buildHeapLimiter{
+		heapLimit:     opts.BuildHeapLimit,
+		checkInterval: 100 * time.Millisecond,
+	}
+	r.readinessRulesRef = parseReadinessRulesRef(opts.ReadinessRulesConfigMap, opts.RuntimeNamespace)
+	r.apiReader = mgr.GetAPIReader()
+	r.preconditionWatches = preconditionWatches{max: opts.MaxPreconditionWatches}
+	r.userAgent = opts.UserAgent
+	r.maxScanDepth = opts.MaxScanDepth
+	r.enableArtifactURL = opts.EnableArtifactURL
+
+	c, err := ctrl.NewControllerManagedBy(mgr).
 		For(&kustomizev1.Kustomization{}, builder.WithPredicates(
 			predicate.Or(predicate.GenerationChangedPredicate{}, predicates.ReconcileRequestedPredicate{}),
 		)).
@@ -111,7 +186,12 @@ func (r *KustomizationReconciler) SetupWithManager(mgr ctrl.Manager, opts Kustom
 			builder.WithPredicates(SourceRevisionChangePredicate{}),
 		).
 		WithOptions(controller.Options{MaxConcurrentReconciles: opts.MaxConcurrentReconciles}).
-		Complete(r)
+		Build(r)
+	if err != nil {
+		return err
+	}
+	r.controller = c
+	return nil
 }
 
 func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -137,12 +217,44 @@ func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		return r.reconcileDelete(ctx, kustomization)
 	}
 
+	// set the controller defaults, the spec is not updated on the cluster
+	kustomization = r.applyIntervalDefaults(ctx, kustomization)
+
 	// Return early if the Kustomization is suspended.
 	if kustomization.Spec.Suspend {
 		log.Info("Reconciliation is suspended for this object")
 		return ctrl.Result{}, nil
 	}
 
+	// reject Kustomizations missing the required metadata
+	if err := r.checkRequiredMetadata(kustomization); err != nil {
+		kustomization = kustomizev1.KustomizationNotReady(
+			kustomization, "", kustomizev1.MissingRequiredMetadataReason, err.Error())
+		if err := r.patchStatus(ctx, req, kustomization.Status); err != nil {
+			log.Error(err, "unable to update status for missing required metadata")
+			return ctrl.Result{Requeue: true}, err
+		}
+		r.recordReadiness(ctx, kustomization)
+		log.Error(err, "Required metadata validation failed")
+		r.event(ctx, kustomization, "", events.EventSeverityError, err.Error(), nil)
+		// metadata changes do not trigger a reconciliation, requeue at the retry interval
+		return ctrl.Result{RequeueAfter: kustomization.GetRetryInterval()}, nil
+	}
+
+	// reject the Kustomizations without a usable source
+	if err := r.checkSourceSpec(kustomization); err != nil {
+		kustomization = kustomizev1.KustomizationNotReady(
+			kustomization, "", kustomizev1.ArtifactFailedReason, err.Error())
+		if err := r.patchStatus(ctx, req, kustomization.Status); err != nil {
+			log.Error(err, "unable to update status for invalid source")
+			return ctrl.Result{Requeue: true}, err
+		}
+		r.recordReadiness(ctx, kustomization)
+		log.Error(err, "Source validation failed")
+		r.event(ctx, kustomization, "", events.EventSeverityError, err.Error(), nil)
+		return ctrl.Result{RequeueAfter: kustomization.GetRetryInterval()}, nil
+	}
+
 	// resolve source reference
 	source, err := r.getSource(ctx, kustomization)
 	if err != nil {
@@ -162,6 +274,9 @@ func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 			return ctrl.Result{Requeue: true}, err
 		}
 	}
+	if s, ok := source.(*artifactURLSource); ok {
+		defer os.Remove(s.path)
+	}
 
 	if source.GetArtifact() == nil {
 		msg := "Source is not ready, artifact not found"
@@ -176,8 +291,39 @@ func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		return ctrl.Result{RequeueAfter: kustomization.GetRetryInterval()}, nil
 	}
 
+	// refuse to build any other revision than the pinned one, if any
+	if kustomization.Spec.SourceRevision != "" {
+		if err := checkPinnedRevision(source, kustomization.Spec.SourceRevision); err != nil {
+			kustomization = kustomizev1.KustomizationNotReady(kustomization, "", kustomizev1.PinnedRevisionNotFoundReason, err.Error())
+			meta.SetResourceCondition(&kustomization, kustomizev1.PinnedRevisionCondition, metav1.ConditionFalse,
+				kustomizev1.PinnedRevisionNotFoundReason, err.Error())
+			if err := r.patchStatus(ctx, req, kustomization.Status); err != nil {
+				log.Error(err, "unable to update status for pinned revision not found")
+				return ctrl.Result{Requeue: true}, err
+			}
+			r.recordReadiness(ctx, kustomization)
+			log.Info(err.Error())
+			r.event(ctx, kustomization, "", events.EventSeverityError, err.Error(), nil)
+			// the source watcher triggers a reconciliation when the source revision changes
+			return ctrl.Result{RequeueAfter: kustomization.GetRetryInterval()}, nil
+		}
+	}
+
+	// wait for the new revision to settle, skipping the superseded ones
+	if wait := sourceSettleWait(kustomization, source.GetArtifact(), time.Now()); wait > 0 {
+		kustomization.Status.PendingRevision = source.GetArtifact().Revision
+		if err := r.patchStatus(ctx, req, kustomization.Status); err != nil {
+			log.Error(err, "unable to update status for pending revision")
+			return ctrl.Result{Requeue: true}, err
+		}
+		log.Info(fmt.Sprintf("Revision %s is settling, building in %s",
+			source.GetArtifact().Revision, wait.Round(time.Second).String()))
+		return ctrl.Result{RequeueAfter: wait}, nil
+	}
+	kustomization.Status.PendingRevision = ""
+
 	// check dependencies
-	if len(kustomization.Spec.DependsOn) > 0 {
+	if len(kustomization.Spec.DependsOn) > 0 || len(kustomization.Spec.DependsOnHelmReleases) > 0 {
 		if err := r.checkDependencies(kustomization); err != nil {
 			kustomization = kustomizev1.KustomizationNotReady(
 				kustomization, source.GetArtifact().Revision, meta.DependencyNotReadyReason, err.Error())
@@ -196,6 +342,29 @@ func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		log.Info("All dependencies area ready, proceeding with reconciliation")
 	}
 
+	// check preconditions
+	if len(kustomization.Spec.Preconditions) > 0 {
+		r.watchPreconditions(ctx, kustomization)
+		imp := NewKustomizeImpersonation(kustomization, r.Client, r.StatusPoller, r.defaultServiceAccount, r.userAgent, "")
+		reader, err := imp.GetReader(ctx, r.apiReader)
+		if err == nil {
+			err = checkPreconditions(ctx, reader, kustomization)
+		}
+		if err != nil {
+			kustomization = kustomizev1.KustomizationNotReady(
+				kustomization, source.GetArtifact().Revision, kustomizev1.PreconditionNotMetReason, err.Error())
+			if err := r.patchStatus(ctx, req, kustomization.Status); err != nil {
+				log.Error(err, "unable to update status for precondition not met")
+				return ctrl.Result{Requeue: true}, err
+			}
+			msg := fmt.Sprintf("Preconditions are not met, retrying in %s", kustomization.GetRetryInterval().String())
+			log.Info(msg, "reason", err.Error())
+			r.event(ctx, kustomization, source.GetArtifact().Revision, events.EventSeverityInfo, msg, nil)
+			r.recordReadiness(ctx, kustomization)
+			return ctrl.Result{RequeueAfter: kustomization.GetRetryInterval()}, nil
+		}
+	}
+
 	// record reconciliation duration
 	if r.MetricsRecorder != nil {
 		objRef, err := reference.GetReference(r.Scheme, &kustomization)
@@ -205,6 +374,12 @@ func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 		defer r.MetricsRecorder.RecordDuration(*objRef, reconcileStart)
 	}
 
+	// keep the readiness of the last reconciliation for the failure grace
+	var lastReady *metav1.Condition
+	if c := apimeta.FindStatusCondition(kustomization.Status.Conditions, meta.ReadyCondition); c != nil {
+		lastReady = c.DeepCopy()
+	}
+
 	// set the reconciliation status to progressing
 	kustomization = kustomizev1.KustomizationProgressing(kustomization)
 	if err := r.patchStatus(ctx, req, kustomization.Status); err != nil {
@@ -215,22 +390,46 @@ func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 
 	// reconcile kustomization by applying the latest revision
 	reconciledKustomization, reconcileErr := r.reconcile(ctx, *kustomization.DeepCopy(), source)
+	reconciledKustomization = r.checkReconcileBudget(ctx, reconciledKustomization, time.Since(reconcileStart))
+	reconciledKustomization, withinGrace := checkFailureGrace(reconciledKustomization, lastReady, reconcileErr)
 	if err := r.patchStatus(ctx, req, reconciledKustomization.Status); err != nil {
 		log.Error(err, "unable to update status after reconciliation")
 		return ctrl.Result{Requeue: true}, err
 	}
 	r.recordReadiness(ctx, reconciledKustomization)
 
+	// requeue to apply the next batch, letting the other Kustomizations be reconciled in between
+	if progress := reconciledKustomization.Status.ApplyProgress; reconcileErr == nil && progress != nil {
+		log.Info(fmt.Sprintf("Applied %d of %d objects, applying the next batch in %s",
+			progress.Applied, progress.Total, applyBatchInterval.String()),
+			"revision",
+			source.GetArtifact().Revision)
+		return ctrl.Result{RequeueAfter: applyBatchInterval}, nil
+	}
+
 	// broadcast the reconciliation failure and requeue at the specified retry interval
 	if reconcileErr != nil {
+		retryInterval := requeueInterval(kustomization, reconcileErr)
+		if remoteClusterUnreachable(kustomization, reconcileErr) {
+			// back off while the remote cluster is down and report the outage only once
+			retryInterval = r.clusterUnreachableRetryInterval(kustomization)
+			if wasClusterUnreachable(lastReady) {
+				log.Info(fmt.Sprintf("Cluster still unreachable, next try in %s", retryInterval.String()),
+					"reason", reconcileErr.Error())
+				return ctrl.Result{RequeueAfter: retryInterval}, nil
+			}
+		}
 		log.Error(reconcileErr, fmt.Sprintf("Reconciliation failed after %s, next try in %s",
 			time.Now().Sub(reconcileStart).String(),
-			kustomization.GetRetryInterval().String()),
+			retryInterval.String()),
 			"revision",
 			source.GetArtifact().Revision)
-		r.event(ctx, reconciledKustomization, source.GetArtifact().Revision, events.EventSeverityError,
-			reconcileErr.Error(), nil)
-		return ctrl.Result{RequeueAfter: kustomization.GetRetryInterval()}, nil
+		severity := events.EventSeverityError
+		if withinGrace {
+			severity = events.EventSeverityInfo
+		}
+		r.event(ctx, reconciledKustomization, source.GetArtifact().Revision, severity, reconcileErr.Error(), nil)
+		return ctrl.Result{RequeueAfter: retryInterval}, nil
 	}
 
 	// broadcast the reconciliation result and requeue at the specified interval
@@ -242,7 +441,16 @@ func (r *KustomizationReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	)
 	r.event(ctx, reconciledKustomization, source.GetArtifact().Revision, events.EventSeverityInfo,
 		"Update completed", map[string]string{"commit_status": "update"})
-	return ctrl.Result{RequeueAfter: kustomization.Spec.Interval.Duration}, nil
+	return ctrl.Result{RequeueAfter: requeueInterval(kustomization, nil)}, nil
+}
+
+// requeueInterval returns the interval after which a reconciliation is requeued,
+// the retry interval when it failed and the interval when it succeeded.
+func requeueInterval(kustomization kustomizev1.Kustomization, reconcileErr error) time.Duration {
+	if reconcileErr != nil {
+		return kustomization.GetRetryInterval()
+	}
+	return kustomization.Spec.Interval.Duration
 }
 
 func (r *KustomizationReconciler) reconcile(
@@ -254,6 +462,24 @@ func (r *KustomizationReconciler) reconcile(
 		kustomization.Status.SetLastHandledReconcileRequest(v)
 	}
 
+	// record the pinned revision, if any
+	if kustomization.Spec.SourceRevision != "" {
+		meta.SetResourceCondition(&kustomization, kustomizev1.PinnedRevisionCondition, metav1.ConditionTrue,
+			kustomizev1.RevisionPinnedReason, "Pinned to revision: "+kustomization.Spec.SourceRevision)
+	} else {
+		apimeta.RemoveStatusCondition(&kustomization.Status.Conditions, kustomizev1.PinnedRevisionCondition)
+	}
+
+	// reject the phase orders that would health check or prune objects before applying them
+	if err := validateReconcileOrder(kustomization.Spec.ReconcileOrder); err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			source.GetArtifact().Revision,
+			kustomizev1.ValidationFailedReason,
+			err.Error(),
+		), err
+	}
+
 	// create tmp dir
 	tmpDir, err := ioutil.TempDir("", kustomization.Name)
 	if err != nil {
@@ -268,7 +494,7 @@ func (r *KustomizationReconciler) reconcile(
 	defer os.RemoveAll(tmpDir)
 
 	// download artifact and extract files
-	err = r.download(kustomization, source.GetArtifact().URL, tmpDir)
+	err = r.download(kustomization, source, tmpDir)
 	if err != nil {
 		return kustomizev1.KustomizationNotReady(
 			kustomization,
@@ -297,10 +523,70 @@ func (r *KustomizationReconciler) reconcile(
 			err.Error(),
 		), err
 	}
+	if n := kustomization.Spec.SourceStripComponents; n > 0 {
+		if ok, err := containsManifests(dirPath); err != nil || !ok {
+			if err == nil {
+				err = fmt.Errorf("kustomization path '%s' contains no manifests after stripping %d path components",
+					kustomization.Spec.Path, n)
+			}
+			return kustomizev1.KustomizationNotReady(
+				kustomization,
+				source.GetArtifact().Revision,
+				kustomizev1.ArtifactFailedReason,
+				err.Error(),
+			), err
+		}
+	}
+
+	// select the namespaces the objects are applied into
+	kustomization.Status.TargetNamespaces = nil
+	if kustomization.Spec.TargetNamespaceSelector != nil {
+		namespaces, err := r.selectTargetNamespaces(ctx, kustomization)
+		if err != nil {
+			return kustomizev1.KustomizationNotReady(
+				kustomization,
+				source.GetArtifact().Revision,
+				meta.ReconciliationFailedReason,
+				err.Error(),
+			), err
+		}
+		kustomization.Status.TargetNamespaces = namespaces
+	}
+
+	// record the configuration used for this reconciliation
+	kustomization.Status.EffectiveSpec = r.effectiveSpec(kustomization, source, tmpDir, dirPath)
+
+	dec, cleanup, err := NewTempDecryptor(r.Client, kustomization)
+	if err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			source.GetArtifact().Revision,
+			kustomizev1.BuildFailedReason,
+			err.Error(),
+		), err
+	}
+	defer cleanup()
+
+	// decrypt the SOPS encrypted files of the build before the kustomize generators read them
+	if err := r.decryptFiles(ctx, dec, tmpDir, dirPath); err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			source.GetArtifact().Revision,
+			kustomizev1.BuildFailedReason,
+			err.Error(),
+		), err
+	}
+
+	// the builds are cancelled when the controller heap exceeds the build heap limit
+	buildFS, releaseBuildFS := r.buildHeapLimiter.guard(filesys.MakeFsOnDisk())
+	defer releaseBuildFS()
 
 	// generate kustomization.yaml and calculate the manifests checksum
-	checksum, err := r.generate(kustomization, dirPath)
+	checksum, err := r.generate(ctx, kustomization, buildFS, dirPath)
 	if err != nil {
+		err = heapLimitError(buildFS, err)
+		err = explainMissingBases(err, filesys.MakeFsOnDisk(), tmpDir, dirPath)
+		err = explainBuildError(err, filesys.MakeFsOnDisk(), tmpDir, dirPath)
 		return kustomizev1.KustomizationNotReady(
 			kustomization,
 			source.GetArtifact().Revision,
@@ -310,8 +596,9 @@ func (r *KustomizationReconciler) reconcile(
 	}
 
 	// build the kustomization and generate the GC snapshot
-	snapshot, err := r.build(kustomization, checksum, dirPath)
+	snapshot, inventory, err := r.build(kustomization, dec, buildFS, checksum, dirPath)
 	if err != nil {
+		err = explainBuildError(err, filesys.MakeFsOnDisk(), tmpDir, dirPath)
 		return kustomizev1.KustomizationNotReady(
 			kustomization,
 			source.GetArtifact().Revision,
@@ -319,22 +606,124 @@ func (r *KustomizationReconciler) reconcile(
 			err.Error(),
 		), err
 	}
+	kustomization.Status.ResourceCount = len(inventory.Entries)
+
+	// refuse to apply and prune an empty build output
+	if len(inventory.Entries) == 0 && (kustomization.Spec.AllowEmptyRender == nil || !*kustomization.Spec.AllowEmptyRender) {
+		err = fmt.Errorf("the build of path '%s' produced no objects, set allowEmptyRender to apply it", kustomization.Spec.Path)
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			source.GetArtifact().Revision,
+			kustomizev1.EmptyRenderDetectedReason,
+			err.Error(),
+		), err
+	}
+
+	// refuse to apply more objects than allowed by the namespace quotas
+	if err := checkObjectQuotas(r.objectQuotas, kustomization, inventory); err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			source.GetArtifact().Revision,
+			kustomizev1.QuotaExceededReason,
+			err.Error(),
+		), err
+	}
+
+	// detect the objects dropped by the kustomize transformers
+	if err := r.checkDroppedResources(ctx, kustomization, buildFS, source.GetArtifact().Revision, dirPath); err != nil {
+		err = heapLimitError(buildFS, err)
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			source.GetArtifact().Revision,
+			kustomizev1.BuildFailedReason,
+			err.Error(),
+		), err
+	}
+
+	// record the source file of the rendered objects
+	kustomization.Status.Origins = nil
+	if kustomization.Spec.ReportOrigins {
+		origins, err := resourceOrigins(buildFS, tmpDir, dirPath)
+		if err != nil {
+			(logr.FromContext(ctx)).Error(heapLimitError(buildFS, err), "unable to find the origins of the rendered objects")
+		}
+		kustomization.Status.Origins = origins
+	}
+	releaseBuildFS()
 
 	// create any necessary kube-clients for impersonation
-	impersonation := NewKustomizeImpersonation(kustomization, r.Client, r.StatusPoller, dirPath)
+	impersonation := NewKustomizeImpersonation(kustomization, r.Client, r.StatusPoller, r.defaultServiceAccount, r.userAgent, dirPath)
 	client, statusPoller, err := impersonation.GetClient(ctx)
 	if err != nil {
+		reason := meta.ReconciliationFailedReason
+		if remoteClusterUnreachable(kustomization, err) {
+			reason = kustomizev1.ClusterUnreachableReason
+		}
 		return kustomizev1.KustomizationNotReady(
 			kustomization,
 			source.GetArtifact().Revision,
-			meta.ReconciliationFailedReason,
+			reason,
 			err.Error(),
 		), fmt.Errorf("failed to build kube client: %w", err)
 	}
 
-	// dry-run apply
-	err = r.validate(ctx, kustomization, impersonation, dirPath)
-	if err != nil {
+	// compare with the cluster state without applying
+	if kustomization.Spec.ReportOnly {
+		return r.report(ctx, client, kustomization, source.GetArtifact().Revision, dirPath)
+	}
+
+	// skip the create-only objects that exist on the cluster
+	if err := r.filterCreateOnly(ctx, client, kustomization, dirPath); err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			source.GetArtifact().Revision,
+			meta.ReconciliationFailedReason,
+			err.Error(),
+		), err
+	}
+
+	// keep the annotations set by other controllers
+	if err := r.mergePreservedAnnotations(ctx, client, kustomization, dirPath); err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			source.GetArtifact().Revision,
+			meta.ReconciliationFailedReason,
+			err.Error(),
+		), err
+	}
+
+	// keep the replicas set by the autoscalers
+	if err := r.mergeHPAReplicas(ctx, client, kustomization, dirPath); err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			source.GetArtifact().Revision,
+			meta.ReconciliationFailedReason,
+			err.Error(),
+		), err
+	}
+
+	// detect the objects managed by other Kustomizations
+	if err := r.checkOwnershipConflicts(ctx, client, kustomization, source.GetArtifact().Revision, dirPath); err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			source.GetArtifact().Revision,
+			kustomizev1.OwnershipConflictReason,
+			err.Error(),
+		), err
+	}
+
+	// refuse to stop serving the CRD versions in use
+	if err := r.checkCRDVersions(ctx, client, kustomization, dirPath); err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			source.GetArtifact().Revision,
+			kustomizev1.CRDVersionConflictReason,
+			err.Error(),
+		), err
+	}
+
+	// detect the namespaced objects applied into the default namespace
+	if err := r.checkMissingNamespaces(ctx, client, kustomization, source.GetArtifact().Revision, dirPath); err != nil {
 		return kustomizev1.KustomizationNotReady(
 			kustomization,
 			source.GetArtifact().Revision,
@@ -343,9 +732,8 @@ func (r *KustomizationReconciler) reconcile(
 		), err
 	}
 
-	// apply
-	changeSet, err := r.applyWithRetry(ctx, kustomization, impersonation, source.GetArtifact().Revision, dirPath, 5*time.Second)
-	if err != nil {
+	// apply the objects listed in ApplyOrder first
+	if err := r.orderManifests(kustomization, dirPath); err != nil {
 		return kustomizev1.KustomizationNotReady(
 			kustomization,
 			source.GetArtifact().Revision,
@@ -354,29 +742,151 @@ func (r *KustomizationReconciler) reconcile(
 		), err
 	}
 
-	// prune
-	err = r.prune(ctx, client, kustomization, checksum)
+	// summarize the changes since the last apply
+	if manifests, err := ioutil.ReadFile(filepath.Join(dirPath, fmt.Sprintf("%s.yaml", kustomization.GetUID()))); err == nil {
+		diff, err := r.validatedManifests.diff(kustomization, source.GetArtifact().Revision, manifests, inventory)
+		if err != nil {
+			(logr.FromContext(ctx)).Error(err, "unable to diff the rendered manifests")
+		}
+		kustomization.Status.LastReconcileDiff = diff
+	}
+
+	// dry-run apply
+	err = r.validate(ctx, kustomization, impersonation, dirPath)
 	if err != nil {
 		return kustomizev1.KustomizationNotReady(
 			kustomization,
 			source.GetArtifact().Revision,
-			kustomizev1.PruneFailedReason,
+			kustomizev1.ValidationFailedReason,
+			err.Error(),
+		), err
+	}
+
+	// server-side dry-run of all objects before mutating the cluster
+	if kustomization.Spec.AtomicApply != nil && *kustomization.Spec.AtomicApply {
+		err = r.dryRunAll(ctx, kustomization, impersonation, dirPath)
+		if err != nil {
+			return kustomizev1.KustomizationNotReady(
+				kustomization,
+				source.GetArtifact().Revision,
+				kustomizev1.ValidationFailedReason,
+				err.Error(),
+			), err
+		}
+	}
+
+	// log the rendered manifests and their diff with the cluster state
+	if (logr.FromContext(ctx)).V(debugLevel).Enabled() {
+		r.logRenderedDiff(ctx, kustomization, impersonation, dirPath)
+	}
+
+	// adopt the live objects on the first reconciliation
+	if adopted, err := r.adoptObjects(ctx, client, kustomization, checksum); err != nil {
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			source.GetArtifact().Revision,
+			meta.ReconciliationFailedReason,
 			err.Error(),
 		), err
+	} else if len(adopted) > 0 {
+		if snapshot, inventory, err = withAdoptedObjects(snapshot, inventory, adopted); err != nil {
+			return kustomizev1.KustomizationNotReady(
+				kustomization,
+				source.GetArtifact().Revision,
+				meta.ReconciliationFailedReason,
+				err.Error(),
+			), err
+		}
 	}
 
-	// health assessment
-	err = r.checkHealth(ctx, statusPoller, kustomization, source.GetArtifact().Revision, changeSet != "")
+	// record the changes made to the cluster in the audit log
+	ctx, auditRec := withAuditRecorder(ctx, kustomization, source.GetArtifact().Revision,
+		auditIdentity(kustomization, impersonation))
+	defer r.recordAudit(ctx, auditRec)
+
+	// apply
+	changeSet, progress, err := r.applyWithRetry(ctx, kustomization, impersonation, source.GetArtifact().Revision, checksum, dirPath, 5*time.Second)
 	if err != nil {
+		if !r.pruneOnApplyFailure(ctx, client, kustomization, source.GetArtifact().Revision, checksum) {
+			snapshot = unprunedSnapshot(kustomization, snapshot)
+		}
 		return kustomizev1.KustomizationNotReadySnapshot(
 			kustomization,
 			snapshot,
 			source.GetArtifact().Revision,
-			kustomizev1.HealthCheckFailedReason,
+			meta.ReconciliationFailedReason,
 			err.Error(),
 		), err
 	}
 
+	// yield after each batch, the garbage collection and the health assessment
+	// run once the last batch is applied
+	kustomization.Status.ApplyProgress = progress
+	if progress != nil {
+		kustomizev1.SetKustomizationReadiness(&kustomization, metav1.ConditionUnknown, meta.ProgressingReason,
+			fmt.Sprintf("Applied %d of %d objects", progress.Applied, progress.Total), source.GetArtifact().Revision)
+		return kustomization, nil
+	}
+
+	if manifests, err := ioutil.ReadFile(filepath.Join(dirPath, fmt.Sprintf("%s.yaml", kustomization.GetUID()))); err == nil {
+		if err := r.validatedManifests.record(kustomization, manifests); err != nil {
+			(logr.FromContext(ctx)).Error(err, "unable to record the applied manifests hashes")
+		}
+	}
+
+	// run the garbage collection and the health assessment in the configured order
+	pruned := false
+	for _, phase := range reconcileOrder(kustomization)[1:] {
+		switch phase {
+		case kustomizev1.PrunePhase:
+			// prune, unless deferred until the revision is stable
+			if isPruneDeferred(kustomization, source.GetArtifact().Revision) {
+				(logr.FromContext(ctx)).Info(fmt.Sprintf("Garbage collection deferred until the revision is healthy for %s",
+					kustomization.Spec.PruneAfterStable.Duration.String()))
+				// keep the previous checksum and kinds for the deferred garbage collection
+				snapshot = unprunedSnapshot(kustomization, snapshot)
+			} else {
+				err = r.prune(ctx, client, kustomization, checksum)
+			}
+			if err != nil {
+				return kustomizev1.KustomizationNotReadySnapshot(
+					kustomization,
+					unprunedSnapshot(kustomization, snapshot),
+					source.GetArtifact().Revision,
+					kustomizev1.PruneFailedReason,
+					err.Error(),
+				), err
+			}
+			pruned = true
+
+			// record the objects managed by this Kustomization
+			kustomization.Status.Inventory = inventory
+		case kustomizev1.HealthCheckPhase:
+			// health assessment
+			err = r.checkHealth(ctx, client, statusPoller, kustomization, source.GetArtifact().Revision, changeSet != "")
+			if err != nil {
+				kustomization.Status.StableSince = nil
+				if !pruned {
+					snapshot = unprunedSnapshot(kustomization, snapshot)
+				}
+				return kustomizev1.KustomizationNotReadySnapshot(
+					kustomization,
+					snapshot,
+					source.GetArtifact().Revision,
+					kustomizev1.HealthCheckFailedReason,
+					err.Error(),
+				), err
+			}
+
+			// record since when the objects of this revision are healthy
+			if kustomization.Status.StableSince == nil ||
+				kustomization.Status.LastAppliedRevision != source.GetArtifact().Revision {
+				now := metav1.Now()
+				kustomization.Status.StableSince = &now
+			}
+		}
+	}
+
 	return kustomizev1.KustomizationReady(
 		kustomization,
 		snapshot,
@@ -386,6 +896,35 @@ func (r *KustomizationReconciler) reconcile(
 	), nil
 }
 
+func (r *KustomizationReconciler) report(ctx context.Context, kubeClient client.Client,
+	kustomization kustomizev1.Kustomization, revision, dirPath string) (kustomizev1.Kustomization, error) {
+	manifests, err := ioutil.ReadFile(filepath.Join(dirPath, fmt.Sprintf("%s.yaml", kustomization.GetUID())))
+	if err == nil {
+		kustomization.Status.DriftReport, err = reportDrift(ctx, kubeClient, manifests, revision)
+	}
+	if err != nil {
+		err = fmt.Errorf("drift report failed: %w", err)
+		return kustomizev1.KustomizationNotReady(
+			kustomization,
+			revision,
+			meta.ReconciliationFailedReason,
+			err.Error(),
+		), err
+	}
+
+	report := kustomization.Status.DriftReport
+	msg := fmt.Sprintf("Compared revision %s: %d objects in sync, %d drifted, %d missing",
+		revision, report.InSync, len(report.Drifted), len(report.Missing))
+	if len(report.Drifted) == 0 && len(report.Missing) == 0 {
+		kustomizev1.SetKustomizationReadiness(&kustomization, metav1.ConditionTrue, kustomizev1.InSyncReason, msg, revision)
+		return kustomization, nil
+	}
+
+	kustomizev1.SetKustomizationReadiness(&kustomization, metav1.ConditionFalse, kustomizev1.DriftDetectedReason, msg, revision)
+	r.event(ctx, kustomization, revision, events.EventSeverityInfo, msg, nil)
+	return kustomization, nil
+}
+
 func (r *KustomizationReconciler) checkDependencies(kustomization kustomizev1.Kustomization) error {
 	for _, d := range kustomization.Spec.DependsOn {
 		if d.Namespace == "" {
@@ -407,40 +946,91 @@ func (r *KustomizationReconciler) checkDependencies(kustomization kustomizev1.Ku
 		}
 	}
 
+	for _, d := range kustomization.Spec.DependsOnHelmReleases {
+		if d.Namespace == "" {
+			d.Namespace = kustomization.GetNamespace()
+		}
+		if err := r.checkHelmReleaseDependency(context.Background(), types.NamespacedName(d)); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (r *KustomizationReconciler) download(kustomization kustomizev1.Kustomization, url string, tmpDir string) error {
-	timeout := kustomization.GetTimeout() + (time.Second * 1)
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
+func (r *KustomizationReconciler) checkRequiredMetadata(kustomization kustomizev1.Kustomization) error {
+	var missing []string
+	for _, key := range r.requiredLabels {
+		if _, ok := kustomization.GetLabels()[key]; !ok {
+			missing = append(missing, fmt.Sprintf("label '%s'", key))
+		}
+	}
+	for _, key := range r.requiredAnnotations {
+		if _, ok := kustomization.GetAnnotations()[key]; !ok {
+			missing = append(missing, fmt.Sprintf("annotation '%s'", key))
+		}
+	}
 
-	// download the tarball
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create HTTP request for %s, error: %w", url, err)
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required metadata: %s", strings.Join(missing, ", "))
 	}
+	return nil
+}
 
-	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
-	if err != nil {
-		return fmt.Errorf("failed to download artifact from %s, error: %w", url, err)
+func (r *KustomizationReconciler) download(kustomization kustomizev1.Kustomization, source sourcev1.Source, tmpDir string) error {
+	// download the tarball, unless already downloaded to compute its revision
+	artifactPath := ""
+	if s, ok := source.(*artifactURLSource); ok {
+		artifactPath = s.path
+	} else {
+		path, err := r.fetchArtifact(kustomization, source.GetArtifact().URL)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(path)
+		artifactPath = path
 	}
-	defer resp.Body.Close()
 
-	// check response
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("faild to download artifact from %s, status: %s", url, resp.Status)
+	artifact, err := os.Open(artifactPath)
+	if err != nil {
+		return fmt.Errorf("failed to open artifact, error: %w", err)
 	}
+	defer artifact.Close()
 
 	// extract
-	if _, err = untar.Untar(resp.Body, tmpDir); err != nil {
+	n := kustomization.Spec.SourceStripComponents
+	if n <= 0 {
+		if _, err = untar.Untar(artifact, tmpDir); err != nil {
+			return fmt.Errorf("faild to untar artifact, error: %w", err)
+		}
+		return nil
+	}
+
+	srcDir, err := ioutil.TempDir("", kustomization.Name+"-src")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir, error: %w", err)
+	}
+	defer os.RemoveAll(srcDir)
+
+	if _, err = untar.Untar(artifact, srcDir); err != nil {
 		return fmt.Errorf("faild to untar artifact, error: %w", err)
 	}
+	if err := stripComponents(srcDir, tmpDir, n); err != nil {
+		return fmt.Errorf("failed to strip %d path components from artifact, error: %w", n, err)
+	}
 
 	return nil
 }
 
 func (r *KustomizationReconciler) getSource(ctx context.Context, kustomization kustomizev1.Kustomization) (sourcev1.Source, error) {
+	if kustomization.Spec.ArtifactURL != "" {
+		source, err := r.newArtifactURLSource(kustomization)
+		if err != nil {
+			return nil, err
+		}
+		return source, nil
+	}
+
 	var source sourcev1.Source
 	sourceNamespace := kustomization.GetNamespace()
 	if kustomization.Spec.SourceRef.Namespace != "" {
@@ -478,61 +1068,97 @@ func (r *KustomizationReconciler) getSource(ctx context.Context, kustomization k
 	return source, nil
 }
 
-func (r *KustomizationReconciler) generate(kustomization kustomizev1.Kustomization, dirPath string) (string, error) {
-	gen := NewGenerator(kustomization)
-	return gen.WriteFile(dirPath)
-}
-
-func (r *KustomizationReconciler) build(kustomization kustomizev1.Kustomization, checksum, dirPath string) (*kustomizev1.Snapshot, error) {
-	timeout := kustomization.GetTimeout()
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	dec, cleanup, err := NewTempDecryptor(r.Client, kustomization)
-	if err != nil {
-		return nil, err
+// decryptFiles imports the OpenPGP keys, if any, and decrypts in place the
+// SOPS encrypted files read by the build of dirPath.
+func (r *KustomizationReconciler) decryptFiles(ctx context.Context, dec *KustomizeDecryptor, tmpDir, dirPath string) error {
+	if dec.kustomization.Spec.Decryption == nil {
+		return nil
 	}
-	defer cleanup()
 
-	// import OpenPGP keys if any
 	if err := dec.ImportKeys(ctx); err != nil {
-		return nil, err
+		return err
 	}
+	return dec.DecryptFiles(tmpDir, dirPath)
+}
+
+func (r *KustomizationReconciler) generate(ctx context.Context, kustomization kustomizev1.Kustomization, fs filesys.FileSystem, dirPath string) (string, error) {
+	gen := NewGenerator(kustomization, fs).WithMaxDepth(r.maxScanDepth).
+		WithTargetNamespaces(kustomization.Status.TargetNamespaces)
+	checksum, err := gen.WriteFile(dirPath)
+	if skipped := gen.SkippedSymlinks(); len(skipped) > 0 {
+		(logr.FromContext(ctx)).Info(fmt.Sprintf("symlinked directories not scanned when generating the kustomization.yaml: %s",
+			strings.Join(skipped, ", ")))
+	}
+	return checksum, err
+}
 
-	fs := filesys.MakeFsOnDisk()
-	m, err := buildKustomization(fs, dirPath)
+func (r *KustomizationReconciler) build(kustomization kustomizev1.Kustomization, dec *KustomizeDecryptor, fs filesys.FileSystem, checksum, dirPath string) (*kustomizev1.Snapshot, *kustomizev1.ResourceInventory, error) {
+	var m resmap.ResMap
+	var err error
+	if kustomization.Spec.TargetNamespaceSelector != nil {
+		m, err = buildKustomizationInNamespaces(fs, dirPath, kustomization.Status.TargetNamespaces)
+	} else {
+		m, err = buildKustomization(fs, dirPath)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("kustomize build failed: %w", err)
+		return nil, nil, fmt.Errorf("kustomize build failed: %w", err)
 	}
 
-	// check if resources are encrypted and decrypt them before generating the final YAML
+	// apply the items of the List objects individually
+	if err := expandLists(m); err != nil {
+		return nil, nil, err
+	}
+
+	// decrypt the objects still encrypted after the build, e.g. the ones of the remote bases
 	if kustomization.Spec.Decryption != nil {
 		for _, res := range m.Resources() {
 			outRes, err := dec.Decrypt(res)
 			if err != nil {
-				return nil, fmt.Errorf("decryption failed for '%s': %w", res.GetName(), err)
+				return nil, nil, fmt.Errorf("decryption failed for '%s': %w", res.GetName(), err)
 			}
 
 			if outRes != nil {
 				_, err = m.Replace(res)
 				if err != nil {
-					return nil, err
+					return nil, nil, err
 				}
 			}
 		}
 	}
 
+	// rewrite the container images registries if any
+	if len(kustomization.Spec.ImageRegistryRewrite) > 0 {
+		if err := rewriteImageRegistries(m, kustomization.Spec.ImageRegistryRewrite); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// remove the post build annotations and labels if any
+	if kustomization.Spec.PostBuild != nil {
+		removeMetadata(m, kustomization.Spec.PostBuild)
+	}
+
 	resources, err := m.AsYaml()
 	if err != nil {
-		return nil, fmt.Errorf("kustomize build failed: %w", err)
+		return nil, nil, fmt.Errorf("kustomize build failed: %w", err)
 	}
 
 	manifestsFile := filepath.Join(dirPath, fmt.Sprintf("%s.yaml", kustomization.GetUID()))
 	if err := fs.WriteFile(manifestsFile, resources); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return kustomizev1.NewSnapshot(resources, checksum)
+	snapshot, err := kustomizev1.NewSnapshot(resources, checksum)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	inventory, err := kustomizev1.NewInventory(resources)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return snapshot, inventory, nil
 }
 
 func (r *KustomizationReconciler) validate(ctx context.Context, kustomization kustomizev1.Kustomization, imp *KustomizeImpersonation, dirPath string) error {
@@ -540,12 +1166,55 @@ func (r *KustomizationReconciler) validate(ctx context.Context, kustomization ku
 		return nil
 	}
 
+	manifestsFile := fmt.Sprintf("%s.yaml", kustomization.GetUID())
+	if isEmptyFile(filepath.Join(dirPath, manifestsFile)) {
+		return nil
+	}
+
+	// the server-side dry-runs are limited to the objects changed since the last apply
+	if kustomization.Spec.Validation == "server" || kustomization.Spec.Validation == "server-apply" {
+		manifests, err := ioutil.ReadFile(filepath.Join(dirPath, manifestsFile))
+		if err != nil {
+			return err
+		}
+		changed, skipped, err := r.validatedManifests.changed(kustomization, manifests)
+		if err != nil {
+			return fmt.Errorf("hashing manifests failed: %w", err)
+		}
+		if len(changed) == 0 {
+			(logr.FromContext(ctx)).V(1).Info("Validation skipped, no objects changed since the last apply")
+			return nil
+		}
+		if skipped > 0 {
+			manifestsFile = fmt.Sprintf("%s.validate.yaml", kustomization.GetUID())
+			if err := ioutil.WriteFile(filepath.Join(dirPath, manifestsFile), changed, os.ModePerm); err != nil {
+				return err
+			}
+			(logr.FromContext(ctx)).V(1).Info(fmt.Sprintf("Validation skipped for %d unchanged objects", skipped))
+		}
+	}
+
+	if kustomization.Spec.Validation == "server-apply" {
+		manifests, err := ioutil.ReadFile(filepath.Join(dirPath, manifestsFile))
+		if err != nil {
+			return err
+		}
+		kubeClient, _, err := imp.GetClient(ctx)
+		if err != nil {
+			return err
+		}
+		if err := serverApplyDryRun(ctx, kubeClient, manifests); err != nil {
+			return fmt.Errorf("validation failed: %w", err)
+		}
+		return nil
+	}
+
 	timeout := kustomization.GetTimeout() + (time.Second * 1)
 	applyCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	cmd := fmt.Sprintf("cd %s && kubectl apply -f %s.yaml --timeout=%s --dry-run=%s --cache-dir=/tmp",
-		dirPath, kustomization.GetUID(), kustomization.GetTimeout().String(), kustomization.Spec.Validation)
+	cmd := fmt.Sprintf("cd %s && kubectl apply -f %s --timeout=%s --dry-run=%s --cache-dir=/tmp",
+		dirPath, manifestsFile, kustomization.GetTimeout().String(), kustomization.Spec.Validation)
 
 	if kustomization.Spec.KubeConfig != nil {
 		kubeConfig, err := imp.WriteKubeConfig(ctx)
@@ -566,15 +1235,96 @@ func (r *KustomizationReconciler) validate(ctx context.Context, kustomization ku
 	return nil
 }
 
-func (r *KustomizationReconciler) apply(ctx context.Context, kustomization kustomizev1.Kustomization, imp *KustomizeImpersonation, dirPath string) (string, error) {
+// apply applies the manifests of the build with the given checksum. When the
+// objects are applied in batches, only the next batch is applied and the
+// progress is returned until all the batches have been applied.
+func (r *KustomizationReconciler) apply(ctx context.Context, kustomization kustomizev1.Kustomization, imp *KustomizeImpersonation, checksum, dirPath string) (string, *kustomizev1.ApplyProgress, error) {
+	if err := checkApplyOptions(kustomization); err != nil {
+		return "", nil, err
+	}
+
+	manifestsFile := fmt.Sprintf("%s.yaml", kustomization.GetUID())
+	if isEmptyFile(filepath.Join(dirPath, manifestsFile)) {
+		// all objects are create-only and exist on the cluster
+		return "", nil, nil
+	}
+
+	// apply the CRDs and wait for them to be established
+	// before applying the custom resources of their kinds
+	changeSet := ""
+	crdsFile, otherFile, crds, err := splitCRDs(dirPath, manifestsFile)
+	if err != nil {
+		return "", nil, fmt.Errorf("splitting CRDs failed: %w", err)
+	}
+	if len(crds) > 0 {
+		crdsChangeSet, err := r.applyManifests(ctx, kustomization, imp, dirPath, crdsFile)
+		if err != nil {
+			return "", nil, err
+		}
+		changeSet += crdsChangeSet
+
+		kubeClient, _, err := imp.GetClient(ctx)
+		if err != nil {
+			return "", nil, err
+		}
+		if err := waitForCRDs(ctx, kubeClient, kustomization, crds); err != nil {
+			return "", nil, err
+		}
+		manifestsFile = otherFile
+	}
+
+	if kustomization.Spec.ApplyConcurrency > 1 {
+		otherChangeSet, err := r.applyConcurrently(ctx, kustomization, imp, dirPath, manifestsFile)
+		if err != nil {
+			return "", nil, err
+		}
+		return changeSet + otherChangeSet, nil, nil
+	}
+
+	if kustomization.Spec.ApplyBatchSize == 0 {
+		otherChangeSet, err := r.applyManifests(ctx, kustomization, imp, dirPath, manifestsFile)
+		if err != nil {
+			return "", nil, err
+		}
+		return changeSet + otherChangeSet, nil, nil
+	}
+
+	batches, total, err := splitManifests(dirPath, manifestsFile, kustomization.Spec.ApplyBatchSize)
+	if err != nil {
+		return "", nil, fmt.Errorf("splitting manifests in batches failed: %w", err)
+	}
+
+	// resume the apply of the same build where the last reconciliation left it
+	applied := 0
+	if progress := kustomization.Status.ApplyProgress; progress != nil && progress.Checksum == checksum && progress.Total == total {
+		applied = progress.Applied
+	}
+
+	// apply the next batch only, the following ones are applied by the next reconciliations
+	batch, skipped, ok := nextManifestsBatch(batches, applied)
+	if !ok {
+		return changeSet, nil, nil
+	}
+	batchChangeSet, err := r.applyManifests(ctx, kustomization, imp, dirPath, batch.file)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w (applied %d of %d objects)", err, skipped, total)
+	}
+	changeSet += batchChangeSet
+	if applied = skipped + batch.size; applied < total {
+		return changeSet, &kustomizev1.ApplyProgress{Checksum: checksum, Applied: applied, Total: total}, nil
+	}
+	return changeSet, nil, nil
+}
+
+func (r *KustomizationReconciler) applyManifests(ctx context.Context, kustomization kustomizev1.Kustomization, imp *KustomizeImpersonation, dirPath, manifestsFile string) (string, error) {
 	start := time.Now()
 	timeout := kustomization.GetTimeout() + (time.Second * 1)
 	applyCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 	fieldManager := "kustomize-controller"
 
-	cmd := fmt.Sprintf("cd %s && kubectl apply --field-manager=%s -f %s.yaml --timeout=%s --cache-dir=/tmp",
-		dirPath, fieldManager, kustomization.GetUID(), kustomization.Spec.Interval.Duration.String())
+	cmd := fmt.Sprintf("cd %s && kubectl apply --field-manager=%s -f %s --timeout=%s --cache-dir=/tmp",
+		dirPath, fieldManager, manifestsFile, kustomization.Spec.Interval.Duration.String())
 
 	if kustomization.Spec.KubeConfig != nil {
 		kubeConfig, err := imp.WriteKubeConfig(ctx)
@@ -584,7 +1334,7 @@ func (r *KustomizationReconciler) apply(ctx context.Context, kustomization kusto
 		cmd = fmt.Sprintf("%s --kubeconfig=%s", cmd, kubeConfig)
 	} else {
 		// impersonate SA
-		if kustomization.Spec.ServiceAccountName != "" {
+		if imp.ServiceAccountName() != "" {
 			saToken, err := imp.GetServiceAccountToken(ctx)
 			if err != nil {
 				return "", fmt.Errorf("service account impersonation failed: %w", err)
@@ -609,6 +1359,7 @@ func (r *KustomizationReconciler) apply(ctx context.Context, kustomization kusto
 	}
 
 	resources := parseApplyOutput(output)
+	auditRecorderFrom(ctx).applied(resources)
 	(logr.FromContext(ctx)).Info(
 		fmt.Sprintf("Kustomization applied in %s",
 			time.Now().Sub(start).String()),
@@ -624,30 +1375,31 @@ func (r *KustomizationReconciler) apply(ctx context.Context, kustomization kusto
 	return changeSet, nil
 }
 
-func (r *KustomizationReconciler) applyWithRetry(ctx context.Context, kustomization kustomizev1.Kustomization, imp *KustomizeImpersonation, revision, dirPath string, delay time.Duration) (string, error) {
-	changeSet, err := r.apply(ctx, kustomization, imp, dirPath)
+func (r *KustomizationReconciler) applyWithRetry(ctx context.Context, kustomization kustomizev1.Kustomization, imp *KustomizeImpersonation, revision, checksum, dirPath string, delay time.Duration) (string, *kustomizev1.ApplyProgress, error) {
+	changeSet, progress, err := r.apply(ctx, kustomization, imp, checksum, dirPath)
 	if err != nil {
 		// retry apply due to CRD/CR race
 		if strings.Contains(err.Error(), "could not find the requested resource") ||
 			strings.Contains(err.Error(), "no matches for kind") {
 			(logr.FromContext(ctx)).Info("retrying apply", "error", err.Error())
 			time.Sleep(delay)
-			if changeSet, err := r.apply(ctx, kustomization, imp, dirPath); err != nil {
-				return "", err
+			if changeSet, progress, err := r.apply(ctx, kustomization, imp, checksum, dirPath); err != nil {
+				return "", nil, err
 			} else {
 				if changeSet != "" {
 					r.event(ctx, kustomization, revision, events.EventSeverityInfo, changeSet, nil)
 				}
+				return changeSet, progress, nil
 			}
 		} else {
-			return "", err
+			return "", nil, err
 		}
 	} else {
 		if changeSet != "" && kustomization.Status.LastAppliedRevision != revision {
 			r.event(ctx, kustomization, revision, events.EventSeverityInfo, changeSet, nil)
 		}
 	}
-	return changeSet, nil
+	return changeSet, progress, nil
 }
 
 func (r *KustomizationReconciler) prune(ctx context.Context, client client.Client, kustomization kustomizev1.Kustomization, newChecksum string) error {
@@ -658,30 +1410,117 @@ func (r *KustomizationReconciler) prune(ctx context.Context, client client.Clien
 		return nil
 	}
 
-	gc := NewGarbageCollector(client, *kustomization.Status.Snapshot, newChecksum, logr.FromContext(ctx))
+	opts := GarbageCollectorOptions{
+		Exclude:   kustomization.Spec.PruneExclude,
+		AllowCRDs: kustomization.Spec.AllowCRDPrune != nil && *kustomization.Spec.AllowCRDPrune,
+	}
+	// the limit doesn't apply when the Kustomization is deleted
+	if kustomization.DeletionTimestamp.IsZero() {
+		opts.Limit = kustomization.Spec.PruneLimit
+		opts.Partial = kustomization.Spec.PruneLimitPolicy == kustomizev1.PruneLimitPartial
+	}
+	gc := NewGarbageCollector(client, *kustomization.Status.Snapshot, newChecksum, opts, logr.FromContext(ctx))
 
-	if output, ok := gc.Prune(kustomization.GetTimeout(),
+	output, remaining, ok := gc.Prune(kustomization.GetTimeout(),
 		kustomization.GetName(),
 		kustomization.GetNamespace(),
-	); !ok {
+	)
+	r.recordPrune(ctx, kustomization, gc.Stats())
+	auditRecorderFrom(ctx).pruned(gc.Deleted())
+	if !ok {
 		return fmt.Errorf("garbage collection failed: %s", output)
-	} else {
-		if output != "" {
-			(logr.FromContext(ctx)).Info(fmt.Sprintf("garbage collection completed: %s", output))
-			r.event(ctx, kustomization, newChecksum, events.EventSeverityInfo, output, nil)
-		}
+	}
+	if output != "" {
+		(logr.FromContext(ctx)).Info(fmt.Sprintf("garbage collection completed: %s", output))
+		r.event(ctx, kustomization, newChecksum, events.EventSeverityInfo, output, nil)
+	}
+	if remaining > 0 {
+		return fmt.Errorf("garbage collection limit of %d objects reached, %d objects left to delete",
+			opts.Limit, remaining)
 	}
 	return nil
 }
 
-func (r *KustomizationReconciler) checkHealth(ctx context.Context, statusPoller *polling.StatusPoller, kustomization kustomizev1.Kustomization, revision string, changed bool) error {
-	if len(kustomization.Spec.HealthChecks) == 0 {
+// unprunedSnapshot returns the last applied snapshot extended with the entries of
+// the new snapshot, for when objects of the new revision may be on the cluster but
+// the previous ones weren't garbage collected. Keeping the previous checksum ensures
+// the next prune runs and looks in both the old and new namespaces, e.g. when the
+// TargetNamespace is changed again before a reconciliation succeeds.
+func unprunedSnapshot(kustomization kustomizev1.Kustomization, snapshot *kustomizev1.Snapshot) *kustomizev1.Snapshot {
+	if kustomization.Status.Snapshot == nil {
+		return (&kustomizev1.Snapshot{Entries: []kustomizev1.SnapshotEntry{}}).WithEntriesOf(snapshot)
+	}
+	return kustomization.Status.Snapshot.WithEntriesOf(snapshot)
+}
+
+// isPruneDeferred returns true if the garbage collection must wait for the objects
+// of the given revision to be healthy for the PruneAfterStable duration.
+func isPruneDeferred(kustomization kustomizev1.Kustomization, revision string) bool {
+	if !kustomization.Spec.Prune || kustomization.Spec.PruneAfterStable == nil {
+		return false
+	}
+	stableSince := kustomization.Status.StableSince
+	if stableSince == nil || kustomization.Status.LastAppliedRevision != revision {
+		return true
+	}
+	return time.Since(stableSince.Time) < kustomization.Spec.PruneAfterStable.Duration
+}
+
+// effectiveSpec returns the configuration resolved from the
+// Kustomization spec, its defaults and the source artifact.
+func (r *KustomizationReconciler) effectiveSpec(kustomization kustomizev1.Kustomization, source sourcev1.Source,
+	tmpDir, dirPath string) *kustomizev1.EffectiveSpec {
+	sourceName := kustomization.Spec.ArtifactURL
+	if ref := kustomization.Spec.SourceRef; sourceName == "" {
+		sourceNamespace := kustomization.GetNamespace()
+		if ref.Namespace != "" {
+			sourceNamespace = ref.Namespace
+		}
+		sourceName = fmt.Sprintf("%s/%s/%s", ref.Kind, sourceNamespace, ref.Name)
+	}
+
+	path, err := filepath.Rel(tmpDir, dirPath)
+	if err != nil {
+		path = kustomization.Spec.Path
+	}
+
+	spec := &kustomizev1.EffectiveSpec{
+		Source:             sourceName,
+		Revision:           source.GetArtifact().Revision,
+		Path:               path,
+		TargetNamespace:    kustomization.Spec.TargetNamespace,
+		ServiceAccountName: kustomization.Spec.ServiceAccountName,
+		Interval:           kustomization.Spec.Interval,
+		RetryInterval:      metav1.Duration{Duration: kustomization.GetRetryInterval()},
+		Timeout:            metav1.Duration{Duration: kustomization.GetTimeout()},
+		Validation:         kustomization.Spec.Validation,
+		Prune:              kustomization.Spec.Prune,
+		BuildFlags:         strings.Join(BuildFlags(DefaultBuildOptions()), " "),
+	}
+	if spec.ServiceAccountName == "" {
+		spec.ServiceAccountName = r.defaultServiceAccount
+	}
+	if kustomization.Spec.KubeConfig != nil {
+		spec.KubeConfigSecretName = kustomization.Spec.KubeConfig.SecretRef.Name
+	}
+	if kustomization.Spec.Decryption != nil {
+		spec.DecryptionProvider = kustomization.Spec.Decryption.Provider
+	}
+	return spec
+}
+
+func (r *KustomizationReconciler) checkHealth(ctx context.Context, kubeClient client.Client, statusPoller *polling.StatusPoller, kustomization kustomizev1.Kustomization, revision string, changed bool) error {
+	if len(kustomization.Spec.HealthChecks) == 0 && len(kustomization.Spec.HealthCheckConditions) == 0 {
 		return nil
 	}
 
-	hc := NewHealthCheck(kustomization, statusPoller)
+	rules, err := r.readinessRules(ctx)
+	if err != nil {
+		return err
+	}
+	hc := NewHealthCheck(kustomization, statusPoller).WithReadinessRules(rules)
 
-	if err := hc.Assess(1 * time.Second); err != nil {
+	if err := hc.AssessAll(kubeClient, 1*time.Second); err != nil {
 		return err
 	}
 
@@ -697,13 +1536,16 @@ func (r *KustomizationReconciler) checkHealth(ctx context.Context, statusPoller
 func (r *KustomizationReconciler) reconcileDelete(ctx context.Context, kustomization kustomizev1.Kustomization) (ctrl.Result, error) {
 	if kustomization.Spec.Prune && !kustomization.Spec.Suspend {
 		// create any necessary kube-clients
-		imp := NewKustomizeImpersonation(kustomization, r.Client, r.StatusPoller, "")
+		imp := NewKustomizeImpersonation(kustomization, r.Client, r.StatusPoller, r.defaultServiceAccount, r.userAgent, "")
 		client, _, err := imp.GetClient(ctx)
 		if err != nil {
 			err = fmt.Errorf("failed to build kube client for Kustomization: %w", err)
 			(logr.FromContext(ctx)).Error(err, "Unable to prune for finalizer")
 			return ctrl.Result{}, err
 		}
+		ctx, auditRec := withAuditRecorder(ctx, kustomization, kustomization.Status.LastAppliedRevision,
+			auditIdentity(kustomization, imp))
+		defer r.recordAudit(ctx, auditRec)
 		if err := r.prune(ctx, client, kustomization, ""); err != nil {
 			r.event(ctx, kustomization, kustomization.Status.LastAppliedRevision, events.EventSeverityError, "pruning for deleted resource failed", nil)
 			// Return the error so we retry the failed garbage collection
@@ -711,6 +1553,8 @@ func (r *KustomizationReconciler) reconcileDelete(ctx context.Context, kustomiza
 		}
 	}
 
+	r.validatedManifests.forget(kustomization)
+
 	// Record deleted status
 	r.recordReadiness(ctx, kustomization)
 
@@ -724,6 +1568,95 @@ func (r *KustomizationReconciler) reconcileDelete(ctx context.Context, kustomiza
 	return ctrl.Result{}, nil
 }
 
+// checkFailureGrace counts the consecutive failed reconciliations and, while within
+// the FailureGrace, restores the Ready condition of the last reconciliation and
+// reports the failure with the Degraded condition. Returns true if the failure
+// is within the grace.
+func checkFailureGrace(kustomization kustomizev1.Kustomization, lastReady *metav1.Condition,
+	reconcileErr error) (kustomizev1.Kustomization, bool) {
+	if reconcileErr == nil {
+		kustomization.Status.ConsecutiveFailures = 0
+		kustomization.Status.FailingSince = nil
+		apimeta.RemoveStatusCondition(&kustomization.Status.Conditions, kustomizev1.DegradedCondition)
+		return kustomization, false
+	}
+
+	kustomization.Status.ConsecutiveFailures++
+	if kustomization.Status.FailingSince == nil {
+		now := metav1.Now()
+		kustomization.Status.FailingSince = &now
+	}
+
+	grace := kustomization.Spec.FailureGrace
+	withinGrace := grace != nil && (grace.Failures > 0 || grace.Duration != nil) &&
+		lastReady != nil && lastReady.Status == metav1.ConditionTrue &&
+		(grace.Failures == 0 || kustomization.Status.ConsecutiveFailures <= grace.Failures) &&
+		(grace.Duration == nil || time.Since(kustomization.Status.FailingSince.Time) <= grace.Duration.Duration)
+	if !withinGrace {
+		apimeta.RemoveStatusCondition(&kustomization.Status.Conditions, kustomizev1.DegradedCondition)
+		return kustomization, false
+	}
+
+	if failed := apimeta.FindStatusCondition(kustomization.Status.Conditions, meta.ReadyCondition); failed != nil {
+		meta.SetResourceCondition(&kustomization, kustomizev1.DegradedCondition, metav1.ConditionTrue,
+			failed.Reason, failed.Message)
+	}
+	meta.SetResourceCondition(&kustomization, meta.ReadyCondition, lastReady.Status, lastReady.Reason, lastReady.Message)
+	return kustomization, true
+}
+
+// applyIntervalDefaults sets the controller default interval and timeout on
+// the Kustomization when it doesn't specify them, and raises the interval and
+// retry interval below the controller minimum to the minimum.
+func (r *KustomizationReconciler) applyIntervalDefaults(ctx context.Context,
+	kustomization kustomizev1.Kustomization) kustomizev1.Kustomization {
+	if kustomization.Spec.Interval.Duration == 0 && r.defaultInterval > 0 {
+		kustomization.Spec.Interval = metav1.Duration{Duration: r.defaultInterval}
+	}
+	if kustomization.Spec.Timeout == nil && r.defaultTimeout > 0 {
+		kustomization.Spec.Timeout = &metav1.Duration{Duration: r.defaultTimeout}
+	}
+
+	if r.minInterval <= 0 {
+		return kustomization
+	}
+	if kustomization.Spec.Interval.Duration < r.minInterval {
+		(logr.FromContext(ctx)).Info(fmt.Sprintf("Interval %s is below the minimum of %s, using the minimum",
+			kustomization.Spec.Interval.Duration.String(), r.minInterval.String()))
+		kustomization.Spec.Interval = metav1.Duration{Duration: r.minInterval}
+	}
+	if ri := kustomization.Spec.RetryInterval; ri != nil && ri.Duration < r.minInterval {
+		(logr.FromContext(ctx)).Info(fmt.Sprintf("Retry interval %s is below the minimum of %s, using the minimum",
+			ri.Duration.String(), r.minInterval.String()))
+		kustomization.Spec.RetryInterval = &metav1.Duration{Duration: r.minInterval}
+	}
+	return kustomization
+}
+
+// checkReconcileBudget records the duration of the reconciliation in status and
+// reports through an event and the ReconcileBudgetExceeded condition when the
+// duration exceeds the Kustomization budget, or the controller default.
+func (r *KustomizationReconciler) checkReconcileBudget(ctx context.Context,
+	kustomization kustomizev1.Kustomization, duration time.Duration) kustomizev1.Kustomization {
+	kustomization.Status.LastReconcileDuration = &metav1.Duration{Duration: duration}
+
+	budget := r.reconcileBudget
+	if kustomization.Spec.ReconcileBudget != nil {
+		budget = kustomization.Spec.ReconcileBudget.Duration
+	}
+
+	if budget <= 0 || duration <= budget {
+		apimeta.RemoveStatusCondition(&kustomization.Status.Conditions, kustomizev1.ReconcileBudgetExceededCondition)
+		return kustomization
+	}
+
+	msg := fmt.Sprintf("Reconciliation took %s, exceeding the budget of %s", duration.String(), budget.String())
+	meta.SetResourceCondition(&kustomization, kustomizev1.ReconcileBudgetExceededCondition, metav1.ConditionTrue,
+		kustomizev1.BudgetExceededReason, msg)
+	r.event(ctx, kustomization, kustomization.Status.LastAttemptedRevision, events.EventSeverityInfo, msg, nil)
+	return kustomization
+}
+
 func (r *KustomizationReconciler) event(ctx context.Context, kustomization kustomizev1.Kustomization, revision, severity, msg string, metadata map[string]string) {
 	r.EventRecorder.Event(&kustomization, "Normal", severity, msg)
 	objRef, err := reference.GetReference(r.Scheme, &kustomization)
@@ -755,6 +1688,23 @@ func (r *KustomizationReconciler) event(ctx context.Context, kustomization kusto
 	}
 }
 
+func (r *KustomizationReconciler) recordPrune(ctx context.Context, kustomization kustomizev1.Kustomization, stats PruneStats) {
+	if r.PruneMetricsRecorder == nil {
+		return
+	}
+
+	objRef, err := reference.GetReference(r.Scheme, &kustomization)
+	if err != nil {
+		(logr.FromContext(ctx)).Error(err, "unable to record prune metrics")
+		return
+	}
+	r.PruneMetricsRecorder.RecordPruned(*objRef, stats.Deleted)
+	r.PruneMetricsRecorder.RecordFailures(*objRef, stats.Failed)
+	if stats.LimitReached {
+		r.PruneMetricsRecorder.RecordLimitReached(*objRef)
+	}
+}
+
 func (r *KustomizationReconciler) recordReadiness(ctx context.Context, kustomization kustomizev1.Kustomization) {
 	if r.MetricsRecorder == nil {
 		return
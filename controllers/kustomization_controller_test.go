@@ -18,6 +18,7 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -25,6 +26,8 @@ import (
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/clientcmd"
@@ -101,11 +104,13 @@ var _ = Describe("KustomizationReconciler", func() {
 		})
 
 		type refTestCase struct {
-			artifacts      []testserver.File
-			waitForReason  string
-			expectStatus   metav1.ConditionStatus
-			expectMessage  string
-			expectRevision string
+			artifacts           []testserver.File
+			waitForReason       string
+			expectStatus        metav1.ConditionStatus
+			expectMessage       string
+			expectRevision      string
+			expectResourceCount int
+			expectInventory     []kustomizev1.ResourceRef
 		}
 
 		DescribeTable("Kustomization tests", func(t refTestCase) {
@@ -191,6 +196,17 @@ var _ = Describe("KustomizationReconciler", func() {
 			Expect(cond.Status).To(Equal(t.expectStatus))
 			Expect(got.Status.LastAppliedRevision).To(Equal(t.expectRevision))
 
+			Expect(got.Status.EffectiveSpec).NotTo(BeNil())
+			Expect(got.Status.EffectiveSpec.Source).To(Equal(
+				fmt.Sprintf("%s/%s/%s", sourcev1.GitRepositoryKind, namespace.Name, repository.Name)))
+			Expect(got.Status.EffectiveSpec.Revision).To(Equal(t.expectRevision))
+			Expect(got.Status.EffectiveSpec.Path).To(Equal("."))
+			Expect(got.Status.EffectiveSpec.KubeConfigSecretName).To(Equal(kubeconfig.SecretRef.Name))
+			Expect(got.Status.EffectiveSpec.Timeout.Duration).To(Equal(got.GetTimeout()))
+			Expect(got.Status.ResourceCount).To(Equal(t.expectResourceCount))
+			Expect(got.Status.Inventory).NotTo(BeNil())
+			Expect(got.Status.Inventory.Entries).To(ConsistOf(t.expectInventory))
+
 			ns := &corev1.Namespace{}
 			Expect(k8sClient.Get(context.Background(), types.NamespacedName{Name: "test"}, ns)).Should(Succeed())
 			Expect(ns.Labels[fmt.Sprintf("%s/name", kustomizev1.GroupVersion.Group)]).To(Equal(kName.Name))
@@ -218,10 +234,368 @@ metadata:
 `,
 					},
 				},
-				waitForReason:  meta.ReconciliationSucceededReason,
-				expectStatus:   metav1.ConditionTrue,
-				expectRevision: "branch/commit1",
+				waitForReason:       meta.ReconciliationSucceededReason,
+				expectStatus:        metav1.ConditionTrue,
+				expectRevision:      "branch/commit1",
+				expectResourceCount: 2,
+				expectInventory: []kustomizev1.ResourceRef{
+					{ID: "_test__Namespace", Version: "v1"},
+					{ID: "test_test__ServiceAccount", Version: "v1"},
+				},
 			}),
 		)
+
+		It("prunes objects from the previous target namespace", func() {
+			artifact, err := httpServer.ArtifactFromFiles([]testserver.File{
+				{
+					Name: "configmap.yaml",
+					Body: `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+data:
+  key: value
+`,
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			url := fmt.Sprintf("%s/%s", httpServer.URL(), artifact)
+
+			var targets []string
+			for i := 0; i < 2; i++ {
+				target := &corev1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{Name: "target-test" + randStringRunes(5)},
+				}
+				Expect(k8sClient.Create(context.Background(), target)).Should(Succeed())
+				defer k8sClient.Delete(context.Background(), target)
+				targets = append(targets, target.Name)
+			}
+
+			repository := &sourcev1.GitRepository{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      randStringRunes(5),
+					Namespace: namespace.Name,
+				},
+				Spec: sourcev1.GitRepositorySpec{
+					URL:      "https://github.com/test/repository",
+					Interval: metav1.Duration{Duration: reconciliationInterval},
+				},
+				Status: sourcev1.GitRepositoryStatus{
+					Conditions: []metav1.Condition{
+						{
+							Type:               meta.ReadyCondition,
+							Status:             metav1.ConditionTrue,
+							LastTransitionTime: metav1.Now(),
+							Reason:             sourcev1.GitOperationSucceedReason,
+						},
+					},
+					URL: url,
+					Artifact: &sourcev1.Artifact{
+						Path:           url,
+						URL:            url,
+						Revision:       "branch/commit1",
+						LastUpdateTime: metav1.Now(),
+					},
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), repository)).Should(Succeed())
+			Expect(k8sClient.Status().Update(context.Background(), repository)).Should(Succeed())
+			defer k8sClient.Delete(context.Background(), repository)
+
+			kName := types.NamespacedName{
+				Name:      randStringRunes(5),
+				Namespace: namespace.Name,
+			}
+			k := &kustomizev1.Kustomization{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      kName.Name,
+					Namespace: kName.Namespace,
+				},
+				Spec: kustomizev1.KustomizationSpec{
+					KubeConfig: kubeconfig,
+					Interval:   metav1.Duration{Duration: reconciliationInterval},
+					Path:       "./",
+					Prune:      true,
+					SourceRef: kustomizev1.CrossNamespaceSourceReference{
+						Kind: sourcev1.GitRepositoryKind,
+						Name: repository.Name,
+					},
+					TargetNamespace: targets[0],
+					Validation:      "client",
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), k)).Should(Succeed())
+			defer k8sClient.Delete(context.Background(), k)
+
+			configMapIn := func(ns string) func() error {
+				return func() error {
+					return k8sClient.Get(context.Background(),
+						types.NamespacedName{Name: "app-config", Namespace: ns}, &corev1.ConfigMap{})
+				}
+			}
+			Eventually(configMapIn(targets[0]), timeout, interval).Should(Succeed())
+
+			Expect(k8sClient.Get(context.Background(), kName, k)).Should(Succeed())
+			k.Spec.TargetNamespace = targets[1]
+			Expect(k8sClient.Update(context.Background(), k)).Should(Succeed())
+
+			Eventually(configMapIn(targets[1]), timeout, interval).Should(Succeed())
+			Eventually(func() bool {
+				return apierrors.IsNotFound(configMapIn(targets[0])())
+			}, timeout, interval).Should(BeTrue())
+		})
+
+		It("refuses empty builds unless allowed", func() {
+			artifact, err := httpServer.ArtifactFromFiles([]testserver.File{
+				{
+					Name: "kustomization.yaml",
+					Body: `---
+apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources: []
+`,
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			url := fmt.Sprintf("%s/%s", httpServer.URL(), artifact)
+
+			repository := &sourcev1.GitRepository{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      randStringRunes(5),
+					Namespace: namespace.Name,
+				},
+				Spec: sourcev1.GitRepositorySpec{
+					URL:      "https://github.com/test/repository",
+					Interval: metav1.Duration{Duration: reconciliationInterval},
+				},
+				Status: sourcev1.GitRepositoryStatus{
+					Conditions: []metav1.Condition{
+						{
+							Type:               meta.ReadyCondition,
+							Status:             metav1.ConditionTrue,
+							LastTransitionTime: metav1.Now(),
+							Reason:             sourcev1.GitOperationSucceedReason,
+						},
+					},
+					URL: url,
+					Artifact: &sourcev1.Artifact{
+						Path:           url,
+						URL:            url,
+						Revision:       "branch/commit1",
+						LastUpdateTime: metav1.Now(),
+					},
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), repository)).Should(Succeed())
+			Expect(k8sClient.Status().Update(context.Background(), repository)).Should(Succeed())
+			defer k8sClient.Delete(context.Background(), repository)
+
+			kName := types.NamespacedName{
+				Name:      randStringRunes(5),
+				Namespace: namespace.Name,
+			}
+			k := &kustomizev1.Kustomization{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      kName.Name,
+					Namespace: kName.Namespace,
+				},
+				Spec: kustomizev1.KustomizationSpec{
+					KubeConfig: kubeconfig,
+					Interval:   metav1.Duration{Duration: reconciliationInterval},
+					Path:       "./",
+					Prune:      true,
+					SourceRef: kustomizev1.CrossNamespaceSourceReference{
+						Kind: sourcev1.GitRepositoryKind,
+						Name: repository.Name,
+					},
+					Validation: "client",
+				},
+			}
+			Expect(k8sClient.Create(context.Background(), k)).Should(Succeed())
+			defer k8sClient.Delete(context.Background(), k)
+
+			readyReason := func() string {
+				got := &kustomizev1.Kustomization{}
+				if err := k8sClient.Get(context.Background(), kName, got); err != nil {
+					return ""
+				}
+				if c := apimeta.FindStatusCondition(got.Status.Conditions, meta.ReadyCondition); c != nil {
+					return c.Reason
+				}
+				return ""
+			}
+			Eventually(readyReason, timeout, interval).Should(Equal(kustomizev1.EmptyRenderDetectedReason))
+
+			allow := true
+			Expect(k8sClient.Get(context.Background(), kName, k)).Should(Succeed())
+			k.Spec.AllowEmptyRender = &allow
+			Expect(k8sClient.Update(context.Background(), k)).Should(Succeed())
+			Eventually(readyReason, timeout, interval).Should(Equal(meta.ReconciliationSucceededReason))
+		})
 	})
 })
+
+var _ = Describe("checkFailureGrace", func() {
+	var (
+		kustomization kustomizev1.Kustomization
+		lastReady     *metav1.Condition
+	)
+
+	BeforeEach(func() {
+		kustomization = kustomizev1.Kustomization{
+			Spec: kustomizev1.KustomizationSpec{
+				FailureGrace: &kustomizev1.FailureGrace{Failures: 2},
+			},
+		}
+		lastReady = &metav1.Condition{
+			Type:    meta.ReadyCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  meta.ReconciliationSucceededReason,
+			Message: "Applied revision: main/abc",
+		}
+	})
+
+	fail := func() bool {
+		kustomization = kustomizev1.KustomizationNotReady(kustomization, "main/abc",
+			kustomizev1.HealthCheckFailedReason, "health check failed")
+		k, withinGrace := checkFailureGrace(kustomization, lastReady, errors.New("health check failed"))
+		kustomization = k
+		if c := apimeta.FindStatusCondition(k.Status.Conditions, meta.ReadyCondition); c != nil {
+			lastReady = c.DeepCopy()
+		}
+		return withinGrace
+	}
+
+	It("keeps the Ready condition for the tolerated failures", func() {
+		Expect(fail()).To(BeTrue())
+		Expect(apimeta.IsStatusConditionTrue(kustomization.Status.Conditions, meta.ReadyCondition)).To(BeTrue())
+		degraded := apimeta.FindStatusCondition(kustomization.Status.Conditions, kustomizev1.DegradedCondition)
+		Expect(degraded).NotTo(BeNil())
+		Expect(degraded.Reason).To(Equal(kustomizev1.HealthCheckFailedReason))
+
+		Expect(fail()).To(BeTrue())
+		Expect(fail()).To(BeFalse())
+		Expect(apimeta.IsStatusConditionFalse(kustomization.Status.Conditions, meta.ReadyCondition)).To(BeTrue())
+		Expect(apimeta.FindStatusCondition(kustomization.Status.Conditions, kustomizev1.DegradedCondition)).To(BeNil())
+		Expect(kustomization.Status.ConsecutiveFailures).To(Equal(3))
+	})
+
+	It("resets the failures on success", func() {
+		Expect(fail()).To(BeTrue())
+		kustomization, _ = checkFailureGrace(kustomization, lastReady, nil)
+		Expect(kustomization.Status.ConsecutiveFailures).To(BeZero())
+		Expect(kustomization.Status.FailingSince).To(BeNil())
+		Expect(apimeta.FindStatusCondition(kustomization.Status.Conditions, kustomizev1.DegradedCondition)).To(BeNil())
+	})
+
+	It("ends the grace after the duration", func() {
+		kustomization.Spec.FailureGrace = &kustomizev1.FailureGrace{Duration: &metav1.Duration{Duration: time.Minute}}
+		past := metav1.NewTime(time.Now().Add(-2 * time.Minute))
+		kustomization.Status.FailingSince = &past
+		Expect(fail()).To(BeFalse())
+	})
+})
+
+var _ = Describe("isPruneDeferred", func() {
+	const revision = "main/abc"
+
+	DescribeTable("defers the garbage collection until the revision is stable",
+		func(appliedRevision string, stableFor time.Duration, deferred bool) {
+			k := kustomizev1.Kustomization{
+				Spec: kustomizev1.KustomizationSpec{
+					Prune:            true,
+					PruneAfterStable: &metav1.Duration{Duration: 10 * time.Minute},
+				},
+				Status: kustomizev1.KustomizationStatus{
+					LastAppliedRevision: appliedRevision,
+				},
+			}
+			if stableFor > 0 {
+				since := metav1.NewTime(time.Now().Add(-stableFor))
+				k.Status.StableSince = &since
+			}
+			Expect(isPruneDeferred(k, revision)).To(Equal(deferred))
+		},
+		Entry("new revision", "main/def", 20*time.Minute, true),
+		Entry("not healthy", revision, time.Duration(0), true),
+		Entry("healthy for less than the duration", revision, 5*time.Minute, true),
+		Entry("stable", revision, 20*time.Minute, false),
+	)
+})
+
+var _ = Describe("applyIntervalDefaults", func() {
+	r := &KustomizationReconciler{
+		defaultInterval: 10 * time.Minute,
+		defaultTimeout:  2 * time.Minute,
+		minInterval:     time.Minute,
+	}
+
+	DescribeTable("sets the controller defaults and minimum",
+		func(spec kustomizev1.KustomizationSpec, interval, retryInterval, timeout time.Duration) {
+			k := r.applyIntervalDefaults(context.Background(), kustomizev1.Kustomization{Spec: spec})
+			Expect(k.Spec.Interval.Duration).To(Equal(interval))
+			Expect(k.GetRetryInterval()).To(Equal(retryInterval))
+			Expect(k.GetTimeout()).To(Equal(timeout))
+		},
+		Entry("defaults", kustomizev1.KustomizationSpec{},
+			10*time.Minute, 10*time.Minute, 2*time.Minute),
+		Entry("explicit values", kustomizev1.KustomizationSpec{
+			Interval:      metav1.Duration{Duration: 5 * time.Minute},
+			RetryInterval: &metav1.Duration{Duration: 2 * time.Minute},
+			Timeout:       &metav1.Duration{Duration: 3 * time.Minute},
+		}, 5*time.Minute, 2*time.Minute, 3*time.Minute),
+		Entry("below the minimum", kustomizev1.KustomizationSpec{
+			Interval:      metav1.Duration{Duration: 5 * time.Second},
+			RetryInterval: &metav1.Duration{Duration: time.Second},
+		}, time.Minute, time.Minute, 2*time.Minute),
+	)
+})
+
+var _ = Describe("checkRequiredMetadata", func() {
+	r := &KustomizationReconciler{
+		requiredLabels:      []string{"team"},
+		requiredAnnotations: []string{"owner"},
+	}
+
+	It("accepts a Kustomization with the required metadata", func() {
+		k := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Labels:      map[string]string{"team": "apps"},
+				Annotations: map[string]string{"owner": ""},
+			},
+		}
+		Expect(r.checkRequiredMetadata(k)).To(Succeed())
+	})
+
+	It("lists the missing labels and annotations", func() {
+		k := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{"team": "apps"},
+			},
+		}
+		err := r.checkRequiredMetadata(k)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("missing required metadata: label 'team', annotation 'owner'"))
+	})
+})
+
+var _ = Describe("requeueInterval", func() {
+	DescribeTable("requeues the reconciliations",
+		func(retryInterval *metav1.Duration, reconcileErr error, expected time.Duration) {
+			k := kustomizev1.Kustomization{
+				Spec: kustomizev1.KustomizationSpec{
+					Interval:      metav1.Duration{Duration: 10 * time.Minute},
+					RetryInterval: retryInterval,
+				},
+			}
+			Expect(requeueInterval(k, reconcileErr)).To(Equal(expected))
+		},
+		Entry("failure at the retry interval", &metav1.Duration{Duration: 30 * time.Second},
+			errors.New("apply failed"), 30*time.Second),
+		Entry("failure defaults to the interval", nil, errors.New("apply failed"), 10*time.Minute),
+		Entry("success at the interval", &metav1.Duration{Duration: 30 * time.Second}, nil, 10*time.Minute),
+	)
+})
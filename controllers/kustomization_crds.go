@@ -0,0 +1,215 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// splitCRDs writes the CustomResourceDefinitions and the other objects of the
+// manifests file in two separate files, when some of the other objects are
+// custom resources of the CRDs. Returns the names of the written files and the
+// CRDs, or no CRDs if the manifests don't need to be split.
+func splitCRDs(dirPath, manifestsFile string) (string, string, []unstructured.Unstructured, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dirPath, manifestsFile))
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	manifests, err := parseManifests(data)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	var crds []unstructured.Unstructured
+	var crdDocs, otherDocs []manifest
+	kinds := make(map[schema.GroupKind]bool)
+	for _, m := range manifests {
+		if obj := m.object; obj.Object != nil && isCRD(obj) {
+			group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+			kind, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "kind")
+			kinds[schema.GroupKind{Group: group, Kind: kind}] = true
+			crds = append(crds, obj)
+			crdDocs = append(crdDocs, m)
+			continue
+		}
+		otherDocs = append(otherDocs, m)
+	}
+
+	needsSplit := false
+	for _, m := range otherDocs {
+		if kinds[m.object.GroupVersionKind().GroupKind()] {
+			needsSplit = true
+			break
+		}
+	}
+	if !needsSplit {
+		return "", "", nil, nil
+	}
+
+	base := strings.TrimSuffix(manifestsFile, filepath.Ext(manifestsFile))
+	crdsFile := base + ".crds.yaml"
+	otherFile := base + ".resources.yaml"
+	if err := ioutil.WriteFile(filepath.Join(dirPath, crdsFile), joinManifests(crdDocs), os.ModePerm); err != nil {
+		return "", "", nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dirPath, otherFile), joinManifests(otherDocs), os.ModePerm); err != nil {
+		return "", "", nil, err
+	}
+	return crdsFile, otherFile, crds, nil
+}
+
+// checkCRDVersions returns an error listing the CRDs of the manifests file that
+// would stop serving a version stored on the cluster, unless AllowCRDVersionRemoval is set.
+func (r *KustomizationReconciler) checkCRDVersions(ctx context.Context, kubeClient client.Client,
+	kustomization kustomizev1.Kustomization, dirPath string) error {
+	if kustomization.Spec.AllowCRDVersionRemoval != nil && *kustomization.Spec.AllowCRDVersionRemoval {
+		return nil
+	}
+
+	manifests, err := ioutil.ReadFile(filepath.Join(dirPath, fmt.Sprintf("%s.yaml", kustomization.GetUID())))
+	if err != nil {
+		return err
+	}
+
+	conflicts, err := crdVersionConflicts(ctx, kubeClient, manifests)
+	if err != nil {
+		return fmt.Errorf("CRD versions lookup failed: %w", err)
+	}
+	if len(conflicts) > 0 {
+		return fmt.Errorf("CRD versions in use would no longer be served: %s", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// crdVersionConflicts returns the CRDs of the multi-doc YAML that don't serve
+// all the versions listed in the status.storedVersions of the CRD on the cluster,
+// as the custom resources stored in these versions would become unreadable.
+func crdVersionConflicts(ctx context.Context, kubeClient client.Client, manifests []byte) ([]string, error) {
+	objects, err := parseManifests(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []string
+	for _, m := range objects {
+		obj := m.object
+		if obj.Object == nil || !isCRD(obj) {
+			continue
+		}
+
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(schema.GroupVersionKind{
+			Group:   "apiextensions.k8s.io",
+			Version: "v1",
+			Kind:    "CustomResourceDefinition",
+		})
+		if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(&obj), live); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, fmt.Errorf("unable to get %s: %w", objectName(obj), err)
+		}
+
+		served := servedVersions(obj)
+		stored, _, _ := unstructured.NestedStringSlice(live.Object, "status", "storedVersions")
+		var removed []string
+		for _, version := range stored {
+			if !served[version] {
+				removed = append(removed, version)
+			}
+		}
+		if len(removed) > 0 {
+			conflicts = append(conflicts, fmt.Sprintf("%s (%s)", objectName(obj), strings.Join(removed, ", ")))
+		}
+	}
+	return conflicts, nil
+}
+
+// servedVersions returns the versions served by the CRD, from spec.versions
+// or from the spec.version of the apiextensions.k8s.io/v1beta1 CRDs.
+func servedVersions(crd unstructured.Unstructured) map[string]bool {
+	served := make(map[string]bool)
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, v := range versions {
+		version, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, ok := version["name"].(string); ok && version["served"] == true {
+			served[name] = true
+		}
+	}
+	if version, ok, _ := unstructured.NestedString(crd.Object, "spec", "version"); ok && len(versions) == 0 {
+		served[version] = true
+	}
+	return served
+}
+
+// waitForCRDs polls the CRDs until they report the Established condition,
+// bounded by the Kustomization timeout, and logs the time waited for each CRD.
+func waitForCRDs(ctx context.Context, kubeClient client.Client, kustomization kustomizev1.Kustomization,
+	crds []unstructured.Unstructured) error {
+	start := time.Now()
+	waited := make([]string, 0, len(crds))
+	for _, crd := range crds {
+		err := wait.PollImmediate(time.Second, kustomization.GetTimeout()-time.Since(start), func() (bool, error) {
+			live := &unstructured.Unstructured{}
+			live.SetGroupVersionKind(crd.GroupVersionKind())
+			if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(&crd), live); err != nil {
+				return false, client.IgnoreNotFound(err)
+			}
+			return isEstablished(*live), nil
+		})
+		if err != nil {
+			return fmt.Errorf("CustomResourceDefinition/%s not established after %s: %w",
+				crd.GetName(), time.Since(start).Round(time.Second).String(), err)
+		}
+		waited = append(waited, fmt.Sprintf("CustomResourceDefinition/%s after %s",
+			crd.GetName(), time.Since(start).Round(time.Millisecond).String()))
+	}
+
+	(logr.FromContext(ctx)).Info("CustomResourceDefinitions established", "crds", waited)
+	return nil
+}
+
+// isEstablished returns true if the CRD has the Established condition set to True.
+func isEstablished(crd unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == "Established" && condition["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
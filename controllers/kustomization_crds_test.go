@@ -0,0 +1,170 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+var _ = Describe("splitCRDs", func() {
+	const crd = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgets
+  scope: Namespaced
+`
+
+	var dirPath string
+
+	BeforeEach(func() {
+		var err error
+		dirPath, err = ioutil.TempDir("", "split-crds")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dirPath)
+	})
+
+	It("splits the CRDs from their custom resources", func() {
+		Expect(ioutil.WriteFile(filepath.Join(dirPath, "manifests.yaml"), []byte(crd+`---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: widget
+  namespace: default
+`), 0644)).To(Succeed())
+
+		crdsFile, otherFile, crds, err := splitCRDs(dirPath, "manifests.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(crds).To(HaveLen(1))
+		Expect(crds[0].GetName()).To(Equal("widgets.example.com"))
+
+		data, err := ioutil.ReadFile(filepath.Join(dirPath, crdsFile))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring("kind: CustomResourceDefinition"))
+		Expect(string(data)).NotTo(ContainSubstring("name: widget\n"))
+
+		data, err = ioutil.ReadFile(filepath.Join(dirPath, otherFile))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring("name: widget\n"))
+		Expect(string(data)).NotTo(ContainSubstring("CustomResourceDefinition"))
+	})
+
+	It("doesn't split CRDs without custom resources", func() {
+		Expect(ioutil.WriteFile(filepath.Join(dirPath, "manifests.yaml"), []byte(crd+`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+  namespace: default
+`), 0644)).To(Succeed())
+
+		_, _, crds, err := splitCRDs(dirPath, "manifests.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(crds).To(BeEmpty())
+	})
+})
+
+var _ = Describe("crdVersionConflicts", func() {
+	crdWithVersions := func(name string, served map[string]bool, storage string) string {
+		crd := fmt.Sprintf(`apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: %s.example.com
+spec:
+  group: example.com
+  names:
+    kind: %s
+    plural: %s
+  scope: Namespaced
+  versions:
+`, name, strings.Title(name), name)
+		for _, version := range []string{"v1alpha1", "v1"} {
+			if _, ok := served[version]; !ok {
+				continue
+			}
+			crd += fmt.Sprintf(`  - name: %s
+    served: %t
+    storage: %t
+    schema:
+      openAPIV3Schema:
+        type: object
+`, version, served[version], version == storage)
+		}
+		return crd
+	}
+
+	var (
+		name         string
+		live         *unstructured.Unstructured
+		directClient client.Client
+	)
+
+	BeforeEach(func() {
+		var err error
+		directClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+		Expect(err).NotTo(HaveOccurred())
+
+		name = "gadgets" + randStringRunes(5)
+		live = &unstructured.Unstructured{}
+		Expect(yaml.Unmarshal([]byte(crdWithVersions(name, map[string]bool{"v1alpha1": true, "v1": true}, "v1")), &live.Object)).To(Succeed())
+		Expect(directClient.Create(context.Background(), live)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(directClient.Delete(context.Background(), live)).To(Succeed())
+	})
+
+	It("reports the stored versions that are no longer served", func() {
+		conflicts, err := crdVersionConflicts(context.Background(), directClient,
+			[]byte(crdWithVersions(name, map[string]bool{"v1alpha1": true}, "v1alpha1")))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conflicts).To(Equal([]string{
+			fmt.Sprintf("CustomResourceDefinition/%s.example.com (v1)", name),
+		}))
+
+		conflicts, err = crdVersionConflicts(context.Background(), directClient,
+			[]byte(crdWithVersions(name, map[string]bool{"v1alpha1": true, "v1": false}, "v1alpha1")))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conflicts).To(HaveLen(1))
+	})
+
+	It("accepts the CRDs serving the stored versions", func() {
+		conflicts, err := crdVersionConflicts(context.Background(), directClient,
+			[]byte(crdWithVersions(name, map[string]bool{"v1alpha1": false, "v1": true}, "v1")))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conflicts).To(BeEmpty())
+	})
+})
@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// createOnlyValue is the value of the apply annotation that marks the
+// objects created when absent from the cluster and never updated afterwards.
+const createOnlyValue = "create-only"
+
+// isCreateOnly returns true if the object has the
+// 'kustomize.toolkit.fluxcd.io/apply: create-only' annotation.
+func isCreateOnly(obj unstructured.Unstructured) bool {
+	return obj.GetAnnotations()[fmt.Sprintf("%s/apply", kustomizev1.GroupVersion.Group)] == createOnlyValue
+}
+
+// filterCreateOnly rewrites the manifests file without
+// the create-only objects that exist on the cluster.
+func (r *KustomizationReconciler) filterCreateOnly(ctx context.Context, kubeClient client.Client,
+	kustomization kustomizev1.Kustomization, dirPath string) error {
+	manifestsFile := filepath.Join(dirPath, fmt.Sprintf("%s.yaml", kustomization.GetUID()))
+	manifests, err := ioutil.ReadFile(manifestsFile)
+	if err != nil {
+		return err
+	}
+
+	filtered, skipped, err := skipCreateOnly(ctx, kubeClient, manifests)
+	if err != nil {
+		return fmt.Errorf("create-only objects lookup failed: %w", err)
+	}
+	if skipped == 0 {
+		return nil
+	}
+
+	(logr.FromContext(ctx)).V(1).Info(fmt.Sprintf("Skipped %d existing create-only objects", skipped))
+	return ioutil.WriteFile(manifestsFile, filtered, os.ModePerm)
+}
+
+// skipCreateOnly removes from the multi-doc YAML the create-only objects that
+// exist on the cluster, and returns the remaining manifests and the number of
+// skipped objects. The labels set by the controller are patched on the skipped
+// objects, so that the garbage collector doesn't consider them stale.
+func skipCreateOnly(ctx context.Context, kubeClient client.Client, manifests []byte) ([]byte, int, error) {
+	objects, err := parseManifests(manifests)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var docs []manifest
+	skipped := 0
+	for _, m := range objects {
+		obj := m.object
+		if obj.Object == nil || !isCreateOnly(obj) {
+			docs = append(docs, m)
+			continue
+		}
+
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		err := kubeClient.Get(ctx, types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}, live)
+		if apierrors.IsNotFound(err) {
+			docs = append(docs, m)
+			continue
+		} else if err != nil {
+			return nil, 0, fmt.Errorf("unable to get %s: %w", objectName(obj), err)
+		}
+
+		labels := make(map[string]string)
+		for key, value := range obj.GetLabels() {
+			if strings.HasPrefix(key, kustomizev1.GroupVersion.Group+"/") {
+				labels[key] = value
+			}
+		}
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{"labels": labels},
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := kubeClient.Patch(ctx, live, client.RawPatch(types.MergePatchType, patch)); err != nil {
+			return nil, 0, fmt.Errorf("unable to label %s: %w", objectName(obj), err)
+		}
+		skipped++
+	}
+	return joinManifests(docs), skipped, nil
+}
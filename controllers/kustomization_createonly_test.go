@@ -0,0 +1,109 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("skipCreateOnly", func() {
+	var (
+		namespace    *corev1.Namespace
+		directClient client.Client
+	)
+
+	BeforeEach(func() {
+		var err error
+		directClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+		Expect(err).NotTo(HaveOccurred())
+
+		namespace = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "create-only-" + randStringRunes(5)},
+		}
+		Expect(directClient.Create(context.Background(), namespace)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(directClient.Delete(context.Background(), namespace)).To(Succeed())
+	})
+
+	It("doesn't overwrite the existing create-only objects", func() {
+		seedKey := types.NamespacedName{Name: "seed", Namespace: namespace.Name}
+		Expect(directClient.Create(context.Background(), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      seedKey.Name,
+				Namespace: seedKey.Namespace,
+				Labels:    gcLabels("app", namespace.Name, "old"),
+			},
+			Data: map[string]string{"key": "updated"},
+		})).To(Succeed())
+
+		manifests := fmt.Sprintf(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: seed
+  namespace: %[1]s
+  annotations:
+    kustomize.toolkit.fluxcd.io/apply: create-only
+  labels:
+    kustomize.toolkit.fluxcd.io/name: app
+    kustomize.toolkit.fluxcd.io/namespace: %[1]s
+    kustomize.toolkit.fluxcd.io/checksum: new
+data:
+  key: initial
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: missing-seed
+  namespace: %[1]s
+  annotations:
+    kustomize.toolkit.fluxcd.io/apply: create-only
+data:
+  key: initial
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+  namespace: %[1]s
+data:
+  key: value
+`, namespace.Name)
+
+		out, skipped, err := skipCreateOnly(context.Background(), directClient, []byte(manifests))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(skipped).To(Equal(1))
+		Expect(string(out)).NotTo(ContainSubstring("name: seed"))
+		Expect(string(out)).To(ContainSubstring("name: missing-seed"))
+		Expect(string(out)).To(ContainSubstring("name: app-config"))
+
+		seed := &corev1.ConfigMap{}
+		Expect(directClient.Get(context.Background(), seedKey, seed)).To(Succeed())
+		Expect(seed.Data).To(HaveKeyWithValue("key", "updated"))
+		Expect(seed.Labels).To(HaveKeyWithValue("kustomize.toolkit.fluxcd.io/checksum", "new"))
+	})
+})
@@ -23,6 +23,9 @@ import (
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
 
 	securejoin "github.com/cyphar/filepath-securejoin"
 	"go.mozilla.org/sops/v3"
@@ -33,7 +36,10 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/konfig"
 	"sigs.k8s.io/kustomize/api/resource"
+	kustypes "sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/yaml"
 
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
@@ -75,47 +81,236 @@ func (kd *KustomizeDecryptor) Decrypt(res *resource.Resource) (*resource.Resourc
 
 	if kd.kustomization.Spec.Decryption != nil && kd.kustomization.Spec.Decryption.Provider == DecryptionProviderSOPS &&
 		bytes.Contains(out, []byte("sops:")) && bytes.Contains(out, []byte("mac: ENC[")) {
-		store := common.StoreForFormat(formats.Yaml)
-
-		tree, err := store.LoadEncryptedFile(out)
+		data, err := kd.decrypt(out, formats.Yaml)
 		if err != nil {
-			return nil, fmt.Errorf("LoadEncryptedFile: %w", err)
+			return nil, err
 		}
 
-		key, err := tree.Metadata.GetDataKeyWithKeyServices(
-			[]keyservice.KeyServiceClient{
-				intkeyservice.NewLocalClient(intkeyservice.NewServer(false, kd.homeDir)),
-			},
-		)
+		jsonData, err := yaml.YAMLToJSON(data)
 		if err != nil {
-			if userErr, ok := err.(sops.UserError); ok {
-				err = fmt.Errorf(userErr.UserError())
-			}
-			return nil, fmt.Errorf("GetDataKey: %w", err)
+			return nil, fmt.Errorf("YAMLToJSON: %w", err)
 		}
 
-		cipher := aes.NewCipher()
-		if _, err := tree.Decrypt(key, cipher); err != nil {
-			return nil, fmt.Errorf("AES decrypt: %w", err)
+		err = res.UnmarshalJSON(jsonData)
+		if err != nil {
+			return nil, fmt.Errorf("UnmarshalJSON: %w", err)
 		}
+		return res, nil
+	}
+	return nil, nil
+}
 
-		data, err := store.EmitPlainFile(tree.Branches)
+// DecryptFiles decrypts in place the SOPS encrypted YAML, JSON, dotenv and INI
+// files read by the build of dirPath, so that the kustomize generators and
+// patches read the plain values. The files of the artifact that are not part
+// of the build, e.g. the ones of other clusters encrypted with other keys,
+// and the files that are not encrypted are left untouched.
+func (kd *KustomizeDecryptor) DecryptFiles(rootPath, dirPath string) error {
+	if kd.kustomization.Spec.Decryption == nil || kd.kustomization.Spec.Decryption.Provider != DecryptionProviderSOPS {
+		return nil
+	}
+
+	files, err := buildFiles(filesys.MakeFsOnDisk(), rootPath, dirPath)
+	if err != nil {
+		return err
+	}
+	for _, path := range files {
+		info, err := os.Lstat(path)
 		if err != nil {
-			return nil, fmt.Errorf("EmitPlainFile: %w", err)
+			return err
 		}
-
-		jsonData, err := yaml.YAMLToJSON(data)
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		data, err := ioutil.ReadFile(path)
 		if err != nil {
-			return nil, fmt.Errorf("YAMLToJSON: %w", err)
+			return err
+		}
+		if !isSOPSEncrypted(data) {
+			continue
 		}
 
-		err = res.UnmarshalJSON(jsonData)
+		name, err := filepath.Rel(rootPath, path)
 		if err != nil {
-			return nil, fmt.Errorf("UnmarshalJSON: %w", err)
+			name = path
+		}
+		plain, err := kd.decrypt(data, formats.FormatForPath(path))
+		if err != nil {
+			return fmt.Errorf("decryption failed for '%s': %w", name, err)
+		}
+		if err := ioutil.WriteFile(path, plain, info.Mode()); err != nil {
+			return err
 		}
-		return res, nil
 	}
-	return nil, nil
+	return nil
+}
+
+// buildFiles returns the local files read by the build of dirPath: the
+// resources, generator sources and patches listed in its kustomization.yaml,
+// and in the local bases it refers to, or all the files under a directory
+// without a kustomization.yaml, as the generated one includes them.
+// The files outside rootPath are ignored, and the symlinks are not followed.
+func buildFiles(fs filesys.FileSystem, rootPath, dirPath string) ([]string, error) {
+	set := &buildFileSet{
+		fs:       fs,
+		rootPath: rootPath,
+		files:    make(map[string]bool),
+		visited:  make(map[string]bool),
+	}
+	if err := set.addDir(dirPath, 0); err != nil {
+		return nil, err
+	}
+
+	files := make([]string, 0, len(set.files))
+	for path := range set.files {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+type buildFileSet struct {
+	fs       filesys.FileSystem
+	rootPath string
+	files    map[string]bool
+	visited  map[string]bool
+}
+
+func (s *buildFileSet) addDir(dirPath string, depth int) error {
+	if depth > 10 || s.visited[dirPath] {
+		return nil
+	}
+	s.visited[dirPath] = true
+
+	var kfile string
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		if path := filepath.Join(dirPath, name); s.fs.Exists(path) && !s.fs.IsDir(path) {
+			kfile = path
+			break
+		}
+	}
+	if kfile == "" {
+		return s.fs.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.Mode().IsRegular() {
+				s.files[path] = true
+			}
+			return nil
+		})
+	}
+
+	data, err := s.fs.ReadFile(kfile)
+	if err != nil {
+		return err
+	}
+	kus := kustypes.Kustomization{}
+	if err := yaml.Unmarshal(data, &kus); err != nil {
+		return fmt.Errorf("unable to parse '%s': %w", kfile, err)
+	}
+
+	var files []string
+	for _, gen := range kus.ConfigMapGenerator {
+		files = append(files, generatorFiles(gen.KvPairSources)...)
+	}
+	for _, gen := range kus.SecretGenerator {
+		files = append(files, generatorFiles(gen.KvPairSources)...)
+	}
+	for _, patch := range kus.PatchesStrategicMerge {
+		files = append(files, string(patch))
+	}
+	for _, patch := range kus.Patches {
+		if patch.Path != "" {
+			files = append(files, patch.Path)
+		}
+	}
+
+	for _, resource := range append(append(kus.Resources, kus.Bases...), kus.Components...) {
+		path := filepath.Join(dirPath, resource)
+		if !s.isLocal(path) {
+			continue
+		}
+		if s.fs.IsDir(path) {
+			if err := s.addDir(path, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+		s.files[path] = true
+	}
+	for _, file := range files {
+		if path := filepath.Join(dirPath, file); s.isLocal(path) && !s.fs.IsDir(path) {
+			s.files[path] = true
+		}
+	}
+	return nil
+}
+
+// isLocal returns true if the path exists under the artifact root,
+// the remote bases and the inline patches are not local files.
+func (s *buildFileSet) isLocal(path string) bool {
+	rel, err := filepath.Rel(s.rootPath, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	return s.fs.Exists(path)
+}
+
+// generatorFiles returns the paths of the files and env files of a generator,
+// without the optional key of the 'key=path' file sources.
+func generatorFiles(sources kustypes.KvPairSources) []string {
+	var files []string
+	for _, source := range sources.FileSources {
+		if i := strings.Index(source, "="); i >= 0 {
+			source = source[i+1:]
+		}
+		files = append(files, source)
+	}
+	files = append(files, sources.EnvSources...)
+	if sources.EnvSource != "" {
+		files = append(files, sources.EnvSource)
+	}
+	return files
+}
+
+// isSOPSEncrypted returns true if the data contains the SOPS metadata,
+// the MAC of the file is stored encrypted in all the formats.
+func isSOPSEncrypted(data []byte) bool {
+	return bytes.Contains(data, []byte("sops")) && bytes.Contains(data, []byte("ENC[AES256_GCM,"))
+}
+
+// decrypt returns the plain content of the SOPS encrypted data in the given format.
+func (kd *KustomizeDecryptor) decrypt(data []byte, format formats.Format) ([]byte, error) {
+	store := common.StoreForFormat(format)
+
+	tree, err := store.LoadEncryptedFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("LoadEncryptedFile: %w", err)
+	}
+
+	key, err := tree.Metadata.GetDataKeyWithKeyServices(
+		[]keyservice.KeyServiceClient{
+			intkeyservice.NewLocalClient(intkeyservice.NewServer(false, kd.homeDir)),
+		},
+	)
+	if err != nil {
+		if userErr, ok := err.(sops.UserError); ok {
+			err = fmt.Errorf(userErr.UserError())
+		}
+		return nil, fmt.Errorf("GetDataKey: %w", err)
+	}
+
+	cipher := aes.NewCipher()
+	if _, err := tree.Decrypt(key, cipher); err != nil {
+		return nil, fmt.Errorf("AES decrypt: %w", err)
+	}
+
+	plain, err := store.EmitPlainFile(tree.Branches)
+	if err != nil {
+		return nil, fmt.Errorf("EmitPlainFile: %w", err)
+	}
+	return plain, nil
 }
 
 func (kd *KustomizeDecryptor) ImportKeys(ctx context.Context) error {
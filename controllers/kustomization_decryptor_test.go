@@ -0,0 +1,154 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var _ = Describe("KustomizeDecryptor DecryptFiles", func() {
+	const plainConfig = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+data:
+  key: value
+`
+	// the data key can't be decrypted, as no OpenPGP key is imported
+	const encryptedSecret = `apiVersion: v1
+kind: Secret
+metadata:
+  name: app-secret
+stringData:
+  password: ENC[AES256_GCM,data:Tr7o1Q==,iv:1=,tag:2=,type:str]
+sops:
+  kms: []
+  gcp_kms: []
+  azure_kv: []
+  hc_vault: []
+  lastmodified: "2021-03-01T10:00:00Z"
+  mac: ENC[AES256_GCM,data:oRkN,iv:3=,tag:4=,type:str]
+  pgp:
+  - created_at: "2021-03-01T10:00:00Z"
+    enc: |
+      -----BEGIN PGP MESSAGE-----
+
+      hQEMAyNaq8xjmM9RAQf/
+      -----END PGP MESSAGE-----
+    fp: FBC7B9E2A4F9289AC0C1D4843D16CEE4A27381B4
+  encrypted_regex: ^(data|stringData)$
+  version: 3.6.1
+`
+
+	var (
+		tmpDir  string
+		homeDir string
+		dec     *KustomizeDecryptor
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "decrypt-files")
+		Expect(err).NotTo(HaveOccurred())
+		homeDir, err = ioutil.TempDir("", "decrypt-files-home")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(os.MkdirAll(filepath.Join(tmpDir, "app", "secrets"), os.ModePerm)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(tmpDir, "app", "configmap.yaml"), []byte(plainConfig), 0644)).To(Succeed())
+
+		dec = NewDecryptor(nil, kustomizev1.Kustomization{
+			Spec: kustomizev1.KustomizationSpec{
+				Decryption: &kustomizev1.Decryption{Provider: DecryptionProviderSOPS},
+			},
+		}, homeDir)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+		os.RemoveAll(homeDir)
+	})
+
+	It("leaves the files that are not encrypted untouched", func() {
+		Expect(dec.DecryptFiles(tmpDir, tmpDir)).To(Succeed())
+
+		data, err := ioutil.ReadFile(filepath.Join(tmpDir, "app", "configmap.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal(plainConfig))
+	})
+
+	It("fails with the name of the file that can't be decrypted", func() {
+		Expect(ioutil.WriteFile(filepath.Join(tmpDir, "app", "secrets", "secret.yaml"),
+			[]byte(encryptedSecret), 0644)).To(Succeed())
+
+		err := dec.DecryptFiles(tmpDir, tmpDir)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(HavePrefix("decryption failed for 'app/secrets/secret.yaml': "))
+	})
+
+	It("decrypts only the files of the build", func() {
+		for _, dir := range []string{"base", "cluster-a", "cluster-b"} {
+			Expect(os.MkdirAll(filepath.Join(tmpDir, dir), os.ModePerm)).To(Succeed())
+		}
+		Expect(ioutil.WriteFile(filepath.Join(tmpDir, "base", "configmap.yaml"), []byte(plainConfig), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(tmpDir, "cluster-a", "kustomization.yaml"), []byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- ../base
+`), 0644)).To(Succeed())
+		// the secret of another cluster, encrypted with a key this Kustomization doesn't have
+		Expect(ioutil.WriteFile(filepath.Join(tmpDir, "cluster-b", "secret.yaml"), []byte(encryptedSecret), 0644)).To(Succeed())
+
+		Expect(dec.DecryptFiles(tmpDir, filepath.Join(tmpDir, "cluster-a"))).To(Succeed())
+
+		err := dec.DecryptFiles(tmpDir, filepath.Join(tmpDir, "cluster-b"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(HavePrefix("decryption failed for 'cluster-b/secret.yaml': "))
+	})
+
+	It("decrypts the generator sources of the build", func() {
+		Expect(ioutil.WriteFile(filepath.Join(tmpDir, "app", "secrets", "secret.yaml"),
+			[]byte(encryptedSecret), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(tmpDir, "app", "kustomization.yaml"), []byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- configmap.yaml
+secretGenerator:
+- name: app
+  files:
+  - secret.yaml=secrets/secret.yaml
+`), 0644)).To(Succeed())
+
+		err := dec.DecryptFiles(tmpDir, filepath.Join(tmpDir, "app"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(HavePrefix("decryption failed for 'app/secrets/secret.yaml': "))
+	})
+
+	It("skips the files when the decryption is not configured", func() {
+		Expect(ioutil.WriteFile(filepath.Join(tmpDir, "app", "secrets", "secret.yaml"),
+			[]byte(encryptedSecret), 0644)).To(Succeed())
+
+		dec = NewDecryptor(nil, kustomizev1.Kustomization{}, homeDir)
+		Expect(dec.DecryptFiles(tmpDir, tmpDir)).To(Succeed())
+	})
+})
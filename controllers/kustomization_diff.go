@@ -0,0 +1,129 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	sigyaml "sigs.k8s.io/yaml"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// debugLevel is the log verbosity at which the rendered
+// manifests and their diff with the cluster state are logged.
+const debugLevel = 4
+
+// logRenderedDiff logs the manifests, with the Secrets values redacted, and
+// the kubectl diff against the cluster state. Errors are logged, as the
+// output is meant for debugging only.
+func (r *KustomizationReconciler) logRenderedDiff(ctx context.Context, kustomization kustomizev1.Kustomization, imp *KustomizeImpersonation, dirPath string) {
+	log := (logr.FromContext(ctx)).V(debugLevel)
+	manifestsFile := fmt.Sprintf("%s.yaml", kustomization.GetUID())
+
+	manifests, err := ioutil.ReadFile(filepath.Join(dirPath, manifestsFile))
+	if err != nil {
+		log.Error(err, "unable to read the rendered manifests")
+		return
+	}
+	redacted, err := redactSecrets(manifests)
+	if err != nil {
+		log.Error(err, "unable to redact the rendered manifests")
+		return
+	}
+	log.Info("Rendered manifests", "manifests", string(redacted))
+
+	cmd := fmt.Sprintf("cd %s && kubectl diff -f %s --server-side --field-manager=%s --cache-dir=/tmp",
+		dirPath, manifestsFile, "kustomize-controller")
+	if kustomization.Spec.KubeConfig != nil {
+		kubeConfig, err := imp.WriteKubeConfig(ctx)
+		if err != nil {
+			log.Error(err, "unable to diff the rendered manifests")
+			return
+		}
+		cmd = fmt.Sprintf("%s --kubeconfig=%s", cmd, kubeConfig)
+	} else if imp.ServiceAccountName() != "" {
+		saToken, err := imp.GetServiceAccountToken(ctx)
+		if err != nil {
+			log.Error(err, "unable to diff the rendered manifests")
+			return
+		}
+		cmd = fmt.Sprintf("%s --token %s", cmd, saToken)
+	}
+
+	diffCtx, cancel := context.WithTimeout(ctx, kustomization.GetTimeout())
+	defer cancel()
+
+	// kubectl diff masks the Secrets values and exits with 1 when
+	// there are differences, other exit codes are failures.
+	output, err := exec.CommandContext(diffCtx, "/bin/sh", "-c", cmd).CombinedOutput()
+	var exitErr *exec.ExitError
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+		log.Error(err, "unable to diff the rendered manifests", "output", string(output))
+		return
+	}
+	log.Info("Rendered manifests diff", "diff", string(output))
+}
+
+// redactSecrets returns the multi-doc YAML with the values of
+// the Secrets data and stringData replaced by '***'.
+func redactSecrets(manifests []byte) ([]byte, error) {
+	var out bytes.Buffer
+	reader := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifests), 2048)
+	for {
+		var obj unstructured.Unstructured
+		err := reader.Decode(&obj)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if obj.Object == nil {
+			continue
+		}
+
+		if obj.GetKind() == "Secret" && obj.GroupVersionKind().Group == "" {
+			for _, field := range []string{"data", "stringData"} {
+				values, ok := obj.Object[field].(map[string]interface{})
+				if !ok {
+					continue
+				}
+				for key := range values {
+					values[key] = "***"
+				}
+			}
+		}
+
+		b, err := sigyaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, err
+		}
+		out.WriteString("---\n")
+		out.Write(b)
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,49 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("redactSecrets", func() {
+	It("masks the Secrets values only", func() {
+		out, err := redactSecrets([]byte(`---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app-credentials
+data:
+  password: c2VjcmV0
+stringData:
+  token: secret
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+data:
+  key: value
+`))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).NotTo(ContainSubstring("c2VjcmV0"))
+		Expect(string(out)).NotTo(ContainSubstring("token: secret"))
+		Expect(string(out)).To(ContainSubstring("password: '***'"))
+		Expect(string(out)).To(ContainSubstring("key: value"))
+	})
+})
@@ -0,0 +1,145 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// reportDrift compares each object of the manifests with its live counterpart,
+// without modifying the cluster. An object is in sync when all the fields set
+// in the manifest have the same value on the cluster, fields added by the API
+// server or other controllers are ignored.
+func reportDrift(ctx context.Context, kubeClient client.Client, manifests []byte, revision string) (*kustomizev1.DriftReport, error) {
+	report := &kustomizev1.DriftReport{
+		Revision: revision,
+	}
+
+	reader := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifests), 2048)
+	for {
+		var obj unstructured.Unstructured
+		err := reader.Decode(&obj)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if obj.Object == nil {
+			continue
+		}
+
+		id := fmt.Sprintf("%s/%s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		err = kubeClient.Get(ctx, types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}, live)
+		switch {
+		case apierrors.IsNotFound(err):
+			report.Missing = append(report.Missing, id)
+		case err != nil:
+			return nil, fmt.Errorf("unable to get %s: %w", id, err)
+		case isCreateOnly(obj):
+			// create-only objects are never updated
+			report.InSync++
+		case isDrifted(obj, *live):
+			report.Drifted = append(report.Drifted, id)
+		default:
+			report.InSync++
+		}
+	}
+
+	return report, nil
+}
+
+// isDrifted returns true if a field of the desired object, except for the
+// metadata other than labels and annotations, differs from the live object.
+// The checksum label is ignored, as it changes with every source revision.
+func isDrifted(desired, live unstructured.Unstructured) bool {
+	desired = *desired.DeepCopy()
+	labels := desired.GetLabels()
+	delete(labels, fmt.Sprintf("%s/checksum", kustomizev1.GroupVersion.Group))
+	desired.SetLabels(labels)
+
+	for key, value := range desired.Object {
+		switch key {
+		case "metadata":
+			if !isSubset(desired.GetLabels(), live.GetLabels()) ||
+				!isSubset(desired.GetAnnotations(), live.GetAnnotations()) {
+				return true
+			}
+		case "status":
+		default:
+			if !isSubset(value, live.Object[key]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isSubset returns true if all the fields of desired are set to the same
+// value in live. Lists must have the same length and their items are
+// compared in order.
+func isSubset(desired, live interface{}) bool {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		l, ok := live.(map[string]interface{})
+		if !ok {
+			return len(d) == 0 && live == nil
+		}
+		for key, value := range d {
+			if !isSubset(value, l[key]) {
+				return false
+			}
+		}
+		return true
+	case map[string]string:
+		l, _ := live.(map[string]string)
+		for key, value := range d {
+			if lv, ok := l[key]; !ok || lv != value {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		l, ok := live.([]interface{})
+		if !ok || len(l) != len(d) {
+			return len(d) == 0 && live == nil
+		}
+		for i := range d {
+			if !isSubset(d[i], l[i]) {
+				return false
+			}
+		}
+		return true
+	case nil:
+		return true
+	default:
+		return reflect.DeepEqual(desired, live)
+	}
+}
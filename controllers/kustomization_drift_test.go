@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+var _ = Describe("isDrifted", func() {
+	const desired = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+  namespace: app
+  labels:
+    app: web
+    kustomize.toolkit.fluxcd.io/checksum: abc
+data:
+  key: value
+`
+
+	toObject := func(manifest string) unstructured.Unstructured {
+		obj := unstructured.Unstructured{}
+		Expect(yaml.Unmarshal([]byte(manifest), &obj.Object)).To(Succeed())
+		return obj
+	}
+
+	DescribeTable("compares the manifest with the live object", func(live string, drifted bool) {
+		Expect(isDrifted(toObject(desired), toObject(live))).To(Equal(drifted))
+	},
+		Entry("in sync with server-side fields", `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+  namespace: app
+  uid: 8f0b3c1e
+  resourceVersion: "42"
+  labels:
+    app: web
+    kustomize.toolkit.fluxcd.io/checksum: def
+  annotations:
+    kubectl.kubernetes.io/last-applied-configuration: "{}"
+data:
+  key: value
+`, false),
+		Entry("changed data", `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+  namespace: app
+  labels:
+    app: web
+data:
+  key: other
+`, true),
+		Entry("removed label", `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+  namespace: app
+data:
+  key: value
+`, true),
+	)
+})
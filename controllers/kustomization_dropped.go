@@ -0,0 +1,125 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/fluxcd/pkg/runtime/events"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/resid"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// checkDroppedResources reports the objects of the manifests listed in the
+// kustomization.yaml that are missing from the build output, and returns an
+// error when the DroppedResourcesPolicy is 'Fail'.
+func (r *KustomizationReconciler) checkDroppedResources(ctx context.Context,
+	kustomization kustomizev1.Kustomization, fs filesys.FileSystem, revision, dirPath string) error {
+	if kustomization.Spec.DroppedResourcesPolicy == "" {
+		return nil
+	}
+
+	dropped, err := droppedResources(fs, dirPath)
+	if err != nil {
+		return fmt.Errorf("dropped resources check failed: %w", err)
+	}
+	if len(dropped) == 0 {
+		return nil
+	}
+
+	msg := fmt.Sprintf("objects missing from the build output: %s", strings.Join(dropped, ", "))
+	if kustomization.Spec.DroppedResourcesPolicy == kustomizev1.DroppedResourcesFail {
+		return errors.New(msg)
+	}
+
+	(logr.FromContext(ctx)).Info(msg)
+	r.event(ctx, kustomization, revision, events.EventSeverityError, msg, nil)
+	return nil
+}
+
+// droppedResources returns the objects of the manifest files listed as
+// resources in the kustomization.yaml of dirPath that are not found, by their
+// original or current id, among the objects of the build output.
+func droppedResources(fs filesys.FileSystem, dirPath string) ([]string, error) {
+	data, err := fs.ReadFile(filepath.Join(dirPath, konfig.DefaultKustomizationFileName()))
+	if err != nil {
+		return nil, err
+	}
+	kus := kustypes.Kustomization{}
+	if err := yaml.Unmarshal(data, &kus); err != nil {
+		return nil, err
+	}
+
+	var inputs []unstructured.Unstructured
+	for _, resource := range kus.Resources {
+		path := filepath.Join(dirPath, resource)
+		if resource == namespaceFileName || !fs.Exists(path) || fs.IsDir(path) {
+			continue
+		}
+		content, err := fs.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		manifests, err := parseManifests(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", resource, err)
+		}
+		for _, m := range manifests {
+			obj := m.object
+			if obj.Object == nil || obj.GetKind() == "" || obj.GetName() == "" || obj.IsList() {
+				continue
+			}
+			inputs = append(inputs, obj)
+		}
+	}
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+
+	m, err := buildKustomization(fs, dirPath)
+	if err != nil {
+		return nil, fmt.Errorf("kustomize build failed: %w", err)
+	}
+	rendered := make(map[string]bool)
+	key := func(gvk resid.Gvk, name string) string {
+		return fmt.Sprintf("%s/%s/%s", gvk.Group, gvk.Kind, name)
+	}
+	for _, res := range m.Resources() {
+		rendered[key(res.OrgId().Gvk, res.OrgId().Name)] = true
+		rendered[key(res.CurId().Gvk, res.CurId().Name)] = true
+	}
+
+	var dropped []string
+	for _, obj := range inputs {
+		gvk := obj.GroupVersionKind()
+		if !rendered[key(resid.Gvk{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind}, obj.GetName())] {
+			dropped = append(dropped, objectName(obj))
+		}
+	}
+	return dropped, nil
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/api/filesys"
+)
+
+var _ = Describe("droppedResources", func() {
+	var fs filesys.FileSystem
+
+	BeforeEach(func() {
+		fs = filesys.MakeFsInMemory()
+		Expect(fs.MkdirAll("/app")).To(Succeed())
+		Expect(fs.WriteFile("/app/configmap.yaml", []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+data:
+  key: value
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app-secret
+`))).To(Succeed())
+		Expect(fs.WriteFile("/app/delete.yaml", []byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: app-secret
+$patch: delete
+`))).To(Succeed())
+	})
+
+	It("reports the objects removed from the build output", func() {
+		Expect(fs.WriteFile("/app/kustomization.yaml", []byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- ./configmap.yaml
+patchesStrategicMerge:
+- delete.yaml
+`))).To(Succeed())
+
+		dropped, err := droppedResources(fs, "/app")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dropped).To(Equal([]string{"Secret/app-secret"}))
+	})
+
+	It("matches the renamed objects by their original name", func() {
+		Expect(fs.WriteFile("/app/kustomization.yaml", []byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+namePrefix: dev-
+namespace: dev
+resources:
+- configmap.yaml
+`))).To(Succeed())
+
+		dropped, err := droppedResources(fs, "/app")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dropped).To(BeEmpty())
+	})
+})
@@ -22,7 +22,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/fluxcd/pkg/apis/meta"
 	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -33,15 +35,50 @@ import (
 type KustomizeGarbageCollector struct {
 	snapshot    kustomizev1.Snapshot
 	newChecksum string
+	opts        GarbageCollectorOptions
 	log         logr.Logger
+	stats       PruneStats
+	deleted     []string
 	client.Client
 }
 
-func NewGarbageCollector(kubeClient client.Client, snapshot kustomizev1.Snapshot, newChecksum string, log logr.Logger) *KustomizeGarbageCollector {
+// PruneStats holds the outcome of the last Prune.
+type PruneStats struct {
+	// Deleted is the number of objects deleted or marked for deletion.
+	Deleted int
+
+	// Failed is the number of objects that failed to be deleted.
+	Failed int
+
+	// LimitReached is true if there were more objects to delete than the limit.
+	LimitReached bool
+}
+
+// GarbageCollectorOptions holds the settings of the garbage collector
+// defined by the Kustomization spec.
+type GarbageCollectorOptions struct {
+	// Exclude lists the objects that are never deleted.
+	Exclude []meta.NamespacedObjectKindReference
+
+	// AllowCRDs allows the deletion of CustomResourceDefinitions.
+	AllowCRDs bool
+
+	// Limit is the maximum number of objects deleted by Prune,
+	// zero means no limit.
+	Limit int
+
+	// Partial allows Prune to delete Limit objects when there are more
+	// stale objects, instead of refusing to delete any.
+	Partial bool
+}
+
+func NewGarbageCollector(kubeClient client.Client, snapshot kustomizev1.Snapshot, newChecksum string,
+	opts GarbageCollectorOptions, log logr.Logger) *KustomizeGarbageCollector {
 	return &KustomizeGarbageCollector{
 		Client:      kubeClient,
 		snapshot:    snapshot,
 		newChecksum: newChecksum,
+		opts:        opts,
 		log:         log,
 	}
 }
@@ -52,13 +89,21 @@ func NewGarbageCollector(kubeClient client.Client, snapshot kustomizev1.Snapshot
 // a label selector that contains the previously applied revision.
 // The garbage collector ignores objects that are no longer present
 // on the cluster or if they are marked for deleting using Kubernetes finalizers.
-func (kgc *KustomizeGarbageCollector) Prune(timeout time.Duration, name string, namespace string) (string, bool) {
+// Objects listed in the exclusion list are never deleted.
+// CustomResourceDefinitions are skipped unless allowed, as deleting
+// a CRD deletes all the custom resources of that kind.
+// When there are more stale objects than the limit, Prune deletes none of them,
+// or the first ones up to the limit if partial pruning is allowed, and returns
+// the number of objects left to delete.
+func (kgc *KustomizeGarbageCollector) Prune(timeout time.Duration, name string, namespace string) (string, int, bool) {
 	changeSet := ""
 	outErr := ""
 
 	ctx, cancel := context.WithTimeout(context.Background(), timeout+time.Second)
 	defer cancel()
 
+	var stale []unstructured.Unstructured
+
 	for ns, gvks := range kgc.snapshot.NamespacedKinds() {
 		for _, gvk := range gvks {
 			ulist := &unstructured.UnstructuredList{}
@@ -71,18 +116,8 @@ func (kgc *KustomizeGarbageCollector) Prune(timeout time.Duration, name string,
 			err := kgc.List(ctx, ulist, client.InNamespace(ns), kgc.matchingLabels(name, namespace))
 			if err == nil {
 				for _, item := range ulist.Items {
-					if kgc.isStale(item) && item.GetDeletionTimestamp().IsZero() {
-						gvkn := fmt.Sprintf("%s/%s/%s", item.GetKind(), item.GetNamespace(), item.GetName())
-						err = kgc.Delete(ctx, &item)
-						if err != nil {
-							outErr += fmt.Sprintf("delete failed for %s: %v\n", gvkn, err)
-						} else {
-							if len(item.GetFinalizers()) > 0 {
-								changeSet += fmt.Sprintf("%s marked for deletion\n", gvkn)
-							} else {
-								changeSet += fmt.Sprintf("%s deleted\n", gvkn)
-							}
-						}
+					if kgc.isStale(item) && !kgc.isExcluded(item) && item.GetDeletionTimestamp().IsZero() {
+						stale = append(stale, item)
 					}
 				}
 			} else {
@@ -105,18 +140,17 @@ func (kgc *KustomizeGarbageCollector) Prune(timeout time.Duration, name string,
 		err := kgc.List(ctx, ulist, kgc.matchingLabels(name, namespace))
 		if err == nil {
 			for _, item := range ulist.Items {
-				if kgc.isStale(item) && item.GetDeletionTimestamp().IsZero() {
-					gvkn := fmt.Sprintf("%s/%s", item.GetKind(), item.GetName())
-					err = kgc.Delete(ctx, &item)
-					if err != nil {
-						outErr += fmt.Sprintf("delete failed for %s: %v\n", gvkn, err)
-					} else {
-						if len(item.GetFinalizers()) > 0 {
-							changeSet += fmt.Sprintf("%s/%s marked for deletion\n", item.GetKind(), item.GetName())
-						} else {
-							changeSet += fmt.Sprintf("%s/%s deleted\n", item.GetKind(), item.GetName())
-						}
+				if kgc.isStale(item) && !kgc.isExcluded(item) && item.GetDeletionTimestamp().IsZero() {
+					if !kgc.opts.AllowCRDs && isCRD(item) {
+						gvkn := objectName(item)
+						kgc.log.WithValues(
+							strings.ToLower(kustomizev1.KustomizationKind),
+							fmt.Sprintf("%s/%s", namespace, name),
+						).Info(fmt.Sprintf("gc skipped %s, CRD pruning is not allowed", gvkn))
+						changeSet += fmt.Sprintf("%s skipped, CRD pruning is not allowed\n", gvkn)
+						continue
 					}
+					stale = append(stale, item)
 				}
 			}
 		} else {
@@ -127,10 +161,107 @@ func (kgc *KustomizeGarbageCollector) Prune(timeout time.Duration, name string,
 		}
 	}
 
+	kgc.stats = PruneStats{}
+	kgc.deleted = nil
+	remaining := 0
+	if limit := kgc.opts.Limit; limit > 0 && len(stale) > limit {
+		kgc.stats.LimitReached = true
+		if !kgc.opts.Partial {
+			return fmt.Sprintf("prune limit exceeded, %d objects to delete and the limit is %d\n",
+				len(stale), limit), len(stale), false
+		}
+		remaining = len(stale) - limit
+		stale = stale[:limit]
+	}
+
+	for _, item := range stale {
+		gvkn := objectName(item)
+		deleted, err := kgc.deleteIfManaged(ctx, item, name, namespace)
+		if err != nil {
+			outErr += fmt.Sprintf("delete failed for %s: %v\n", gvkn, err)
+			kgc.stats.Failed++
+		} else if !deleted {
+			kgc.logSkipped(gvkn, name, namespace)
+			changeSet += fmt.Sprintf("%s skipped, no longer managed by the Kustomization\n", gvkn)
+		} else {
+			kgc.stats.Deleted++
+			kgc.deleted = append(kgc.deleted, gvkn)
+			if len(item.GetFinalizers()) > 0 {
+				changeSet += fmt.Sprintf("%s marked for deletion\n", gvkn)
+			} else {
+				changeSet += fmt.Sprintf("%s deleted\n", gvkn)
+			}
+		}
+	}
+
 	if outErr != "" {
-		return outErr, false
+		return outErr, remaining, false
+	}
+	return changeSet, remaining, true
+}
+
+// Stats returns the number of objects deleted and failed to be
+// deleted by the last Prune, and if the limit was reached.
+func (kgc *KustomizeGarbageCollector) Stats() PruneStats {
+	return kgc.stats
+}
+
+// Deleted returns the objects deleted or marked for deletion by the
+// last Prune, in the <kind>/<namespace>/<name> format.
+func (kgc *KustomizeGarbageCollector) Deleted() []string {
+	return kgc.deleted
+}
+
+// objectName returns the kind, namespace and name of
+// namespaced objects, and the kind and name of global ones.
+func objectName(obj unstructured.Unstructured) string {
+	if obj.GetNamespace() == "" {
+		return fmt.Sprintf("%s/%s", obj.GetKind(), obj.GetName())
+	}
+	return fmt.Sprintf("%s/%s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName())
+}
+
+// deleteIfManaged reads the live object and deletes it only if it still carries
+// the labels of the Kustomization with a stale checksum, as another controller
+// could have adopted the object since it was listed. The delete is conditioned
+// on the live object UID and resource version, to fail if the object changes
+// before it is deleted. Returns false if the object is no longer managed.
+func (kgc *KustomizeGarbageCollector) deleteIfManaged(ctx context.Context, item unstructured.Unstructured, name, namespace string) (bool, error) {
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(item.GroupVersionKind())
+	if err := kgc.Get(ctx, client.ObjectKeyFromObject(&item), live); err != nil {
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	for k, v := range selectorLabels(name, namespace) {
+		if live.GetLabels()[k] != v {
+			return false, nil
+		}
 	}
-	return changeSet, true
+	if !kgc.isStale(*live) {
+		return false, nil
+	}
+
+	uid := live.GetUID()
+	resourceVersion := live.GetResourceVersion()
+	err := kgc.Delete(ctx, live, client.Preconditions{UID: &uid, ResourceVersion: &resourceVersion})
+	switch {
+	case apierrors.IsNotFound(err):
+		return true, nil
+	case apierrors.IsConflict(err):
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (kgc *KustomizeGarbageCollector) logSkipped(gvkn, name, namespace string) {
+	kgc.log.WithValues(
+		strings.ToLower(kustomizev1.KustomizationKind),
+		fmt.Sprintf("%s/%s", namespace, name),
+	).Info(fmt.Sprintf("gc skipped %s, the object labels changed since it was listed", gvkn))
 }
 
 func (kgc *KustomizeGarbageCollector) isStale(obj unstructured.Unstructured) bool {
@@ -138,6 +269,21 @@ func (kgc *KustomizeGarbageCollector) isStale(obj unstructured.Unstructured) boo
 	return kgc.newChecksum == "" || itemChecksum != kgc.newChecksum
 }
 
+func (kgc *KustomizeGarbageCollector) isExcluded(obj unstructured.Unstructured) bool {
+	for _, ref := range kgc.opts.Exclude {
+		if ref.Kind == obj.GetKind() && ref.Name == obj.GetName() && ref.Namespace == obj.GetNamespace() &&
+			(ref.APIVersion == "" || ref.APIVersion == obj.GetAPIVersion()) {
+			return true
+		}
+	}
+	return false
+}
+
+func isCRD(obj unstructured.Unstructured) bool {
+	return obj.GetKind() == "CustomResourceDefinition" &&
+		obj.GroupVersionKind().Group == "apiextensions.k8s.io"
+}
+
 func (kgc *KustomizeGarbageCollector) matchingLabels(name, namespace string) client.MatchingLabels {
 	return selectorLabels(name, namespace)
 }
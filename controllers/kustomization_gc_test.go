@@ -0,0 +1,231 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// relabelingClient simulates another controller adopting the listed
+// objects between the garbage collector list and delete calls.
+type relabelingClient struct {
+	client.Client
+}
+
+func (c relabelingClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	if err := c.Client.List(ctx, list, opts...); err != nil {
+		return err
+	}
+	for _, item := range list.(*unstructured.UnstructuredList).Items {
+		adopted := item.DeepCopy()
+		adopted.SetLabels(map[string]string{"app.kubernetes.io/managed-by": "other"})
+		if err := c.Client.Update(ctx, adopted); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var _ = Describe("KustomizeGarbageCollector", func() {
+	const kName = "app"
+
+	var (
+		namespace    *corev1.Namespace
+		directClient client.Client
+		snapshot     *kustomizev1.Snapshot
+		configMapKey types.NamespacedName
+	)
+
+	BeforeEach(func() {
+		var err error
+		directClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+		Expect(err).NotTo(HaveOccurred())
+
+		namespace = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "gc-test" + randStringRunes(5)},
+		}
+		Expect(directClient.Create(context.Background(), namespace)).To(Succeed())
+
+		configMapKey = types.NamespacedName{Name: "app-config", Namespace: namespace.Name}
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      configMapKey.Name,
+				Namespace: configMapKey.Namespace,
+				Labels:    gcLabels(kName, namespace.Name, "old"),
+			},
+		}
+		Expect(directClient.Create(context.Background(), configMap)).To(Succeed())
+
+		snapshot, err = kustomizev1.NewSnapshot([]byte(fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %s
+  namespace: %s
+`, configMapKey.Name, configMapKey.Namespace)), "old")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(directClient.Delete(context.Background(), namespace)).To(Succeed())
+	})
+
+	It("deletes stale objects", func() {
+		gc := NewGarbageCollector(directClient, *snapshot, "new", GarbageCollectorOptions{}, ctrl.Log)
+		output, _, ok := gc.Prune(time.Minute, kName, namespace.Name)
+		Expect(ok).To(BeTrue(), output)
+		Expect(output).To(ContainSubstring("ConfigMap/%s/%s deleted", configMapKey.Namespace, configMapKey.Name))
+		Expect(gc.Stats()).To(Equal(PruneStats{Deleted: 1}))
+		Expect(gc.Deleted()).To(Equal([]string{fmt.Sprintf("ConfigMap/%s/%s", configMapKey.Namespace, configMapKey.Name)}))
+
+		err := directClient.Get(context.Background(), configMapKey, &corev1.ConfigMap{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("keeps the excluded objects", func() {
+		exclude := meta.NamespacedObjectKindReference{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Name:       configMapKey.Name,
+			Namespace:  configMapKey.Namespace,
+		}
+		gc := NewGarbageCollector(directClient, *snapshot, "new", GarbageCollectorOptions{
+			Exclude: []meta.NamespacedObjectKindReference{exclude},
+		}, ctrl.Log)
+		output, _, ok := gc.Prune(time.Minute, kName, namespace.Name)
+		Expect(ok).To(BeTrue(), output)
+		Expect(gc.Stats()).To(Equal(PruneStats{}))
+		Expect(directClient.Get(context.Background(), configMapKey, &corev1.ConfigMap{})).To(Succeed())
+
+		// the exclusion matches the API version when set
+		exclude.APIVersion = "v2"
+		gc = NewGarbageCollector(directClient, *snapshot, "new", GarbageCollectorOptions{
+			Exclude: []meta.NamespacedObjectKindReference{exclude},
+		}, ctrl.Log)
+		output, _, ok = gc.Prune(time.Minute, kName, namespace.Name)
+		Expect(ok).To(BeTrue(), output)
+		Expect(gc.Stats()).To(Equal(PruneStats{Deleted: 1}))
+	})
+
+	It("deletes stale CRDs only when allowed", func() {
+		group := namespace.Name + ".example.com"
+		manifest := fmt.Sprintf(`---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.%[1]s
+spec:
+  group: %[1]s
+  names:
+    kind: Widget
+    plural: widgets
+  scope: Namespaced
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+`, group)
+		crd := &unstructured.Unstructured{}
+		Expect(yaml.Unmarshal([]byte(manifest), &crd.Object)).To(Succeed())
+		crd.SetLabels(gcLabels(kName, namespace.Name, "old"))
+		Expect(directClient.Create(context.Background(), crd)).To(Succeed())
+		defer directClient.Delete(context.Background(), crd)
+
+		crdSnapshot, err := kustomizev1.NewSnapshot([]byte(manifest), "old")
+		Expect(err).NotTo(HaveOccurred())
+		crdKey := types.NamespacedName{Name: crd.GetName()}
+
+		gc := NewGarbageCollector(directClient, *crdSnapshot, "new", GarbageCollectorOptions{}, ctrl.Log)
+		output, _, ok := gc.Prune(time.Minute, kName, namespace.Name)
+		Expect(ok).To(BeTrue(), output)
+		Expect(output).To(ContainSubstring("CustomResourceDefinition/%s skipped, CRD pruning is not allowed", crd.GetName()))
+		Expect(gc.Stats()).To(Equal(PruneStats{}))
+		Expect(directClient.Get(context.Background(), crdKey, crd.DeepCopy())).To(Succeed())
+
+		gc = NewGarbageCollector(directClient, *crdSnapshot, "new", GarbageCollectorOptions{AllowCRDs: true}, ctrl.Log)
+		output, _, ok = gc.Prune(time.Minute, kName, namespace.Name)
+		Expect(ok).To(BeTrue(), output)
+		Expect(gc.Stats()).To(Equal(PruneStats{Deleted: 1}))
+		Eventually(func() bool {
+			err := directClient.Get(context.Background(), crdKey, crd.DeepCopy())
+			return apierrors.IsNotFound(err)
+		}, 30*time.Second, time.Second).Should(BeTrue())
+	})
+
+	It("skips objects relabeled after they were listed", func() {
+		gc := NewGarbageCollector(relabelingClient{directClient}, *snapshot, "new", GarbageCollectorOptions{}, ctrl.Log)
+		output, _, ok := gc.Prune(time.Minute, kName, namespace.Name)
+		Expect(ok).To(BeTrue(), output)
+		Expect(output).To(ContainSubstring("ConfigMap/%s/%s skipped", configMapKey.Namespace, configMapKey.Name))
+
+		Expect(directClient.Get(context.Background(), configMapKey, &corev1.ConfigMap{})).To(Succeed())
+	})
+
+	It("refuses to delete more objects than the limit", func() {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app-secret",
+				Namespace: namespace.Name,
+				Labels:    gcLabels(kName, namespace.Name, "old"),
+			},
+		}
+		Expect(directClient.Create(context.Background(), secret)).To(Succeed())
+		secretSnapshot, err := kustomizev1.NewSnapshot([]byte(fmt.Sprintf(`---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: %s
+  namespace: %s
+`, secret.Name, secret.Namespace)), "old")
+		Expect(err).NotTo(HaveOccurred())
+		snapshot = snapshot.WithEntriesOf(secretSnapshot)
+
+		gc := NewGarbageCollector(directClient, *snapshot, "new", GarbageCollectorOptions{Limit: 1}, ctrl.Log)
+		output, remaining, ok := gc.Prune(time.Minute, kName, namespace.Name)
+		Expect(ok).To(BeFalse())
+		Expect(remaining).To(Equal(2))
+		Expect(output).To(ContainSubstring("prune limit exceeded"))
+		Expect(gc.Stats()).To(Equal(PruneStats{LimitReached: true}))
+		Expect(directClient.Get(context.Background(), configMapKey, &corev1.ConfigMap{})).To(Succeed())
+
+		gc = NewGarbageCollector(directClient, *snapshot, "new", GarbageCollectorOptions{Limit: 1, Partial: true}, ctrl.Log)
+		output, remaining, ok = gc.Prune(time.Minute, kName, namespace.Name)
+		Expect(ok).To(BeTrue(), output)
+		Expect(remaining).To(Equal(1))
+		Expect(gc.Stats()).To(Equal(PruneStats{Deleted: 1, LimitReached: true}))
+	})
+})
@@ -17,17 +17,23 @@ limitations under the License.
 package controllers
 
 import (
+	"bytes"
 	"crypto/sha1"
+	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
 	"strings"
 
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/kustomize/api/filesys"
 	"sigs.k8s.io/kustomize/api/k8sdeps/kunstruct"
 	"sigs.k8s.io/kustomize/api/konfig"
 	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/resid"
 	"sigs.k8s.io/kustomize/api/resmap"
 	kustypes "sigs.k8s.io/kustomize/api/types"
 	"sigs.k8s.io/yaml"
@@ -36,19 +42,53 @@ import (
 )
 
 const (
-	transformerFileName = "kustomization-gc-labels.yaml"
+	transformerFileName           = "kustomization-gc-labels.yaml"
+	namespaceExclusionsFileName   = "kustomization-namespace-exclusions.yaml"
+	metadataTransformerFileName   = "kustomization-metadata-%d.yaml"
+	generationTransformerFileName = "kustomization-generation.yaml"
+	namespaceFileName             = "kustomization-namespace.yaml"
+	podTemplateLabelsFileName     = "kustomization-pod-template-labels.yaml"
 )
 
 type KustomizeGenerator struct {
-	kustomization kustomizev1.Kustomization
+	kustomization    kustomizev1.Kustomization
+	fs               filesys.FileSystem
+	maxDepth         int
+	targetNamespaces []string
+	skippedSymlinks  []string
 }
 
-func NewGenerator(kustomization kustomizev1.Kustomization) *KustomizeGenerator {
+// NewGenerator returns a generator that reads and writes the kustomization
+// files on the given file system, use filesys.MakeFsInMemory to generate
+// and build without touching the disk.
+func NewGenerator(kustomization kustomizev1.Kustomization, fs filesys.FileSystem) *KustomizeGenerator {
 	return &KustomizeGenerator{
 		kustomization: kustomization,
+		fs:            fs,
 	}
 }
 
+// WithMaxDepth sets the maximum depth of the directories scanned
+// when generating a kustomization.yaml, zero means no limit.
+func (kg *KustomizeGenerator) WithMaxDepth(depth int) *KustomizeGenerator {
+	kg.maxDepth = depth
+	return kg
+}
+
+// SkippedSymlinks returns the symlinked directories, relative to the
+// kustomization path, that were not scanned when generating a kustomization.yaml.
+func (kg *KustomizeGenerator) SkippedSymlinks() []string {
+	return kg.skippedSymlinks
+}
+
+// WithTargetNamespaces sets the namespaces matching the TargetNamespaceSelector,
+// which are part of the build options so that the checksum changes, and the
+// objects of the namespaces that no longer match are garbage collected.
+func (kg *KustomizeGenerator) WithTargetNamespaces(namespaces []string) *KustomizeGenerator {
+	kg.targetNamespaces = namespaces
+	return kg
+}
+
 func (kg *KustomizeGenerator) WriteFile(dirPath string) (string, error) {
 	kfile := filepath.Join(dirPath, konfig.DefaultKustomizationFileName())
 
@@ -57,11 +97,12 @@ func (kg *KustomizeGenerator) WriteFile(dirPath string) (string, error) {
 		return "", err
 	}
 
-	if err := kg.generateLabelTransformer(checksum, dirPath); err != nil {
+	transformers, err := kg.generateTransformers(checksum, dirPath)
+	if err != nil {
 		return "", err
 	}
 
-	data, err := ioutil.ReadFile(kfile)
+	data, err := kg.fs.ReadFile(kfile)
 	if err != nil {
 		return "", err
 	}
@@ -77,25 +118,42 @@ func (kg *KustomizeGenerator) WriteFile(dirPath string) (string, error) {
 		return "", err
 	}
 
-	if len(kus.Transformers) == 0 {
-		kus.Transformers = []string{transformerFileName}
-	} else {
-		var exists bool
-		for _, transformer := range kus.Transformers {
-			if transformer == transformerFileName {
-				exists = true
-				break
+	for _, transformer := range transformers {
+		kus.Transformers = addTransformer(kus.Transformers, transformer)
+	}
+
+	if kg.kustomization.Spec.TargetNamespace != "" {
+		if len(kg.kustomization.Spec.TargetNamespaceExclude) > 0 {
+			excluded, err := kg.generateNamespaceExclusions(dirPath)
+			if err != nil {
+				return "", err
+			}
+			if excluded {
+				kus.Transformers = addTransformer(kus.Transformers, namespaceExclusionsFileName)
 			}
 		}
-		if !exists {
-			kus.Transformers = append(kus.Transformers, transformerFileName)
+
+		if kg.kustomization.Spec.CreateNamespace {
+			if err := kg.generateNamespace(dirPath); err != nil {
+				return "", err
+			}
+			kus.Resources = addTransformer(kus.Resources, namespaceFileName)
 		}
-	}
 
-	if kg.kustomization.Spec.TargetNamespace != "" {
 		kus.Namespace = kg.kustomization.Spec.TargetNamespace
 	}
 
+	for _, config := range kg.kustomization.Spec.ConfigTransformers {
+		kus.Configurations = addTransformer(kus.Configurations, config)
+	}
+
+	if err := kg.checkComponents(dirPath); err != nil {
+		return "", err
+	}
+	for _, component := range kg.kustomization.Spec.Components {
+		kus.Components = addTransformer(kus.Components, component)
+	}
+
 	for _, image := range kg.kustomization.Spec.Images {
 		newImage := kustypes.Image{
 			Name:    image.Name,
@@ -109,12 +167,146 @@ func (kg *KustomizeGenerator) WriteFile(dirPath string) (string, error) {
 		}
 	}
 
+	for _, replica := range kg.kustomization.Spec.Replicas {
+		newReplica := kustypes.Replica{
+			Name:  replica.Name,
+			Count: replica.Count,
+		}
+		if exists, index := checkKustomizeReplicaExists(kus.Replicas, replica.Name); exists {
+			kus.Replicas[index] = newReplica
+		} else {
+			kus.Replicas = append(kus.Replicas, newReplica)
+		}
+	}
+
+	for _, patch := range kg.kustomization.Spec.Patches {
+		kus.Patches = addPatch(kus.Patches, kustomizePatch(patch))
+	}
+
+	kus.CommonLabels = mergeCommonMetadata(kus.CommonLabels, kg.kustomization.Spec.CommonLabels)
+	kus.CommonAnnotations = mergeCommonMetadata(kus.CommonAnnotations, kg.kustomization.Spec.CommonAnnotations)
+
+	kus.NamePrefix = kg.kustomization.Spec.NamePrefix + kus.NamePrefix
+	kus.NameSuffix = kus.NameSuffix + kg.kustomization.Spec.NameSuffix
+
 	kd, err := yaml.Marshal(kus)
 	if err != nil {
 		return "", err
 	}
 
-	return checksum, ioutil.WriteFile(kfile, kd, os.ModePerm)
+	return checksum, kg.fs.WriteFile(kfile, kd)
+}
+
+func addTransformer(transformers []string, fileName string) []string {
+	for _, transformer := range transformers {
+		if transformer == fileName {
+			return transformers
+		}
+	}
+	return append(transformers, fileName)
+}
+
+// checkComponents returns an error if a local component of the spec
+// is not a directory containing a kustomization file. The remote
+// components are fetched by kustomize during the build.
+func (kg *KustomizeGenerator) checkComponents(dirPath string) error {
+	for _, component := range kg.kustomization.Spec.Components {
+		if isRemoteComponent(component) {
+			continue
+		}
+		path := filepath.Join(dirPath, component)
+		if !kg.fs.IsDir(path) {
+			return fmt.Errorf("component '%s' not found", component)
+		}
+		found := false
+		for _, name := range konfig.RecognizedKustomizationFileNames() {
+			if kg.fs.Exists(filepath.Join(path, name)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("component '%s' has no kustomization file", component)
+		}
+	}
+	return nil
+}
+
+// isRemoteComponent returns true if the component is a git URL.
+func isRemoteComponent(component string) bool {
+	return strings.Contains(component, "://") ||
+		strings.HasPrefix(component, "git@") ||
+		strings.HasPrefix(component, "github.com/")
+}
+
+// addPatch appends the patch, unless the same patch is already applied to the same target.
+func addPatch(patches []kustypes.Patch, patch kustypes.Patch) []kustypes.Patch {
+	for _, p := range patches {
+		if p.Path == "" && p.Patch == patch.Patch && reflect.DeepEqual(p.Target, patch.Target) {
+			return patches
+		}
+	}
+	return append(patches, patch)
+}
+
+// podTemplateFieldSpecs returns the pod template labels of the workload kinds.
+// The selectors are immutable and are never included.
+func podTemplateFieldSpecs() []kustypes.FieldSpec {
+	var fieldSpecs []kustypes.FieldSpec
+	for _, gvk := range []resid.Gvk{
+		{Group: "apps", Kind: "Deployment"},
+		{Group: "apps", Kind: "StatefulSet"},
+		{Group: "apps", Kind: "DaemonSet"},
+		{Group: "apps", Kind: "ReplicaSet"},
+		{Kind: "ReplicationController"},
+		{Group: "batch", Kind: "Job"},
+	} {
+		fieldSpecs = append(fieldSpecs, kustypes.FieldSpec{
+			Gvk:                gvk,
+			Path:               "spec/template/metadata/labels",
+			CreateIfNotPresent: true,
+		})
+	}
+	return append(fieldSpecs, kustypes.FieldSpec{
+		Gvk:                resid.Gvk{Group: "batch", Kind: "CronJob"},
+		Path:               "spec/jobTemplate/spec/template/metadata/labels",
+		CreateIfNotPresent: true,
+	})
+}
+
+// mergeCommonMetadata sets the labels or annotations of the spec in the common ones
+// of the kustomization.yaml, skipping the keys of the kustomize.toolkit.fluxcd.io
+// group which are managed by the controller.
+func mergeCommonMetadata(common, values map[string]string) map[string]string {
+	for key, value := range values {
+		if strings.HasPrefix(key, kustomizev1.GroupVersion.Group+"/") {
+			continue
+		}
+		if common == nil {
+			common = map[string]string{}
+		}
+		common[key] = value
+	}
+	return common
+}
+
+// kustomizePatch converts the Kustomization patch to its kustomize representation.
+func kustomizePatch(patch kustomizev1.Patch) kustypes.Patch {
+	p := kustypes.Patch{Patch: patch.Patch}
+	if t := patch.Target; t != nil {
+		p.Target = &kustypes.Selector{
+			Gvk: resid.Gvk{
+				Group:   t.Group,
+				Version: t.Version,
+				Kind:    t.Kind,
+			},
+			Namespace:          t.Namespace,
+			Name:               t.Name,
+			AnnotationSelector: t.AnnotationSelector,
+			LabelSelector:      t.LabelSelector,
+		}
+	}
+	return p
 }
 
 func checkKustomizeImageExists(images []kustypes.Image, imageName string) (bool, int) {
@@ -127,56 +319,23 @@ func checkKustomizeImageExists(images []kustypes.Image, imageName string) (bool,
 	return false, -1
 }
 
-func (kg *KustomizeGenerator) generateKustomization(dirPath string) error {
-	fs := filesys.MakeFsOnDisk()
-
-	// Determine if there already is a Kustomization file at the root,
-	// as this means we do not have to generate one.
-	for _, kfilename := range konfig.RecognizedKustomizationFileNames() {
-		if kpath := filepath.Join(dirPath, kfilename); fs.Exists(kpath) && !fs.IsDir(kpath) {
-			return nil
+func checkKustomizeReplicaExists(replicas []kustypes.Replica, name string) (bool, int) {
+	for i, replica := range replicas {
+		if name == replica.Name {
+			return true, i
 		}
 	}
 
-	scan := func(base string) ([]string, error) {
-		var paths []string
-		uf := kunstruct.NewKunstructuredFactoryImpl()
-		err := fs.Walk(base, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if path == base {
-				return nil
-			}
-			if info.IsDir() {
-				// If a sub-directory contains an existing kustomization file add the
-				// directory as a resource and do not decend into it.
-				for _, kfilename := range konfig.RecognizedKustomizationFileNames() {
-					if kpath := filepath.Join(path, kfilename); fs.Exists(kpath) && !fs.IsDir(kpath) {
-						paths = append(paths, path)
-						return filepath.SkipDir
-					}
-				}
-				return nil
-			}
-
-			extension := filepath.Ext(path)
-			if !containsString([]string{".yaml", ".yml"}, extension) {
-				return nil
-			}
+	return false, -1
+}
 
-			fContents, err := fs.ReadFile(path)
-			if err != nil {
-				return err
-			}
+func (kg *KustomizeGenerator) generateKustomization(dirPath string) error {
+	fs := kg.fs
 
-			if _, err := uf.SliceFromBytes(fContents); err != nil {
-				return fmt.Errorf("failed to decode Kubernetes YAML from %s: %w", path, err)
-			}
-			paths = append(paths, path)
-			return nil
-		})
-		return paths, err
+	// Determine if there already is a Kustomization file at the root,
+	// as this means we do not have to generate one.
+	if containsKustomization(fs, dirPath) {
+		return nil
 	}
 
 	abs, err := filepath.Abs(dirPath)
@@ -184,17 +343,12 @@ func (kg *KustomizeGenerator) generateKustomization(dirPath string) error {
 		return err
 	}
 
-	files, err := scan(abs)
+	files, err := kg.scanResources(abs)
 	if err != nil {
 		return err
 	}
 
 	kfile := filepath.Join(dirPath, konfig.DefaultKustomizationFileName())
-	f, err := fs.Create(kfile)
-	if err != nil {
-		return err
-	}
-	f.Close()
 
 	kus := kustypes.Kustomization{
 		TypeMeta: kustypes.TypeMeta{
@@ -214,7 +368,85 @@ func (kg *KustomizeGenerator) generateKustomization(dirPath string) error {
 		return err
 	}
 
-	return ioutil.WriteFile(kfile, kd, os.ModePerm)
+	return fs.WriteFile(kfile, kd)
+}
+
+// scanResources returns the YAML files and the directories containing a
+// kustomization file found under base. Hidden files and directories are
+// skipped unless IncludeHiddenFiles is set. The symlinked directories are
+// not followed, they are recorded as skipped, and the scan fails when the
+// directories are nested deeper than the maximum depth.
+func (kg *KustomizeGenerator) scanResources(base string) ([]string, error) {
+	fs := kg.fs
+	var paths []string
+	uf := kunstruct.NewKunstructuredFactoryImpl()
+	err := fs.Walk(base, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == base {
+			return nil
+		}
+
+		// skip the hidden files and directories such as .git and .github
+		if strings.HasPrefix(info.Name(), ".") && !kg.kustomization.Spec.IncludeHiddenFiles {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 && fs.IsDir(path) {
+			kg.skippedSymlinks = append(kg.skippedSymlinks, rel)
+			return nil
+		}
+
+		if info.IsDir() {
+			if depth := strings.Count(rel, string(filepath.Separator)) + 1; kg.maxDepth > 0 && depth > kg.maxDepth {
+				return fmt.Errorf("maximum directory depth of %d exceeded at %s", kg.maxDepth, rel)
+			}
+			// If a sub-directory contains an existing kustomization file add the
+			// directory as a resource and do not decend into it.
+			if containsKustomization(fs, path) {
+				paths = append(paths, path)
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		extension := filepath.Ext(path)
+		if !containsString([]string{".yaml", ".yml"}, extension) {
+			return nil
+		}
+
+		fContents, err := fs.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if _, err := uf.SliceFromBytes(fContents); err != nil {
+			return fmt.Errorf("failed to decode Kubernetes YAML from %s: %w", path, err)
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	return paths, err
+}
+
+// containsKustomization returns true if the directory
+// contains one of the recognized kustomization files.
+func containsKustomization(fs filesys.FileSystem, dirPath string) bool {
+	for _, kfilename := range konfig.RecognizedKustomizationFileNames() {
+		if kpath := filepath.Join(dirPath, kfilename); fs.Exists(kpath) && !fs.IsDir(kpath) {
+			return true
+		}
+	}
+	return false
 }
 
 func (kg *KustomizeGenerator) checksum(dirPath string) (string, error) {
@@ -222,21 +454,94 @@ func (kg *KustomizeGenerator) checksum(dirPath string) (string, error) {
 		return "", fmt.Errorf("kustomize create failed: %w", err)
 	}
 
-	fs := filesys.MakeFsOnDisk()
-	m, err := buildKustomization(fs, dirPath)
+	m, err := buildKustomization(kg.fs, dirPath)
 	if err != nil {
 		return "", fmt.Errorf("kustomize build failed: %w", err)
 	}
 
+	for _, res := range m.Resources() {
+		if matchesResourceKind(kg.kustomization.Spec.ChecksumExclude, res.GetGvk(), res.GetName()) {
+			if err := m.Remove(res.CurId()); err != nil {
+				return "", fmt.Errorf("checksum exclusion failed: %w", err)
+			}
+		}
+	}
+
 	resources, err := m.AsYaml()
 	if err != nil {
 		return "", fmt.Errorf("kustomize build failed: %w", err)
 	}
 
-	return fmt.Sprintf("%x", sha1.Sum(resources)), nil
+	opts, err := kg.buildOptions()
+	if err != nil {
+		return "", fmt.Errorf("build options encoding failed: %w", err)
+	}
+
+	h := sha1.New()
+	h.Write(resources)
+	h.Write(opts)
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// buildOptions returns the JSON encoding of the effective build options,
+// the spec fields that alter the generated kustomization.yaml together with
+// the kustomize settings, so that a change to any of them results in a new checksum.
+func (kg *KustomizeGenerator) buildOptions() ([]byte, error) {
+	opts := struct {
+		TargetNamespace              string                              `json:"targetNamespace,omitempty"`
+		TargetNamespaceExclude       []kustomizev1.ResourceKindReference `json:"targetNamespaceExclude,omitempty"`
+		TargetNamespaces             []string                            `json:"targetNamespaces,omitempty"`
+		Images                       []kustomizev1.Image                 `json:"images,omitempty"`
+		Replicas                     []kustomizev1.Replica               `json:"replicas,omitempty"`
+		Patches                      []kustomizev1.Patch                 `json:"patches,omitempty"`
+		CommonLabels                 map[string]string                   `json:"commonLabels,omitempty"`
+		CommonAnnotations            map[string]string                   `json:"commonAnnotations,omitempty"`
+		NamePrefix                   string                              `json:"namePrefix,omitempty"`
+		NameSuffix                   string                              `json:"nameSuffix,omitempty"`
+		ImageRegistryRewrite         []kustomizev1.ImageRegistryRewrite  `json:"imageRegistryRewrite,omitempty"`
+		MetadataTransformers         []kustomizev1.MetadataTransformer   `json:"metadataTransformers,omitempty"`
+		AnnotateGeneration           bool                                `json:"annotateGeneration,omitempty"`
+		PropagateLabelsToPodTemplate bool                                `json:"propagateLabelsToPodTemplate,omitempty"`
+		CreateNamespace              bool                                `json:"createNamespace,omitempty"`
+		NamespaceLabels              map[string]string                   `json:"namespaceLabels,omitempty"`
+		NamespaceAnnotations         map[string]string                   `json:"namespaceAnnotations,omitempty"`
+		ForceReconcileToken          string                              `json:"forceReconcileToken,omitempty"`
+		ConfigTransformers           []string                            `json:"configTransformers,omitempty"`
+		Components                   []string                            `json:"components,omitempty"`
+		PostBuild                    *kustomizev1.PostBuild              `json:"postBuild,omitempty"`
+		Kustomize                    *krusty.Options                     `json:"kustomize"`
+	}{
+		TargetNamespace:              kg.kustomization.Spec.TargetNamespace,
+		TargetNamespaceExclude:       kg.kustomization.Spec.TargetNamespaceExclude,
+		TargetNamespaces:             kg.targetNamespaces,
+		Images:                       kg.kustomization.Spec.Images,
+		Replicas:                     kg.kustomization.Spec.Replicas,
+		Patches:                      kg.kustomization.Spec.Patches,
+		CommonLabels:                 kg.kustomization.Spec.CommonLabels,
+		CommonAnnotations:            kg.kustomization.Spec.CommonAnnotations,
+		NamePrefix:                   kg.kustomization.Spec.NamePrefix,
+		NameSuffix:                   kg.kustomization.Spec.NameSuffix,
+		ImageRegistryRewrite:         kg.kustomization.Spec.ImageRegistryRewrite,
+		MetadataTransformers:         kg.kustomization.Spec.MetadataTransformers,
+		AnnotateGeneration:           kg.kustomization.Spec.AnnotateGeneration,
+		PropagateLabelsToPodTemplate: kg.kustomization.Spec.PropagateLabelsToPodTemplate,
+		CreateNamespace:              kg.kustomization.Spec.CreateNamespace,
+		NamespaceLabels:              kg.kustomization.Spec.NamespaceLabels,
+		NamespaceAnnotations:         kg.kustomization.Spec.NamespaceAnnotations,
+		ForceReconcileToken:          kg.kustomization.Spec.ForceReconcileToken,
+		ConfigTransformers:           kg.kustomization.Spec.ConfigTransformers,
+		Components:                   kg.kustomization.Spec.Components,
+		PostBuild:                    postBuildOptions(kg.kustomization.Spec.PostBuild),
+		Kustomize:                    DefaultBuildOptions(),
+	}
+	return json.Marshal(opts)
 }
 
-func (kg *KustomizeGenerator) generateLabelTransformer(checksum, dirPath string) error {
+// generateTransformers writes the transformer that sets the garbage collection
+// labels, followed by the MetadataTransformers of the spec and the generation
+// annotation transformer, and returns the transformer file names in the order
+// they must be applied.
+func (kg *KustomizeGenerator) generateTransformers(checksum, dirPath string) ([]string, error) {
 	labels := selectorLabels(kg.kustomization.GetName(), kg.kustomization.GetNamespace())
 
 	// add checksum label only if GC is enabled
@@ -244,39 +549,222 @@ func (kg *KustomizeGenerator) generateLabelTransformer(checksum, dirPath string)
 		labels = gcLabels(kg.kustomization.GetName(), kg.kustomization.GetNamespace(), checksum)
 	}
 
-	var lt = struct {
+	gcFieldSpecs := []kustypes.FieldSpec{
+		{Path: "metadata/labels", CreateIfNotPresent: true},
+	}
+	if err := kg.writeTransformer(dirPath, transformerFileName, "LabelTransformer",
+		kg.kustomization.GetName(), labels, gcFieldSpecs); err != nil {
+		return nil, err
+	}
+	files := []string{transformerFileName}
+
+	// the checksum label is left out of the pod templates, as
+	// it would roll out the workloads at every new revision
+	if kg.kustomization.Spec.PropagateLabelsToPodTemplate {
+		if err := kg.writeTransformer(dirPath, podTemplateLabelsFileName, "LabelTransformer",
+			kg.kustomization.GetName()+"-pod-template",
+			selectorLabels(kg.kustomization.GetName(), kg.kustomization.GetNamespace()),
+			podTemplateFieldSpecs()); err != nil {
+			return nil, err
+		}
+		files = append(files, podTemplateLabelsFileName)
+	}
+
+	for i, t := range kg.kustomization.Spec.MetadataTransformers {
+		fieldSpecs := make([]kustypes.FieldSpec, 0, len(t.FieldSpecs))
+		for _, spec := range t.FieldSpecs {
+			fieldSpecs = append(fieldSpecs, kustypes.FieldSpec{
+				Gvk:                resid.Gvk{Group: spec.Group, Version: spec.Version, Kind: spec.Kind},
+				Path:               spec.Path,
+				CreateIfNotPresent: spec.Create,
+			})
+		}
+		if len(fieldSpecs) == 0 {
+			path := "metadata/labels"
+			if t.Kind == "AnnotationsTransformer" {
+				path = "metadata/annotations"
+			}
+			fieldSpecs = append(fieldSpecs, kustypes.FieldSpec{Path: path, CreateIfNotPresent: true})
+		}
+
+		fileName := fmt.Sprintf(metadataTransformerFileName, i)
+		name := fmt.Sprintf("%s-metadata-%d", kg.kustomization.GetName(), i)
+		if err := kg.writeTransformer(dirPath, fileName, t.Kind, name, t.Values, fieldSpecs); err != nil {
+			return nil, err
+		}
+		files = append(files, fileName)
+	}
+
+	// the generation is left out of the checksum, as the
+	// transformers are generated after it's computed
+	if kg.kustomization.Spec.AnnotateGeneration {
+		annotations := map[string]string{
+			fmt.Sprintf("%s/generation", kustomizev1.GroupVersion.Group): fmt.Sprintf("%d", kg.kustomization.GetGeneration()),
+		}
+		fieldSpecs := []kustypes.FieldSpec{
+			{Path: "metadata/annotations", CreateIfNotPresent: true},
+		}
+		if err := kg.writeTransformer(dirPath, generationTransformerFileName, "AnnotationsTransformer",
+			kg.kustomization.GetName()+"-generation", annotations, fieldSpecs); err != nil {
+			return nil, err
+		}
+		files = append(files, generationTransformerFileName)
+	}
+
+	return files, nil
+}
+
+// writeTransformer writes the config of a builtin LabelTransformer or
+// AnnotationsTransformer, the values are set in the given fields.
+func (kg *KustomizeGenerator) writeTransformer(dirPath, fileName, kind, name string,
+	values map[string]string, fieldSpecs []kustypes.FieldSpec) error {
+	var t = struct {
 		ApiVersion string `json:"apiVersion" yaml:"apiVersion"`
 		Kind       string `json:"kind" yaml:"kind"`
 		Metadata   struct {
 			Name string `json:"name" yaml:"name"`
 		} `json:"metadata" yaml:"metadata"`
-		Labels     map[string]string    `json:"labels,omitempty" yaml:"labels,omitempty"`
-		FieldSpecs []kustypes.FieldSpec `json:"fieldSpecs,omitempty" yaml:"fieldSpecs,omitempty"`
+		Labels      map[string]string    `json:"labels,omitempty" yaml:"labels,omitempty"`
+		Annotations map[string]string    `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+		FieldSpecs  []kustypes.FieldSpec `json:"fieldSpecs,omitempty" yaml:"fieldSpecs,omitempty"`
 	}{
 		ApiVersion: "builtin",
-		Kind:       "LabelTransformer",
+		Kind:       kind,
 		Metadata: struct {
 			Name string `json:"name" yaml:"name"`
 		}{
-			Name: kg.kustomization.GetName(),
-		},
-		Labels: labels,
-		FieldSpecs: []kustypes.FieldSpec{
-			{Path: "metadata/labels", CreateIfNotPresent: true},
+			Name: name,
 		},
+		FieldSpecs: fieldSpecs,
+	}
+
+	switch kind {
+	case "LabelTransformer":
+		t.Labels = values
+	case "AnnotationsTransformer":
+		t.Annotations = values
+	default:
+		return fmt.Errorf("unsupported transformer kind '%s'", kind)
 	}
 
-	data, err := yaml.Marshal(lt)
+	data, err := yaml.Marshal(t)
 	if err != nil {
 		return err
 	}
 
-	labelsFile := filepath.Join(dirPath, transformerFileName)
-	if err := ioutil.WriteFile(labelsFile, data, os.ModePerm); err != nil {
+	return kg.fs.WriteFile(filepath.Join(dirPath, fileName), data)
+}
+
+// generateNamespace writes the manifest of the TargetNamespace
+// with the NamespaceLabels and NamespaceAnnotations.
+func (kg *KustomizeGenerator) generateNamespace(dirPath string) error {
+	ns := unstructured.Unstructured{}
+	ns.SetAPIVersion("v1")
+	ns.SetKind("Namespace")
+	ns.SetName(kg.kustomization.Spec.TargetNamespace)
+	ns.SetLabels(kg.kustomization.Spec.NamespaceLabels)
+	ns.SetAnnotations(kg.kustomization.Spec.NamespaceAnnotations)
+
+	data, err := yaml.Marshal(ns.Object)
+	if err != nil {
 		return err
 	}
+	return kg.fs.WriteFile(filepath.Join(dirPath, namespaceFileName), data)
+}
 
-	return nil
+// generateNamespaceExclusions writes a patch transformer for each resource
+// listed in TargetNamespaceExclude, restoring the namespace the resource had
+// before the TargetNamespace override was applied.
+// It must be called before the kustomization.yaml is updated with the TargetNamespace.
+// The custom transformers run after the builtin namePrefix and nameSuffix, so
+// the patches target the names with the NamePrefix and NameSuffix applied.
+func (kg *KustomizeGenerator) generateNamespaceExclusions(dirPath string) (bool, error) {
+	m, err := buildKustomization(kg.fs, dirPath)
+	if err != nil {
+		return false, fmt.Errorf("kustomize build failed: %w", err)
+	}
+
+	var docs [][]byte
+	for _, res := range m.Resources() {
+		if !kg.isNamespaceExcluded(res.GetGvk(), res.GetName()) {
+			continue
+		}
+
+		op := map[string]string{"op": "remove", "path": "/metadata/namespace"}
+		if ns := res.GetNamespace(); ns != "" {
+			op = map[string]string{"op": "replace", "path": "/metadata/namespace", "value": ns}
+		}
+		patch, err := json.Marshal([]map[string]string{op})
+		if err != nil {
+			return false, err
+		}
+
+		var pt = struct {
+			ApiVersion string `json:"apiVersion" yaml:"apiVersion"`
+			Kind       string `json:"kind" yaml:"kind"`
+			Metadata   struct {
+				Name string `json:"name" yaml:"name"`
+			} `json:"metadata" yaml:"metadata"`
+			Patch  string             `json:"patch" yaml:"patch"`
+			Target *kustypes.Selector `json:"target" yaml:"target"`
+		}{
+			ApiVersion: "builtin",
+			Kind:       "PatchTransformer",
+			Metadata: struct {
+				Name string `json:"name" yaml:"name"`
+			}{
+				Name: fmt.Sprintf("%s-namespace-exclusion-%d", kg.kustomization.GetName(), len(docs)),
+			},
+			Patch: string(patch),
+			Target: &kustypes.Selector{
+				Gvk:  res.GetGvk(),
+				Name: "^" + regexp.QuoteMeta(kg.finalName(res.GetName())) + "$",
+			},
+		}
+
+		data, err := yaml.Marshal(pt)
+		if err != nil {
+			return false, err
+		}
+		docs = append(docs, data)
+	}
+
+	if len(docs) == 0 {
+		return false, nil
+	}
+
+	exclusionsFile := filepath.Join(dirPath, namespaceExclusionsFileName)
+	if err := kg.fs.WriteFile(exclusionsFile, bytes.Join(docs, []byte("---\n"))); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// finalName returns the name of a resource with the NamePrefix and NameSuffix applied.
+func (kg *KustomizeGenerator) finalName(name string) string {
+	return kg.kustomization.Spec.NamePrefix + name + kg.kustomization.Spec.NameSuffix
+}
+
+func (kg *KustomizeGenerator) isNamespaceExcluded(gvk resid.Gvk, name string) bool {
+	return matchesResourceKind(kg.kustomization.Spec.TargetNamespaceExclude, gvk, name)
+}
+
+// matchesResourceKind returns true if any of the references matches the
+// resource, references without a name match all resources of the kind.
+func matchesResourceKind(refs []kustomizev1.ResourceKindReference, gvk resid.Gvk, name string) bool {
+	for _, ref := range refs {
+		if ref.Kind != gvk.Kind || (ref.Name != "" && ref.Name != name) {
+			continue
+		}
+		if ref.APIVersion == "" {
+			return true
+		}
+		if gv, err := schema.ParseGroupVersion(ref.APIVersion); err == nil &&
+			gv.Group == gvk.Group && gv.Version == gvk.Version {
+			return true
+		}
+	}
+	return false
 }
 
 // buildKustomization wraps krusty.MakeKustomizer with the following settings:
@@ -288,7 +776,21 @@ func (kg *KustomizeGenerator) generateLabelTransformer(checksum, dirPath string)
 // - disable plugins except for the builtin ones
 // - prohibit changes to resourceIds, patch name/kind don't overwrite target name/kind
 func buildKustomization(fs filesys.FileSystem, dirPath string) (resmap.ResMap, error) {
-	buildOptions := &krusty.Options{
+	k := krusty.MakeKustomizer(fs, DefaultBuildOptions())
+	m, err := k.Run(dirPath)
+	// a build cancelled by the heap limit may have ignored the failed reads
+	if lfs, ok := fs.(*heapLimitedFS); ok && lfs.Err() != nil {
+		return nil, lfs.Err()
+	}
+	return m, err
+}
+
+// DefaultBuildOptions returns the krusty options the controller builds
+// the kustomizations with. The options can be passed to krusty.MakeKustomizer,
+// or turned into kustomize CLI flags with BuildFlags, to reproduce the
+// controller output outside of the cluster.
+func DefaultBuildOptions() *krusty.Options {
+	return &krusty.Options{
 		UseKyaml:               false,
 		DoLegacyResourceSort:   true,
 		LoadRestrictions:       kustypes.LoadRestrictionsNone,
@@ -297,7 +799,23 @@ func buildKustomization(fs filesys.FileSystem, dirPath string) (resmap.ResMap, e
 		PluginConfig:           konfig.DisabledPluginConfig(),
 		AllowResourceIdChanges: false,
 	}
+}
 
-	k := krusty.MakeKustomizer(fs, buildOptions)
-	return k.Run(dirPath)
+// BuildFlags returns the `kustomize build` flags matching the given options.
+func BuildFlags(opts *krusty.Options) []string {
+	reorder := "none"
+	if opts.DoLegacyResourceSort {
+		reorder = "legacy"
+	}
+	flags := []string{
+		fmt.Sprintf("--enable_kyaml=%t", opts.UseKyaml),
+		fmt.Sprintf("--reorder=%s", reorder),
+		fmt.Sprintf("--load_restrictor=%s", opts.LoadRestrictions),
+		fmt.Sprintf("--enable_managedby_label=%t", opts.AddManagedbyLabel),
+		fmt.Sprintf("--allow_id_changes=%t", opts.AllowResourceIdChanges),
+	}
+	if opts.PluginConfig != nil && opts.PluginConfig.PluginRestrictions != kustypes.PluginRestrictionsBuiltinsOnly {
+		flags = append(flags, "--enable_alpha_plugins")
+	}
+	return flags
 }
@@ -0,0 +1,898 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/kustomize/api/filesys"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var _ = Describe("KustomizeGenerator", func() {
+	const dirPath = "/app"
+	var fs filesys.FileSystem
+
+	BeforeEach(func() {
+		fs = filesys.MakeFsInMemory()
+		Expect(fs.MkdirAll(dirPath)).To(Succeed())
+	})
+
+	writeFile := func(name, body string) {
+		Expect(fs.WriteFile(filepath.Join(dirPath, name), []byte(body))).To(Succeed())
+	}
+
+	It("excludes resources from the target namespace override", func() {
+		writeFile("configmap.yaml", `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+data:
+  key: value
+`)
+		writeFile("rolebinding.yaml", `---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: app-reader
+  namespace: kube-system
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: extension-apiserver-authentication-reader
+subjects:
+- kind: ServiceAccount
+  name: app
+  namespace: app
+`)
+
+		k := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: "flux-system",
+			},
+			Spec: kustomizev1.KustomizationSpec{
+				TargetNamespace: "app",
+				TargetNamespaceExclude: []kustomizev1.ResourceKindReference{
+					{
+						APIVersion: "rbac.authorization.k8s.io/v1",
+						Kind:       "RoleBinding",
+						Name:       "app-reader",
+					},
+				},
+			},
+		}
+
+		_, err := NewGenerator(k, fs).WriteFile(dirPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		m, err := buildKustomization(fs, dirPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		namespaces := map[string]string{}
+		for _, res := range m.Resources() {
+			namespaces[res.GetName()] = res.GetNamespace()
+		}
+		Expect(namespaces).To(Equal(map[string]string{
+			"app-config": "app",
+			"app-reader": "kube-system",
+		}))
+	})
+
+	It("excludes the prefixed resources from the target namespace override", func() {
+		writeFile("configmap.yaml", `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app.config
+  namespace: kube-system
+data:
+  key: value
+`)
+		writeFile("secret.yaml", `---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app-config
+stringData:
+  key: value
+`)
+
+		k := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: "flux-system",
+			},
+			Spec: kustomizev1.KustomizationSpec{
+				NamePrefix:      "prod-",
+				NameSuffix:      "-v1",
+				TargetNamespace: "app",
+				TargetNamespaceExclude: []kustomizev1.ResourceKindReference{
+					{
+						APIVersion: "v1",
+						Kind:       "ConfigMap",
+						Name:       "app.config",
+					},
+				},
+			},
+		}
+
+		_, err := NewGenerator(k, fs).WriteFile(dirPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		m, err := buildKustomization(fs, dirPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		namespaces := map[string]string{}
+		for _, res := range m.Resources() {
+			namespaces[res.GetName()] = res.GetNamespace()
+		}
+		Expect(namespaces).To(Equal(map[string]string{
+			"prod-app.config-v1": "kube-system",
+			"prod-app-config-v1": "app",
+		}))
+	})
+
+	It("orders ConfigMaps and Secrets before the workloads", func() {
+		writeFile("a-deployment.yaml", `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  selector:
+    matchLabels:
+      app: app
+  template:
+    metadata:
+      labels:
+        app: app
+    spec:
+      containers:
+      - name: app
+        image: nginx
+        envFrom:
+        - configMapRef:
+            name: app-config
+        - secretRef:
+            name: app-credentials
+`)
+		writeFile("b-config.yaml", `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+data:
+  key: value
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app-credentials
+stringData:
+  token: secret
+`)
+
+		k := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: "flux-system",
+			},
+		}
+
+		_, err := NewGenerator(k, fs).WriteFile(dirPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		m, err := buildKustomization(fs, dirPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		var kinds []string
+		for _, res := range m.Resources() {
+			kinds = append(kinds, res.GetKind())
+		}
+		Expect(kinds).To(Equal([]string{"ConfigMap", "Secret", "Deployment"}))
+	})
+
+	It("applies the metadata transformers after the GC labels", func() {
+		writeFile("deployment.yaml", `---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  selector:
+    matchLabels:
+      app: app
+  template:
+    metadata:
+      labels:
+        app: app
+    spec:
+      containers:
+      - name: app
+        image: nginx
+`)
+
+		k := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: "flux-system",
+			},
+			Spec: kustomizev1.KustomizationSpec{
+				MetadataTransformers: []kustomizev1.MetadataTransformer{
+					{
+						Kind:   "LabelTransformer",
+						Values: map[string]string{"team": "web"},
+						FieldSpecs: []kustomizev1.FieldSpec{
+							{Path: "metadata/labels", Create: true},
+							{Kind: "Deployment", Path: "spec/template/metadata/labels", Create: true},
+						},
+					},
+					{
+						Kind:   "AnnotationsTransformer",
+						Values: map[string]string{"example.com/revision": "main"},
+					},
+				},
+			},
+		}
+
+		_, err := NewGenerator(k, fs).WriteFile(dirPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		m, err := buildKustomization(fs, dirPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Resources()).To(HaveLen(1))
+
+		obj := m.Resources()[0].Map()
+		Expect(obj).To(HaveKeyWithValue("metadata", And(
+			HaveKeyWithValue("labels", And(
+				HaveKeyWithValue("team", "web"),
+				HaveKeyWithValue("kustomize.toolkit.fluxcd.io/name", "app"),
+			)),
+			HaveKeyWithValue("annotations", HaveKeyWithValue("example.com/revision", "main")),
+		)))
+		Expect(obj).To(HaveKeyWithValue("spec", HaveKeyWithValue("template",
+			HaveKeyWithValue("metadata", HaveKeyWithValue("labels", HaveKeyWithValue("team", "web"))))))
+	})
+
+	It("annotates the generation without changing the checksum", func() {
+		const configMap = `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+data:
+  key: value
+`
+		k := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:       "app",
+				Namespace:  "flux-system",
+				Generation: 3,
+			},
+			Spec: kustomizev1.KustomizationSpec{
+				AnnotateGeneration: true,
+			},
+		}
+
+		writeFile("configmap.yaml", configMap)
+		checksum, err := NewGenerator(k, fs).WriteFile(dirPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		m, err := buildKustomization(fs, dirPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Resources()).To(HaveLen(1))
+		Expect(m.Resources()[0].GetAnnotations()).To(HaveKeyWithValue("kustomize.toolkit.fluxcd.io/generation", "3"))
+
+		fs = filesys.MakeFsInMemory()
+		Expect(fs.MkdirAll(dirPath)).To(Succeed())
+		writeFile("configmap.yaml", configMap)
+		k.Generation = 4
+		nextChecksum, err := NewGenerator(k, fs).WriteFile(dirPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(nextChecksum).To(Equal(checksum))
+	})
+
+	It("changes the checksum when the build options change", func() {
+		const configMap = `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+data:
+  key: value
+`
+		k := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: "flux-system",
+			},
+			Spec: kustomizev1.KustomizationSpec{
+				Images: []kustomizev1.Image{{Name: "podinfo", NewTag: "5.0.0"}},
+			},
+		}
+		checksum := func(k kustomizev1.Kustomization) string {
+			fs = filesys.MakeFsInMemory()
+			Expect(fs.MkdirAll(dirPath)).To(Succeed())
+			writeFile("configmap.yaml", configMap)
+			sum, err := NewGenerator(k, fs).WriteFile(dirPath)
+			Expect(err).NotTo(HaveOccurred())
+			return sum
+		}
+
+		initial := checksum(k)
+		Expect(checksum(k)).To(Equal(initial))
+
+		// the image doesn't match any object, the build output is unchanged
+		k.Spec.Images[0].NewTag = "5.0.1"
+		Expect(checksum(k)).NotTo(Equal(initial))
+	})
+
+	It("adds the transformer configurations", func() {
+		writeFile("kustomization.yaml", `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+namePrefix: dev-
+resources:
+- configmap.yaml
+- widget.yaml
+`)
+		writeFile("configmap.yaml", `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+`)
+		writeFile("widget.yaml", `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: app
+spec:
+  configMapName: app-config
+`)
+		Expect(fs.MkdirAll(filepath.Join(dirPath, "kustomizeconfig"))).To(Succeed())
+		writeFile("kustomizeconfig/widgets.yaml", `nameReference:
+- kind: ConfigMap
+  fieldSpecs:
+  - kind: Widget
+    path: spec/configMapName
+`)
+
+		k := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: "flux-system",
+			},
+			Spec: kustomizev1.KustomizationSpec{
+				ConfigTransformers: []string{"kustomizeconfig/widgets.yaml"},
+			},
+		}
+		_, err := NewGenerator(k, fs).WriteFile(dirPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		m, err := buildKustomization(fs, dirPath)
+		Expect(err).NotTo(HaveOccurred())
+		var configMapName interface{}
+		for _, res := range m.Resources() {
+			if res.GetKind() == "Widget" {
+				configMapName = res.Map()["spec"].(map[string]interface{})["configMapName"]
+			}
+		}
+		Expect(configMapName).To(Equal("dev-app-config"))
+	})
+
+	It("appends the patches after the ones of the kustomization.yaml", func() {
+		const replicasPatch = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 2
+`
+		writeFile("kustomization.yaml", `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- deployment.yaml
+patches:
+- patch: |
+    apiVersion: apps/v1
+    kind: Deployment
+    metadata:
+      name: app
+    spec:
+      replicas: 2
+`)
+		writeFile("deployment.yaml", `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: app
+        image: app:1.0.0
+`)
+
+		k := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: "flux-system",
+			},
+			Spec: kustomizev1.KustomizationSpec{
+				Patches: []kustomizev1.Patch{
+					{Patch: replicasPatch},
+					{
+						Patch: `[{"op": "replace", "path": "/spec/replicas", "value": 3}]`,
+						Target: &kustomizev1.PatchSelector{
+							Group: "apps",
+							Kind:  "Deployment",
+							Name:  "app",
+						},
+					},
+				},
+			},
+		}
+		_, err := NewGenerator(k, fs).WriteFile(dirPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		data, err := fs.ReadFile(filepath.Join(dirPath, "kustomization.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		kus := kustypes.Kustomization{}
+		Expect(yaml.Unmarshal(data, &kus)).To(Succeed())
+		Expect(kus.Patches).To(HaveLen(2))
+
+		m, err := buildKustomization(fs, dirPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Resources()).To(HaveLen(1))
+		Expect(m.Resources()[0].Map()["spec"].(map[string]interface{})["replicas"]).To(BeNumerically("==", 3))
+	})
+
+	It("overrides the replicas by name", func() {
+		writeFile("kustomization.yaml", `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- deployment.yaml
+replicas:
+- name: app
+  count: 2
+`)
+		writeFile("deployment.yaml", `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 1
+`)
+
+		k := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: "flux-system",
+			},
+			Spec: kustomizev1.KustomizationSpec{
+				Replicas: []kustomizev1.Replica{{Name: "app", Count: 3}},
+			},
+		}
+		_, err := NewGenerator(k, fs).WriteFile(dirPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		data, err := fs.ReadFile(filepath.Join(dirPath, "kustomization.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		kus := kustypes.Kustomization{}
+		Expect(yaml.Unmarshal(data, &kus)).To(Succeed())
+		Expect(kus.Replicas).To(Equal([]kustypes.Replica{{Name: "app", Count: 3}}))
+
+		m, err := buildKustomization(fs, dirPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Resources()[0].Map()["spec"].(map[string]interface{})["replicas"]).To(BeNumerically("==", 3))
+	})
+
+	It("fails the build when the replicas name doesn't match an object", func() {
+		writeFile("deployment.yaml", `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 1
+`)
+
+		k := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: "flux-system",
+			},
+			Spec: kustomizev1.KustomizationSpec{
+				Replicas: []kustomizev1.Replica{{Name: "web", Count: 3}},
+			},
+		}
+		_, err := NewGenerator(k, fs).WriteFile(dirPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = buildKustomization(fs, dirPath)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("web"))
+	})
+
+	It("adds the components", func() {
+		writeFile("configmap.yaml", `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+`)
+		Expect(fs.MkdirAll("/components/monitoring")).To(Succeed())
+		Expect(fs.WriteFile("/components/monitoring/kustomization.yaml", []byte(`apiVersion: kustomize.config.k8s.io/v1alpha1
+kind: Component
+commonLabels:
+  monitoring: enabled
+`))).To(Succeed())
+
+		k := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: "flux-system",
+			},
+			Spec: kustomizev1.KustomizationSpec{
+				Components: []string{"../components/monitoring"},
+			},
+		}
+		_, err := NewGenerator(k, fs).WriteFile(dirPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		m, err := buildKustomization(fs, dirPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Resources()).To(HaveLen(1))
+		Expect(m.Resources()[0].GetLabels()).To(HaveKeyWithValue("monitoring", "enabled"))
+	})
+
+	It("fails when a local component is missing", func() {
+		writeFile("configmap.yaml", `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+`)
+
+		k := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: "flux-system",
+			},
+			Spec: kustomizev1.KustomizationSpec{
+				Components: []string{
+					"https://github.com/example/components//ingress?ref=v1.0.0",
+					"../components/missing",
+				},
+			},
+		}
+		_, err := NewGenerator(k, fs).WriteFile(dirPath)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal("component '../components/missing' not found"))
+	})
+
+	It("propagates the selector labels to the pod templates", func() {
+		writeFile("deployment.yaml", `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  selector:
+    matchLabels:
+      app: app
+  template:
+    metadata:
+      labels:
+        app: app
+    spec:
+      containers:
+      - name: app
+        image: app:1.0.0
+`)
+
+		k := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: "flux-system",
+			},
+			Spec: kustomizev1.KustomizationSpec{
+				Prune:                        true,
+				PropagateLabelsToPodTemplate: true,
+			},
+		}
+		_, err := NewGenerator(k, fs).WriteFile(dirPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		m, err := buildKustomization(fs, dirPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Resources()).To(HaveLen(1))
+		spec := m.Resources()[0].Map()["spec"].(map[string]interface{})
+		template := spec["template"].(map[string]interface{})["metadata"].(map[string]interface{})
+		Expect(template["labels"]).To(Equal(map[string]interface{}{
+			"app":                                   "app",
+			"kustomize.toolkit.fluxcd.io/name":      "app",
+			"kustomize.toolkit.fluxcd.io/namespace": "flux-system",
+		}))
+		Expect(spec["selector"]).To(Equal(map[string]interface{}{
+			"matchLabels": map[string]interface{}{"app": "app"},
+		}))
+	})
+
+	It("merges the common labels and annotations", func() {
+		writeFile("kustomization.yaml", `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+commonLabels:
+  app: web
+  team: web
+resources:
+- configmap.yaml
+`)
+		writeFile("configmap.yaml", `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+`)
+
+		k := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: "flux-system",
+			},
+			Spec: kustomizev1.KustomizationSpec{
+				Prune: true,
+				CommonLabels: map[string]string{
+					"team":                             "payments",
+					"cost-center":                      "cc-1234",
+					"kustomize.toolkit.fluxcd.io/name": "other",
+				},
+				CommonAnnotations: map[string]string{
+					"owner": "payments@example.com",
+				},
+			},
+		}
+		checksum, err := NewGenerator(k, fs).WriteFile(dirPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		data, err := fs.ReadFile(filepath.Join(dirPath, "kustomization.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		kus := kustypes.Kustomization{}
+		Expect(yaml.Unmarshal(data, &kus)).To(Succeed())
+		Expect(kus.CommonLabels).To(Equal(map[string]string{
+			"app":         "web",
+			"team":        "payments",
+			"cost-center": "cc-1234",
+		}))
+		Expect(kus.CommonAnnotations).To(Equal(map[string]string{"owner": "payments@example.com"}))
+
+		m, err := buildKustomization(fs, dirPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Resources()).To(HaveLen(1))
+		labels := m.Resources()[0].GetLabels()
+		Expect(labels).To(HaveKeyWithValue("team", "payments"))
+		Expect(labels).To(HaveKeyWithValue("kustomize.toolkit.fluxcd.io/name", "app"))
+		Expect(labels).To(HaveKeyWithValue("kustomize.toolkit.fluxcd.io/checksum", checksum))
+	})
+
+	It("changes the checksum when the force reconcile token changes", func() {
+		k := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: "flux-system",
+			},
+		}
+		checksum := func(token string) string {
+			fs = filesys.MakeFsInMemory()
+			Expect(fs.MkdirAll(dirPath)).To(Succeed())
+			writeFile("configmap.yaml", "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app-config\n")
+			k.Spec.ForceReconcileToken = token
+			sum, err := NewGenerator(k, fs).WriteFile(dirPath)
+			Expect(err).NotTo(HaveOccurred())
+			return sum
+		}
+
+		initial := checksum("")
+		forced := checksum("1")
+		Expect(forced).NotTo(Equal(initial))
+		Expect(checksum("1")).To(Equal(forced))
+		Expect(checksum("2")).NotTo(Equal(forced))
+	})
+})
+
+var _ = Describe("KustomizeGenerator namespace", func() {
+	const dirPath = "/app"
+
+	It("creates the target namespace with the labels and annotations", func() {
+		fs := filesys.MakeFsInMemory()
+		Expect(fs.MkdirAll(dirPath)).To(Succeed())
+		Expect(fs.WriteFile(filepath.Join(dirPath, "configmap.yaml"), []byte(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+`))).To(Succeed())
+
+		k := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app",
+				Namespace: "flux-system",
+			},
+			Spec: kustomizev1.KustomizationSpec{
+				TargetNamespace: "apps",
+				CreateNamespace: true,
+				NamespaceLabels: map[string]string{
+					"pod-security.kubernetes.io/enforce": "restricted",
+				},
+				NamespaceAnnotations: map[string]string{
+					"owner": "platform",
+				},
+			},
+		}
+		_, err := NewGenerator(k, fs).WriteFile(dirPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		m, err := buildKustomization(fs, dirPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Resources()).To(HaveLen(2))
+
+		var found bool
+		for _, res := range m.Resources() {
+			if res.GetKind() != "Namespace" {
+				continue
+			}
+			found = true
+			Expect(res.GetName()).To(Equal("apps"))
+			Expect(res.GetLabels()).To(HaveKeyWithValue("pod-security.kubernetes.io/enforce", "restricted"))
+			Expect(res.GetLabels()).To(HaveKeyWithValue("kustomize.toolkit.fluxcd.io/name", "app"))
+			Expect(res.GetLabels()).To(HaveKeyWithValue("kustomize.toolkit.fluxcd.io/namespace", "flux-system"))
+			Expect(res.GetAnnotations()).To(HaveKeyWithValue("owner", "platform"))
+		}
+		Expect(found).To(BeTrue())
+	})
+})
+
+var _ = Describe("KustomizeGenerator hidden files", func() {
+	const dirPath = "/app"
+	var fs filesys.FileSystem
+
+	BeforeEach(func() {
+		fs = filesys.MakeFsInMemory()
+		Expect(fs.MkdirAll(filepath.Join(dirPath, ".github", "workflows"))).To(Succeed())
+		Expect(fs.WriteFile(filepath.Join(dirPath, "configmap.yaml"), []byte(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+`))).To(Succeed())
+		Expect(fs.WriteFile(filepath.Join(dirPath, ".github", "workflows", "ci.yaml"), []byte(`name: ci
+on: [push]
+jobs:
+  test:
+    runs-on: ubuntu-latest
+`))).To(Succeed())
+	})
+
+	It("skips the hidden directories by default", func() {
+		_, err := NewGenerator(kustomizev1.Kustomization{}, fs).WriteFile(dirPath)
+		Expect(err).NotTo(HaveOccurred())
+
+		m, err := buildKustomization(fs, dirPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(m.Resources()).To(HaveLen(1))
+		Expect(m.Resources()[0].GetName()).To(Equal("app-config"))
+	})
+
+	It("includes the hidden directories when enabled", func() {
+		k := kustomizev1.Kustomization{
+			Spec: kustomizev1.KustomizationSpec{
+				IncludeHiddenFiles: true,
+			},
+		}
+		_, err := NewGenerator(k, fs).WriteFile(dirPath)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(".github/workflows/ci.yaml"))
+	})
+})
+
+var _ = Describe("KustomizeGenerator scan", func() {
+	var tmpDir string
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "scan")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	writeFile := func(name, body string) {
+		path := filepath.Join(tmpDir, name)
+		Expect(os.MkdirAll(filepath.Dir(path), os.ModePerm)).To(Succeed())
+		Expect(ioutil.WriteFile(path, []byte(body), os.ModePerm)).To(Succeed())
+	}
+
+	const configMap = `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+`
+
+	It("skips the symlinked directories", func() {
+		writeFile("shared/configmap.yaml", configMap)
+		Expect(os.MkdirAll(filepath.Join(tmpDir, "app"), os.ModePerm)).To(Succeed())
+		Expect(os.Symlink(filepath.Join(tmpDir, "shared"), filepath.Join(tmpDir, "app", "shared"))).To(Succeed())
+
+		gen := NewGenerator(kustomizev1.Kustomization{}, filesys.MakeFsOnDisk())
+		Expect(gen.generateKustomization(filepath.Join(tmpDir, "app"))).To(Succeed())
+		Expect(gen.SkippedSymlinks()).To(Equal([]string{"shared"}))
+
+		data, err := ioutil.ReadFile(filepath.Join(tmpDir, "app", "kustomization.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).NotTo(ContainSubstring("configmap.yaml"))
+	})
+
+	It("doesn't loop on symlink cycles", func() {
+		writeFile("app/configmap.yaml", configMap)
+		Expect(os.Symlink(filepath.Join(tmpDir, "app"), filepath.Join(tmpDir, "app", "loop"))).To(Succeed())
+
+		gen := NewGenerator(kustomizev1.Kustomization{}, filesys.MakeFsOnDisk())
+		Expect(gen.generateKustomization(filepath.Join(tmpDir, "app"))).To(Succeed())
+		Expect(gen.SkippedSymlinks()).To(Equal([]string{"loop"}))
+
+		data, err := ioutil.ReadFile(filepath.Join(tmpDir, "app", "kustomization.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring("- ./configmap.yaml"))
+	})
+
+	It("fails when the directories are nested deeper than the maximum depth", func() {
+		writeFile("app/a/b/c/configmap.yaml", configMap)
+
+		gen := NewGenerator(kustomizev1.Kustomization{}, filesys.MakeFsOnDisk()).WithMaxDepth(2)
+		err := gen.generateKustomization(filepath.Join(tmpDir, "app"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("maximum directory depth of 2 exceeded"))
+	})
+})
+
+var _ = Describe("BuildFlags", func() {
+	It("matches the default build options", func() {
+		Expect(BuildFlags(DefaultBuildOptions())).To(Equal([]string{
+			"--enable_kyaml=false",
+			"--reorder=legacy",
+			"--load_restrictor=LoadRestrictionsNone",
+			"--enable_managedby_label=false",
+			"--allow_id_changes=false",
+		}))
+	})
+
+	It("enables the alpha plugins when they are not restricted to builtins", func() {
+		opts := DefaultBuildOptions()
+		opts.PluginConfig = &kustypes.PluginConfig{PluginRestrictions: kustypes.PluginRestrictionsNone}
+		opts.DoLegacyResourceSort = false
+		flags := BuildFlags(opts)
+		Expect(flags).To(ContainElement("--reorder=none"))
+		Expect(flags).To(ContainElement("--enable_alpha_plugins"))
+	})
+})
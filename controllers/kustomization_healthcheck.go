@@ -20,23 +20,33 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fluxcd/pkg/apis/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"sigs.k8s.io/cli-utils/pkg/kstatus/polling"
 	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/aggregator"
 	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/collector"
 	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/event"
 	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
 	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
 )
 
+// maxHealthCheckWorkers is the maximum number of objects
+// whose conditions are fetched concurrently.
+const maxHealthCheckWorkers = 10
+
 type KustomizeHealthCheck struct {
-	kustomization kustomizev1.Kustomization
-	statusPoller  *polling.StatusPoller
+	kustomization  kustomizev1.Kustomization
+	statusPoller   *polling.StatusPoller
+	readinessRules []readinessRule
 }
 
 func NewHealthCheck(kustomization kustomizev1.Kustomization, statusPoller *polling.StatusPoller) *KustomizeHealthCheck {
@@ -46,6 +56,49 @@ func NewHealthCheck(kustomization kustomizev1.Kustomization, statusPoller *polli
 	}
 }
 
+// WithReadinessRules sets the rules that override the kstatus
+// readiness of the HealthChecks objects of their kinds.
+func (hc *KustomizeHealthCheck) WithReadinessRules(rules []readinessRule) *KustomizeHealthCheck {
+	hc.readinessRules = rules
+	return hc
+}
+
+// AssessAll runs the assessment of the HealthChecks and of the HealthCheckConditions
+// concurrently, so that both are bounded by the same timeout, and returns the
+// objects not ready of both assessments on failure.
+func (hc *KustomizeHealthCheck) AssessAll(kubeClient client.Client, pollInterval time.Duration) error {
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	if len(hc.kustomization.Spec.HealthChecks) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[0] = hc.Assess(pollInterval)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		errs[1] = hc.AssessConditions(kubeClient, pollInterval)
+	}()
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	switch len(failed) {
+	case 0:
+		return nil
+	case 1:
+		return failed[0]
+	default:
+		return fmt.Errorf("%v; %v", failed[0], failed[1])
+	}
+}
+
 func (hc *KustomizeHealthCheck) Assess(pollInterval time.Duration) error {
 	objMetadata, err := hc.toObjMetadata(hc.kustomization.Spec.HealthChecks)
 	if err != nil {
@@ -63,7 +116,7 @@ func (hc *KustomizeHealthCheck) Assess(pollInterval time.Duration) error {
 		func(statusCollector *collector.ResourceStatusCollector, e event.Event) {
 			var rss []*event.ResourceStatus
 			for _, rs := range statusCollector.ResourceStatuses {
-				rss = append(rss, rs)
+				rss = append(rss, applyReadinessRule(hc.readinessRules, rs))
 			}
 			desired := status.CurrentStatus
 			aggStatus := aggregator.AggregateStatus(rss, desired)
@@ -83,7 +136,7 @@ func (hc *KustomizeHealthCheck) Assess(pollInterval time.Duration) error {
 	if ctx.Err() == context.DeadlineExceeded {
 		ids := []string{}
 		for _, rs := range coll.ResourceStatuses {
-			if rs.Status != status.CurrentStatus {
+			if applyReadinessRule(hc.readinessRules, rs).Status != status.CurrentStatus {
 				id := hc.objMetadataToString(rs.Identifier)
 				ids = append(ids, id)
 			}
@@ -94,6 +147,98 @@ func (hc *KustomizeHealthCheck) Assess(pollInterval time.Duration) error {
 	return nil
 }
 
+// AssessConditions polls the objects of the HealthCheckConditions until all of
+// them have the expected condition status, or the timeout expires. The objects
+// are fetched concurrently, and are no longer polled once they passed the check.
+func (hc *KustomizeHealthCheck) AssessConditions(kubeClient client.Client, pollInterval time.Duration) error {
+	checks := hc.kustomization.Spec.HealthCheckConditions
+	if len(checks) == 0 {
+		return nil
+	}
+
+	timeout := hc.kustomization.GetTimeout() + (time.Second * 1)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	passed := make([]bool, len(checks))
+	var pending []string
+	err := wait.PollImmediateUntil(pollInterval, func() (bool, error) {
+		errs := make([]error, len(checks))
+		sem := make(chan struct{}, maxHealthCheckWorkers)
+		var wg sync.WaitGroup
+		for i := range checks {
+			if passed[i] {
+				continue
+			}
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(i int) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				passed[i], errs[i] = hasCondition(ctx, kubeClient, checks[i])
+			}(i)
+		}
+		wg.Wait()
+
+		pending = nil
+		for i, check := range checks {
+			if errs[i] != nil {
+				return false, errs[i]
+			}
+			if !passed[i] {
+				pending = append(pending, fmt.Sprintf("%s '%s/%s' %s=%s", check.ObjectRef.Kind,
+					check.ObjectRef.Namespace, check.ObjectRef.Name, check.Type, conditionStatus(check)))
+			}
+		}
+		return len(pending) == 0, nil
+	}, ctx.Done())
+
+	if err == wait.ErrWaitTimeout {
+		return fmt.Errorf("Health check timed out for [%v]", strings.Join(pending, ", "))
+	}
+	return err
+}
+
+// hasCondition returns true if the object has the condition
+// type of the check with the expected status.
+func hasCondition(ctx context.Context, kubeClient client.Client, check kustomizev1.HealthCheckCondition) (bool, error) {
+	ref := check.ObjectRef
+	// For backwards compatibility
+	if ref.APIVersion == "" {
+		ref.APIVersion = "apps/v1"
+	}
+
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(ref.APIVersion)
+	obj.SetKind(ref.Kind)
+	if err := kubeClient.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, obj); err != nil {
+		if ctx.Err() != nil {
+			// the health check timed out, let the poll report the pending checks
+			return false, nil
+		}
+		return false, client.IgnoreNotFound(err)
+	}
+
+	conditions, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil {
+		return false, nil
+	}
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == check.Type {
+			return condition["status"] == string(conditionStatus(check)), nil
+		}
+	}
+	return false, nil
+}
+
+func conditionStatus(check kustomizev1.HealthCheckCondition) string {
+	if check.Status == "" {
+		return "True"
+	}
+	return string(check.Status)
+}
+
 func (hc *KustomizeHealthCheck) toObjMetadata(cr []meta.NamespacedObjectKindReference) ([]object.ObjMetadata, error) {
 	oo := []object.ObjMetadata{}
 	for _, c := range cr {
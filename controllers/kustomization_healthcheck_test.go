@@ -0,0 +1,142 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var _ = Describe("AssessConditions", func() {
+	var (
+		namespace    *corev1.Namespace
+		directClient client.Client
+		target       *kustomizev1.Kustomization
+	)
+
+	BeforeEach(func() {
+		var err error
+		directClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+		Expect(err).NotTo(HaveOccurred())
+
+		namespace = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "conditions-" + randStringRunes(5)},
+		}
+		Expect(directClient.Create(context.Background(), namespace)).To(Succeed())
+
+		target = &kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "target",
+				Namespace: namespace.Name,
+			},
+			Spec: kustomizev1.KustomizationSpec{
+				Interval: metav1.Duration{Duration: time.Minute},
+				Path:     "./",
+				SourceRef: kustomizev1.CrossNamespaceSourceReference{
+					Kind: "GitRepository",
+					Name: "missing",
+				},
+				Suspend: true,
+			},
+		}
+		Expect(directClient.Create(context.Background(), target)).To(Succeed())
+
+		apimeta.SetStatusCondition(&target.Status.Conditions, metav1.Condition{
+			Type:    "Progressing",
+			Status:  metav1.ConditionFalse,
+			Reason:  "RolloutCompleted",
+			Message: "rollout completed",
+		})
+		Expect(directClient.Status().Update(context.Background(), target)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(directClient.Delete(context.Background(), namespace)).To(Succeed())
+	})
+
+	check := func(status metav1.ConditionStatus) kustomizev1.HealthCheckCondition {
+		return kustomizev1.HealthCheckCondition{
+			ObjectRef: meta.NamespacedObjectKindReference{
+				APIVersion: kustomizev1.GroupVersion.String(),
+				Kind:       kustomizev1.KustomizationKind,
+				Name:       target.Name,
+				Namespace:  target.Namespace,
+			},
+			Type:   "Progressing",
+			Status: status,
+		}
+	}
+
+	It("passes when the condition has the expected status", func() {
+		kustomization := kustomizev1.Kustomization{
+			Spec: kustomizev1.KustomizationSpec{
+				HealthCheckConditions: []kustomizev1.HealthCheckCondition{check(metav1.ConditionFalse)},
+				Timeout:               &metav1.Duration{Duration: 2 * time.Second},
+			},
+		}
+		hc := NewHealthCheck(kustomization, nil)
+		Expect(hc.AssessConditions(directClient, 100*time.Millisecond)).To(Succeed())
+	})
+
+	It("times out when the condition doesn't have the expected status", func() {
+		kustomization := kustomizev1.Kustomization{
+			Spec: kustomizev1.KustomizationSpec{
+				HealthCheckConditions: []kustomizev1.HealthCheckCondition{check(metav1.ConditionFalse), check("")},
+				Timeout:               &metav1.Duration{Duration: time.Second},
+			},
+		}
+		hc := NewHealthCheck(kustomization, nil)
+		err := hc.AssessConditions(directClient, 100*time.Millisecond)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("Progressing=True"))
+		Expect(err.Error()).NotTo(ContainSubstring("Progressing=False"))
+	})
+
+	It("reports the pending conditions when the timeout expires during a fetch", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		ok, err := hasCondition(ctx, directClient, check(metav1.ConditionFalse))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+	})
+
+	It("assesses the conditions of many objects concurrently", func() {
+		checks := make([]kustomizev1.HealthCheckCondition, 2*maxHealthCheckWorkers)
+		for i := range checks {
+			checks[i] = check(metav1.ConditionFalse)
+		}
+		kustomization := kustomizev1.Kustomization{
+			Spec: kustomizev1.KustomizationSpec{
+				HealthCheckConditions: checks,
+				Timeout:               &metav1.Duration{Duration: 2 * time.Second},
+			},
+		}
+		hc := NewHealthCheck(kustomization, nil)
+		Expect(hc.AssessAll(directClient, 100*time.Millisecond)).To(Succeed())
+	})
+})
@@ -0,0 +1,76 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// helmReleaseGroupVersionKind is the kind of the HelmReleases reconciled by
+// helm-controller, read as unstructured objects to not depend on its API.
+var helmReleaseGroupVersionKind = schema.GroupVersionKind{
+	Group:   "helm.toolkit.fluxcd.io",
+	Version: "v2beta1",
+	Kind:    "HelmRelease",
+}
+
+// checkHelmReleaseDependency returns an error if the HelmRelease
+// can't be found or is not ready.
+func (r *KustomizationReconciler) checkHelmReleaseDependency(ctx context.Context, name types.NamespacedName) error {
+	hr := &unstructured.Unstructured{}
+	hr.SetGroupVersionKind(helmReleaseGroupVersionKind)
+	if err := r.Get(ctx, name, hr); err != nil {
+		return fmt.Errorf("unable to get HelmRelease '%s' dependency: %w", name, err)
+	}
+
+	ready, err := isHelmReleaseReady(hr)
+	if err != nil {
+		return fmt.Errorf("unable to read HelmRelease '%s' dependency status: %w", name, err)
+	}
+	if !ready {
+		return fmt.Errorf("HelmRelease dependency '%s' is not ready", name)
+	}
+	return nil
+}
+
+// isHelmReleaseReady returns true if the HelmRelease status is up to date
+// with its generation and its ready condition is true.
+func isHelmReleaseReady(hr *unstructured.Unstructured) (bool, error) {
+	var status struct {
+		ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+		Conditions         []metav1.Condition `json:"conditions,omitempty"`
+	}
+	if s, ok := hr.Object["status"].(map[string]interface{}); ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(s, &status); err != nil {
+			return false, err
+		}
+	}
+
+	if len(status.Conditions) == 0 || hr.GetGeneration() != status.ObservedGeneration {
+		return false, nil
+	}
+	return apimeta.IsStatusConditionTrue(status.Conditions, meta.ReadyCondition), nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+var _ = Describe("isHelmReleaseReady", func() {
+	DescribeTable("evaluates the HelmRelease ready condition",
+		func(manifest string, ready bool) {
+			hr := &unstructured.Unstructured{}
+			Expect(yaml.Unmarshal([]byte(manifest), &hr.Object)).To(Succeed())
+			Expect(isHelmReleaseReady(hr)).To(Equal(ready))
+		},
+		Entry("ready", `apiVersion: helm.toolkit.fluxcd.io/v2beta1
+kind: HelmRelease
+metadata:
+  name: podinfo
+  generation: 2
+status:
+  observedGeneration: 2
+  conditions:
+  - type: Ready
+    status: "True"
+    reason: ReconciliationSucceeded
+    message: Release reconciliation succeeded
+    lastTransitionTime: "2021-03-01T10:00:00Z"
+`, true),
+		Entry("not reconciled yet", `apiVersion: helm.toolkit.fluxcd.io/v2beta1
+kind: HelmRelease
+metadata:
+  name: podinfo
+  generation: 1
+`, false),
+		Entry("stale status", `apiVersion: helm.toolkit.fluxcd.io/v2beta1
+kind: HelmRelease
+metadata:
+  name: podinfo
+  generation: 3
+status:
+  observedGeneration: 2
+  conditions:
+  - type: Ready
+    status: "True"
+    reason: ReconciliationSucceeded
+    message: Release reconciliation succeeded
+    lastTransitionTime: "2021-03-01T10:00:00Z"
+`, false),
+		Entry("install failed", `apiVersion: helm.toolkit.fluxcd.io/v2beta1
+kind: HelmRelease
+metadata:
+  name: podinfo
+  generation: 1
+status:
+  observedGeneration: 1
+  conditions:
+  - type: Ready
+    status: "False"
+    reason: InstallFailed
+    message: Helm install failed
+    lastTransitionTime: "2021-03-01T10:00:00Z"
+`, false),
+	)
+})
@@ -0,0 +1,156 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// mergeHPAReplicas rewrites the manifests file with the live replicas
+// of the objects scaled by a HorizontalPodAutoscaler.
+func (r *KustomizationReconciler) mergeHPAReplicas(ctx context.Context, kubeClient client.Client,
+	kustomization kustomizev1.Kustomization, dirPath string) error {
+	if !kustomization.Spec.PreserveHPAReplicas {
+		return nil
+	}
+
+	manifestsFile := filepath.Join(dirPath, fmt.Sprintf("%s.yaml", kustomization.GetUID()))
+	manifests, err := ioutil.ReadFile(manifestsFile)
+	if err != nil {
+		return err
+	}
+
+	merged, count, err := preserveHPAReplicas(ctx, kubeClient, manifests)
+	if err != nil {
+		return fmt.Errorf("preserving HPA replicas failed: %w", err)
+	}
+	if count == 0 {
+		return nil
+	}
+
+	(logr.FromContext(ctx)).V(1).Info(fmt.Sprintf("Preserved the replicas of %d autoscaled objects", count))
+	return ioutil.WriteFile(manifestsFile, merged, os.ModePerm)
+}
+
+// preserveHPAReplicas sets the replicas of the Deployments and StatefulSets of
+// the multi-doc YAML that are the scale target of a HorizontalPodAutoscaler
+// on the cluster to their live value, and returns the updated manifests and
+// the number of objects changed. Objects absent from the cluster are left as is.
+func preserveHPAReplicas(ctx context.Context, kubeClient client.Client, manifests []byte) ([]byte, int, error) {
+	targets := make(map[string]map[string]bool)
+	objects, err := parseManifests(manifests)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var docs []manifest
+	count := 0
+	for _, m := range objects {
+		obj := m.object
+		if obj.Object == nil || obj.GroupVersionKind().Group != "apps" ||
+			(obj.GetKind() != "Deployment" && obj.GetKind() != "StatefulSet") {
+			docs = append(docs, m)
+			continue
+		}
+
+		namespaceTargets, ok := targets[obj.GetNamespace()]
+		if !ok {
+			var err error
+			namespaceTargets, err = hpaTargets(ctx, kubeClient, obj.GetNamespace())
+			if err != nil {
+				return nil, 0, err
+			}
+			targets[obj.GetNamespace()] = namespaceTargets
+		}
+		if !namespaceTargets[obj.GetKind()+"/"+obj.GetName()] {
+			docs = append(docs, m)
+			continue
+		}
+
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		err := kubeClient.Get(ctx, types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}, live)
+		if apierrors.IsNotFound(err) {
+			docs = append(docs, m)
+			continue
+		} else if err != nil {
+			return nil, 0, fmt.Errorf("unable to get %s: %w", objectName(obj), err)
+		}
+
+		replicas, found, err := unstructured.NestedInt64(live.Object, "spec", "replicas")
+		if err != nil || !found {
+			docs = append(docs, m)
+			continue
+		}
+		// numbers decoded from YAML are float64, compare their string representation
+		if current, ok, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", "replicas"); ok &&
+			fmt.Sprint(current) == fmt.Sprint(replicas) {
+			docs = append(docs, m)
+			continue
+		}
+
+		if err := unstructured.SetNestedField(obj.Object, replicas, "spec", "replicas"); err != nil {
+			return nil, 0, err
+		}
+		out, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, 0, err
+		}
+		docs = append(docs, manifest{doc: out, object: obj})
+		count++
+	}
+	return joinManifests(docs), count, nil
+}
+
+// hpaTargets returns the Deployments and StatefulSets scaled by the
+// HorizontalPodAutoscalers of a namespace, in the <kind>/<name> format.
+func hpaTargets(ctx context.Context, kubeClient client.Client, namespace string) (map[string]bool, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   "autoscaling",
+		Version: "v1",
+		Kind:    "HorizontalPodAutoscalerList",
+	})
+	if err := kubeClient.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("unable to list HorizontalPodAutoscalers in '%s': %w", namespace, err)
+	}
+
+	targets := make(map[string]bool)
+	for _, hpa := range list.Items {
+		ref, _, _ := unstructured.NestedStringMap(hpa.Object, "spec", "scaleTargetRef")
+		gv, err := schema.ParseGroupVersion(ref["apiVersion"])
+		if err != nil || gv.Group != "apps" {
+			continue
+		}
+		targets[ref["kind"]+"/"+ref["name"]] = true
+	}
+	return targets, nil
+}
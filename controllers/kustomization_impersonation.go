@@ -22,8 +22,10 @@ import (
 	"io/ioutil"
 	"strings"
 
+	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"sigs.k8s.io/cli-utils/pkg/kstatus/polling"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -34,9 +36,11 @@ import (
 )
 
 type KustomizeImpersonation struct {
-	workdir       string
-	kustomization kustomizev1.Kustomization
-	statusPoller  *polling.StatusPoller
+	workdir               string
+	kustomization         kustomizev1.Kustomization
+	statusPoller          *polling.StatusPoller
+	defaultServiceAccount string
+	userAgent             string
 	client.Client
 }
 
@@ -44,19 +48,45 @@ func NewKustomizeImpersonation(
 	kustomization kustomizev1.Kustomization,
 	kubeClient client.Client,
 	statusPoller *polling.StatusPoller,
+	defaultServiceAccount string,
+	userAgent string,
 	workdir string) *KustomizeImpersonation {
 	return &KustomizeImpersonation{
-		workdir:       workdir,
-		kustomization: kustomization,
-		statusPoller:  statusPoller,
-		Client:        kubeClient,
+		workdir:               workdir,
+		kustomization:         kustomization,
+		statusPoller:          statusPoller,
+		defaultServiceAccount: defaultServiceAccount,
+		userAgent:             userAgent,
+		Client:                kubeClient,
 	}
 }
 
+// UserAgent returns the user agent of the impersonated clients, made of the
+// controller user agent and the Kustomization namespaced name, so that the
+// API server audit logs can be traced back to the Kustomization.
+func (ki *KustomizeImpersonation) UserAgent() string {
+	userAgent := ki.userAgent
+	if userAgent == "" {
+		userAgent = rest.DefaultKubernetesUserAgent()
+	}
+	return fmt.Sprintf("%s kustomization/%s/%s", userAgent,
+		ki.kustomization.GetNamespace(), ki.kustomization.GetName())
+}
+
+// ServiceAccountName returns the name of the service account to impersonate,
+// falling back to the controller's default service account when
+// ServiceAccountName is not set on the Kustomization.
+func (ki *KustomizeImpersonation) ServiceAccountName() string {
+	if ki.kustomization.Spec.ServiceAccountName != "" {
+		return ki.kustomization.Spec.ServiceAccountName
+	}
+	return ki.defaultServiceAccount
+}
+
 func (ki *KustomizeImpersonation) GetServiceAccountToken(ctx context.Context) (string, error) {
 	namespacedName := types.NamespacedName{
 		Namespace: ki.kustomization.Namespace,
-		Name:      ki.kustomization.Spec.ServiceAccountName,
+		Name:      ki.ServiceAccountName(),
 	}
 
 	var serviceAccount corev1.ServiceAccount
@@ -67,7 +97,7 @@ func (ki *KustomizeImpersonation) GetServiceAccountToken(ctx context.Context) (s
 
 	secretName := types.NamespacedName{
 		Namespace: ki.kustomization.Namespace,
-		Name:      ki.kustomization.Spec.ServiceAccountName,
+		Name:      ki.ServiceAccountName(),
 	}
 
 	for _, secret := range serviceAccount.Secrets {
@@ -96,11 +126,13 @@ func (ki *KustomizeImpersonation) GetServiceAccountToken(ctx context.Context) (s
 // GetClient creates a controller-runtime client for talking to a Kubernetes API server.
 // If KubeConfig is set, will use the kubeconfig bytes from the Kubernetes secret.
 // If ServiceAccountName is set, will use the cluster provided kubeconfig impersonating the SA.
+// If ServiceAccountName is not set and a default service account is configured,
+// will use the cluster provided kubeconfig impersonating the default SA.
 // If --kubeconfig is set, will use the kubeconfig file at that location.
 // Otherwise will assume running in cluster and use the cluster provided kubeconfig.
 func (ki *KustomizeImpersonation) GetClient(ctx context.Context) (client.Client, *polling.StatusPoller, error) {
 	if ki.kustomization.Spec.KubeConfig == nil {
-		if ki.kustomization.Spec.ServiceAccountName != "" {
+		if ki.ServiceAccountName() != "" {
 			return ki.clientForServiceAccount(ctx)
 		}
 
@@ -109,6 +141,20 @@ func (ki *KustomizeImpersonation) GetClient(ctx context.Context) (client.Client,
 	return ki.clientForKubeConfig(ctx)
 }
 
+// GetReader returns an uncached reader with the identity of the client
+// returned by GetClient, apiReader is returned when that identity is the
+// controller's own.
+func (ki *KustomizeImpersonation) GetReader(ctx context.Context, apiReader client.Reader) (client.Reader, error) {
+	if ki.kustomization.Spec.KubeConfig == nil && ki.ServiceAccountName() == "" {
+		return apiReader, nil
+	}
+	kubeClient, _, err := ki.GetClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return kubeClient, nil
+}
+
 func (ki *KustomizeImpersonation) clientForServiceAccount(ctx context.Context) (client.Client, *polling.StatusPoller, error) {
 	token, err := ki.GetServiceAccountToken(ctx)
 	if err != nil {
@@ -119,6 +165,7 @@ func (ki *KustomizeImpersonation) clientForServiceAccount(ctx context.Context) (
 		return nil, nil, err
 	}
 	restConfig.BearerToken = token
+	restConfig.UserAgent = ki.UserAgent()
 
 	restMapper, err := apiutil.NewDynamicRESTMapper(restConfig)
 	if err != nil {
@@ -140,11 +187,15 @@ func (ki *KustomizeImpersonation) clientForKubeConfig(ctx context.Context) (clie
 	if err != nil {
 		return nil, nil, err
 	}
+	if ki.kustomization.Spec.KubeConfig.InsecureSkipTLSVerify {
+		(logr.FromContext(ctx)).Info("WARNING: the TLS verification of the remote cluster API server is disabled")
+	}
 
 	restConfig, err := clientcmd.RESTConfigFromKubeConfig(kubeConfigBytes)
 	if err != nil {
 		return nil, nil, err
 	}
+	restConfig.UserAgent = ki.UserAgent()
 
 	restMapper, err := apiutil.NewDynamicRESTMapper(restConfig)
 	if err != nil {
@@ -199,5 +250,63 @@ func (ki *KustomizeImpersonation) getKubeConfig(ctx context.Context) ([]byte, er
 		return nil, fmt.Errorf("KubeConfig secret '%s' doesn't contain a 'value' key ", secretName.String())
 	}
 
-	return kubeConfig, nil
+	spec := ki.kustomization.Spec.KubeConfig
+	if spec.Context == "" && spec.CASecretRef == nil && !spec.InsecureSkipTLSVerify {
+		return kubeConfig, nil
+	}
+
+	cfg, err := clientcmd.Load(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load KubeConfig secret '%s' error: %w", secretName.String(), err)
+	}
+	if spec.Context != "" {
+		cfg.CurrentContext = spec.Context
+	}
+	kubeContext, ok := cfg.Contexts[cfg.CurrentContext]
+	if !ok {
+		return nil, fmt.Errorf("KubeConfig secret '%s' doesn't contain a '%s' context", secretName.String(), cfg.CurrentContext)
+	}
+
+	if spec.CASecretRef != nil || spec.InsecureSkipTLSVerify {
+		cluster, ok := cfg.Clusters[kubeContext.Cluster]
+		if !ok {
+			return nil, fmt.Errorf("KubeConfig secret '%s' doesn't contain a '%s' cluster", secretName.String(), kubeContext.Cluster)
+		}
+		if spec.InsecureSkipTLSVerify {
+			// client-go refuses certificate authorities together with the insecure flag
+			cluster.InsecureSkipTLSVerify = true
+			cluster.CertificateAuthority = ""
+			cluster.CertificateAuthorityData = nil
+		} else {
+			caBundle, err := ki.getCABundle(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if len(cluster.CertificateAuthorityData) > 0 {
+				cluster.CertificateAuthorityData = append(cluster.CertificateAuthorityData, '\n')
+			}
+			cluster.CertificateAuthorityData = append(cluster.CertificateAuthorityData, caBundle...)
+		}
+	}
+
+	return clientcmd.Write(*cfg)
+}
+
+// getCABundle returns the PEM encoded CA bundle of the CASecretRef secret.
+func (ki *KustomizeImpersonation) getCABundle(ctx context.Context) ([]byte, error) {
+	secretName := types.NamespacedName{
+		Namespace: ki.kustomization.GetNamespace(),
+		Name:      ki.kustomization.Spec.KubeConfig.CASecretRef.Name,
+	}
+
+	var secret corev1.Secret
+	if err := ki.Get(ctx, secretName, &secret); err != nil {
+		return nil, fmt.Errorf("unable to read CA secret '%s' error: %w", secretName.String(), err)
+	}
+
+	caBundle, ok := secret.Data["ca.crt"]
+	if !ok {
+		return nil, fmt.Errorf("CA secret '%s' doesn't contain a 'ca.crt' key", secretName.String())
+	}
+	return caBundle, nil
 }
@@ -0,0 +1,161 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var _ = Describe("KustomizeImpersonation kubeconfig", func() {
+	var (
+		namespace    *corev1.Namespace
+		directClient client.Client
+	)
+
+	BeforeEach(func() {
+		var err error
+		directClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+		Expect(err).NotTo(HaveOccurred())
+
+		namespace = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "kubeconfig-" + randStringRunes(5)},
+		}
+		Expect(directClient.Create(context.Background(), namespace)).To(Succeed())
+
+		kubeConfig := clientcmdapi.NewConfig()
+		kubeConfig.Clusters["stage"] = &clientcmdapi.Cluster{
+			Server:                   "https://stage.example.com",
+			CertificateAuthorityData: []byte("embedded-ca"),
+		}
+		kubeConfig.Clusters["prod"] = &clientcmdapi.Cluster{Server: "https://prod.example.com"}
+		kubeConfig.AuthInfos["admin"] = &clientcmdapi.AuthInfo{Token: "token"}
+		kubeConfig.Contexts["stage"] = &clientcmdapi.Context{Cluster: "stage", AuthInfo: "admin"}
+		kubeConfig.Contexts["prod"] = &clientcmdapi.Context{Cluster: "prod", AuthInfo: "admin"}
+		kubeConfig.CurrentContext = "stage"
+		data, err := clientcmd.Write(*kubeConfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(directClient.Create(context.Background(), &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "kubeconfig", Namespace: namespace.Name},
+			Data:       map[string][]byte{"value": data},
+		})).To(Succeed())
+		Expect(directClient.Create(context.Background(), &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "ca", Namespace: namespace.Name},
+			Data:       map[string][]byte{"ca.crt": []byte("private-ca")},
+		})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(directClient.Delete(context.Background(), namespace)).To(Succeed())
+	})
+
+	getKubeConfig := func(spec kustomizev1.KubeConfig) ([]byte, error) {
+		spec.SecretRef = meta.LocalObjectReference{Name: "kubeconfig"}
+		kustomization := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{Name: "remote", Namespace: namespace.Name},
+			Spec:       kustomizev1.KustomizationSpec{KubeConfig: &spec},
+		}
+		imp := NewKustomizeImpersonation(kustomization, directClient, nil, "", "", "")
+		return imp.getKubeConfig(context.Background())
+	}
+
+	load := func(spec kustomizev1.KubeConfig) *clientcmdapi.Config {
+		data, err := getKubeConfig(spec)
+		Expect(err).NotTo(HaveOccurred())
+		kubeConfig, err := clientcmd.Load(data)
+		Expect(err).NotTo(HaveOccurred())
+		return kubeConfig
+	}
+
+	cluster := func(spec kustomizev1.KubeConfig) *clientcmdapi.Cluster {
+		return load(spec).Clusters["stage"]
+	}
+
+	It("keeps the current context by default", func() {
+		Expect(load(kustomizev1.KubeConfig{}).CurrentContext).To(Equal("stage"))
+	})
+
+	It("switches to the context of the Kustomization", func() {
+		Expect(load(kustomizev1.KubeConfig{Context: "prod"}).CurrentContext).To(Equal("prod"))
+	})
+
+	It("fails when the context doesn't exist", func() {
+		_, err := getKubeConfig(kustomizev1.KubeConfig{Context: "dev"})
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("doesn't contain a 'dev' context"))
+	})
+
+	It("adds the CA bundle to the cluster certificate authorities", func() {
+		c := cluster(kustomizev1.KubeConfig{CASecretRef: &meta.LocalObjectReference{Name: "ca"}})
+		Expect(string(c.CertificateAuthorityData)).To(Equal("embedded-ca\nprivate-ca"))
+		Expect(c.InsecureSkipTLSVerify).To(BeFalse())
+	})
+
+	It("disables the TLS verification when insecure", func() {
+		c := cluster(kustomizev1.KubeConfig{
+			CASecretRef:           &meta.LocalObjectReference{Name: "ca"},
+			InsecureSkipTLSVerify: true,
+		})
+		Expect(c.CertificateAuthorityData).To(BeEmpty())
+		Expect(c.InsecureSkipTLSVerify).To(BeTrue())
+	})
+})
+
+var _ = Describe("KustomizeImpersonation identity", func() {
+	kustomization := func(serviceAccountName string) kustomizev1.Kustomization {
+		return kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{Name: "apps", Namespace: "tenant"},
+			Spec:       kustomizev1.KustomizationSpec{ServiceAccountName: serviceAccountName},
+		}
+	}
+
+	It("falls back to the default service account", func() {
+		imp := NewKustomizeImpersonation(kustomization(""), nil, nil, "reconciler", "", "")
+		Expect(imp.ServiceAccountName()).To(Equal("reconciler"))
+	})
+
+	It("uses the service account of the Kustomization", func() {
+		imp := NewKustomizeImpersonation(kustomization("deployer"), nil, nil, "reconciler", "", "")
+		Expect(imp.ServiceAccountName()).To(Equal("deployer"))
+
+		imp = NewKustomizeImpersonation(kustomization(""), nil, nil, "", "", "")
+		Expect(imp.ServiceAccountName()).To(BeEmpty())
+	})
+
+	It("appends the Kustomization to the user agent", func() {
+		imp := NewKustomizeImpersonation(kustomization(""), nil, nil, "", "kustomize-controller/v0.9.0", "")
+		Expect(imp.UserAgent()).To(Equal("kustomize-controller/v0.9.0 kustomization/tenant/apps"))
+	})
+
+	It("falls back to the client-go user agent", func() {
+		imp := NewKustomizeImpersonation(kustomization(""), nil, nil, "", "", "")
+		Expect(imp.UserAgent()).To(Equal(rest.DefaultKubernetesUserAgent() + " kustomization/tenant/apps"))
+	})
+})
@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
@@ -137,3 +138,42 @@ func (r *KustomizationReconciler) indexByBucket(o client.Object) []string {
 
 	return nil
 }
+
+func (r *KustomizationReconciler) requestsForPreconditionChange(obj client.Object) []reconcile.Request {
+	gk := obj.GetObjectKind().GroupVersionKind().GroupKind()
+
+	ctx := context.Background()
+	var list kustomizev1.KustomizationList
+	if err := r.List(ctx, &list, client.MatchingFields{
+		kustomizev1.PreconditionIndexKey: preconditionIndexValue(gk, obj.GetNamespace(), obj.GetName()),
+	}); err != nil {
+		return nil
+	}
+	reqs := make([]reconcile.Request, len(list.Items), len(list.Items))
+	for i := range list.Items {
+		reqs[i].NamespacedName.Name = list.Items[i].Name
+		reqs[i].NamespacedName.Namespace = list.Items[i].Namespace
+	}
+	return reqs
+}
+
+func (r *KustomizationReconciler) indexByPrecondition(o client.Object) []string {
+	k, ok := o.(*kustomizev1.Kustomization)
+	if !ok {
+		panic(fmt.Sprintf("Expected a Kustomization, got %T", o))
+	}
+
+	var keys []string
+	for _, p := range k.Spec.Preconditions {
+		if !p.Watch {
+			continue
+		}
+		gk := preconditionGroupVersionKind(p).GroupKind()
+		keys = append(keys, preconditionIndexValue(gk, k.GetNamespace(), p.ObjectRef.Name))
+	}
+	return keys
+}
+
+func preconditionIndexValue(gk schema.GroupKind, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s", gk.String(), namespace, name)
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+// expandLists replaces the List objects of the build output with their items,
+// so that each item is applied, garbage collected and health checked on its
+// own. The items inherit the labels and annotations set on the List by the
+// kustomize transformers, their own labels and annotations take precedence.
+func expandLists(m resmap.ResMap) error {
+	var lists []*resource.Resource
+	for _, res := range m.Resources() {
+		if res.GetKind() == "List" {
+			lists = append(lists, res)
+		}
+	}
+	if len(lists) == 0 {
+		return nil
+	}
+
+	resources := m.Resources()
+	m.Clear()
+	for _, res := range resources {
+		if res.GetKind() != "List" {
+			if err := m.Append(res); err != nil {
+				return err
+			}
+			continue
+		}
+
+		items, err := listItems(res)
+		if err != nil {
+			return fmt.Errorf("failed to expand List '%s': %w", res.GetName(), err)
+		}
+		for _, item := range items {
+			if err := m.Append(item); err != nil {
+				return fmt.Errorf("failed to expand List '%s': %w", res.GetName(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// listItems returns the items of a List as resources.
+func listItems(list *resource.Resource) ([]*resource.Resource, error) {
+	data, err := list.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var obj unstructured.Unstructured
+	if err := json.Unmarshal(data, &obj.Object); err != nil {
+		return nil, err
+	}
+	items, _, err := unstructured.NestedSlice(obj.Object, "items")
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []*resource.Resource
+	for i, item := range items {
+		itemObj, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("item %d is not an object", i)
+		}
+		u := unstructured.Unstructured{Object: itemObj}
+		if u.GetKind() == "" || u.GetName() == "" {
+			return nil, fmt.Errorf("item %d has no kind or name", i)
+		}
+		u.SetLabels(mergeStringMaps(obj.GetLabels(), u.GetLabels()))
+		u.SetAnnotations(mergeStringMaps(obj.GetAnnotations(), u.GetAnnotations()))
+
+		itemData, err := json.Marshal(u.Object)
+		if err != nil {
+			return nil, err
+		}
+		res := list.DeepCopy()
+		if err := res.UnmarshalJSON(itemData); err != nil {
+			return nil, err
+		}
+		resources = append(resources, res)
+	}
+	return resources, nil
+}
+
+// mergeStringMaps returns the union of the maps, the values of
+// the second map take precedence, or nil if both are empty.
+func mergeStringMaps(base, overrides map[string]string) map[string]string {
+	if len(base) == 0 && len(overrides) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(base)+len(overrides))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
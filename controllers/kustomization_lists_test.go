@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/api/k8sdeps/kunstruct"
+	"sigs.k8s.io/kustomize/api/resmap"
+	"sigs.k8s.io/kustomize/api/resource"
+)
+
+var _ = Describe("expandLists", func() {
+	It("replaces a v1/List with its ConfigMaps", func() {
+		rf := resource.NewFactory(kunstruct.NewKunstructuredFactoryImpl())
+		m := resmap.New()
+		Expect(m.Append(rf.FromMap(map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata":   map[string]interface{}{"name": "first"},
+		}))).To(Succeed())
+		Expect(m.Append(rf.FromMap(map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "List",
+			"metadata": map[string]interface{}{
+				"name": "configs",
+				"labels": map[string]interface{}{
+					"kustomize.toolkit.fluxcd.io/name": "app",
+					"tier":                             "backend",
+				},
+			},
+			"items": []interface{}{
+				map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "ConfigMap",
+					"metadata":   map[string]interface{}{"name": "app-a", "namespace": "apps"},
+					"data":       map[string]interface{}{"key": "a"},
+				},
+				map[string]interface{}{
+					"apiVersion": "v1",
+					"kind":       "ConfigMap",
+					"metadata": map[string]interface{}{
+						"name":      "app-b",
+						"namespace": "apps",
+						"labels":    map[string]interface{}{"tier": "frontend"},
+					},
+					"data": map[string]interface{}{"key": "b"},
+				},
+			},
+		}))).To(Succeed())
+
+		Expect(expandLists(m)).To(Succeed())
+
+		resources := m.Resources()
+		Expect(resources).To(HaveLen(3))
+		Expect(resources[0].GetName()).To(Equal("first"))
+		Expect(resources[1].GetKind()).To(Equal("ConfigMap"))
+		Expect(resources[1].GetName()).To(Equal("app-a"))
+		Expect(resources[1].GetNamespace()).To(Equal("apps"))
+		Expect(resources[1].GetLabels()).To(Equal(map[string]string{
+			"kustomize.toolkit.fluxcd.io/name": "app",
+			"tier":                             "backend",
+		}))
+		Expect(resources[2].GetName()).To(Equal("app-b"))
+		Expect(resources[2].GetLabels()).To(HaveKeyWithValue("tier", "frontend"))
+	})
+
+	It("rejects the items without kind", func() {
+		rf := resource.NewFactory(kunstruct.NewKunstructuredFactoryImpl())
+		m := resmap.New()
+		Expect(m.Append(rf.FromMap(map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "List",
+			"metadata":   map[string]interface{}{"name": "configs"},
+			"items": []interface{}{
+				map[string]interface{}{"metadata": map[string]interface{}{"name": "app"}},
+			},
+		}))).To(Succeed())
+		Expect(expandLists(m)).To(MatchError("failed to expand List 'configs': item 0 has no kind or name"))
+	})
+})
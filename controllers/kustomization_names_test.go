@@ -0,0 +1,127 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/kustomize/api/filesys"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var _ = Describe("KustomizeGenerator name prefix and suffix", func() {
+	const dirPath = "/app"
+
+	var (
+		namespace    *corev1.Namespace
+		directClient client.Client
+	)
+
+	BeforeEach(func() {
+		var err error
+		directClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+		Expect(err).NotTo(HaveOccurred())
+
+		namespace = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "names-test-" + randStringRunes(5)},
+		}
+		Expect(directClient.Create(context.Background(), namespace)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(directClient.Delete(context.Background(), namespace)).To(Succeed())
+	})
+
+	generate := func(k kustomizev1.Kustomization) (string, []byte, []string) {
+		fs := filesys.MakeFsInMemory()
+		Expect(fs.MkdirAll(dirPath)).To(Succeed())
+		Expect(fs.WriteFile(dirPath+"/kustomization.yaml", []byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+namePrefix: app-
+resources:
+- configmap.yaml
+`))).To(Succeed())
+		Expect(fs.WriteFile(dirPath+"/configmap.yaml", []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+`))).To(Succeed())
+
+		checksum, err := NewGenerator(k, fs).WriteFile(dirPath)
+		Expect(err).NotTo(HaveOccurred())
+		m, err := buildKustomization(fs, dirPath)
+		Expect(err).NotTo(HaveOccurred())
+		manifests, err := m.AsYaml()
+		Expect(err).NotTo(HaveOccurred())
+
+		var names []string
+		for _, res := range m.Resources() {
+			names = append(names, res.GetName())
+			obj := &unstructured.Unstructured{Object: res.Map()}
+			Expect(directClient.Create(context.Background(), obj)).To(Succeed())
+		}
+		return checksum, manifests, names
+	}
+
+	It("prunes the objects with the previous names", func() {
+		k := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "tenant",
+				Namespace: "flux-system",
+			},
+			Spec: kustomizev1.KustomizationSpec{
+				Prune:           true,
+				TargetNamespace: namespace.Name,
+				NamePrefix:      "tenant-a-",
+				NameSuffix:      "-v1",
+			},
+		}
+		checksum, manifests, names := generate(k)
+		Expect(names).To(Equal([]string{"tenant-a-app-config-v1"}))
+		snapshot, err := kustomizev1.NewSnapshot(manifests, checksum)
+		Expect(err).NotTo(HaveOccurred())
+
+		k.Spec.NameSuffix = "-v2"
+		newChecksum, _, names := generate(k)
+		Expect(names).To(Equal([]string{"tenant-a-app-config-v2"}))
+		Expect(newChecksum).NotTo(Equal(checksum))
+
+		gc := NewGarbageCollector(directClient, *snapshot, newChecksum, GarbageCollectorOptions{}, ctrl.Log)
+		output, _, ok := gc.Prune(time.Minute, k.Name, k.Namespace)
+		Expect(ok).To(BeTrue(), output)
+		Expect(output).To(ContainSubstring(fmt.Sprintf("ConfigMap/%s/tenant-a-app-config-v1 deleted", namespace.Name)))
+
+		err = directClient.Get(context.Background(),
+			types.NamespacedName{Namespace: namespace.Name, Name: "tenant-a-app-config-v1"}, &corev1.ConfigMap{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		Expect(directClient.Get(context.Background(),
+			types.NamespacedName{Namespace: namespace.Name, Name: "tenant-a-app-config-v2"}, &corev1.ConfigMap{})).To(Succeed())
+	})
+})
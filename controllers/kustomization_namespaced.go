@@ -0,0 +1,120 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/fluxcd/pkg/runtime/events"
+	"github.com/go-logr/logr"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// checkMissingNamespaces reports the namespaced objects of the manifests file
+// that have no namespace, when the Kustomization has no target namespace, and
+// returns an error when the MissingNamespacePolicy is 'Fail'.
+func (r *KustomizationReconciler) checkMissingNamespaces(ctx context.Context, kubeClient client.Client,
+	kustomization kustomizev1.Kustomization, revision, dirPath string) error {
+	if kustomization.Spec.MissingNamespacePolicy == "" || kustomization.Spec.TargetNamespace != "" ||
+		kustomization.Spec.TargetNamespaceSelector != nil {
+		return nil
+	}
+
+	manifests, err := ioutil.ReadFile(filepath.Join(dirPath, fmt.Sprintf("%s.yaml", kustomization.GetUID())))
+	if err != nil {
+		return err
+	}
+
+	missing, err := missingNamespaces(kubeClient.RESTMapper(), manifests)
+	if err != nil {
+		return fmt.Errorf("namespace check failed: %w", err)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	msg := fmt.Sprintf("namespaced objects without namespace: %s", strings.Join(missing, ", "))
+	if kustomization.Spec.MissingNamespacePolicy == kustomizev1.MissingNamespaceFail {
+		return errors.New(msg)
+	}
+
+	(logr.FromContext(ctx)).Info(msg)
+	r.event(ctx, kustomization, revision, events.EventSeverityError, msg, nil)
+	return nil
+}
+
+// missingNamespaces returns the objects of the multi-doc YAML that have no
+// namespace and whose kind is namespaced. The scope of the kinds is looked up
+// with the REST mapper, or in the CRDs of the manifests for the custom kinds
+// not yet registered on the cluster. The objects of unknown kinds are skipped.
+func missingNamespaces(mapper apimeta.RESTMapper, manifests []byte) ([]string, error) {
+	docs, err := parseManifests(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []unstructured.Unstructured
+	crdScopes := make(map[schema.GroupKind]string)
+	for _, m := range docs {
+		obj := m.object
+		if obj.Object == nil {
+			continue
+		}
+		if isCRD(obj) {
+			group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+			kind, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "kind")
+			scope, _, _ := unstructured.NestedString(obj.Object, "spec", "scope")
+			crdScopes[schema.GroupKind{Group: group, Kind: kind}] = scope
+		}
+		objects = append(objects, obj)
+	}
+
+	var missing []string
+	for _, obj := range objects {
+		if obj.GetNamespace() != "" {
+			continue
+		}
+		gvk := obj.GroupVersionKind()
+		if scope, ok := crdScopes[gvk.GroupKind()]; ok {
+			if scope == "Namespaced" {
+				missing = append(missing, objectName(obj))
+			}
+			continue
+		}
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			if apimeta.IsNoMatchError(err) {
+				continue
+			}
+			return nil, err
+		}
+		if mapping.Scope.Name() == apimeta.RESTScopeNameNamespace {
+			missing = append(missing, objectName(obj))
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+var _ = Describe("missingNamespaces", func() {
+	It("lists the namespaced objects without namespace", func() {
+		mapper, err := apiutil.NewDynamicRESTMapper(cfg)
+		Expect(err).NotTo(HaveOccurred())
+
+		manifests := []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: no-namespace
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: with-namespace
+  namespace: apps
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: reader
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: databases.db.example.com
+spec:
+  group: db.example.com
+  names:
+    kind: Database
+    plural: databases
+  scope: Namespaced
+---
+apiVersion: db.example.com/v1
+kind: Database
+metadata:
+  name: orders
+---
+apiVersion: unknown.example.com/v1
+kind: Widget
+metadata:
+  name: widget
+`)
+		missing, err := missingNamespaces(mapper, manifests)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(missing).To(Equal([]string{"ConfigMap/no-namespace", "Database/orders"}))
+	})
+})
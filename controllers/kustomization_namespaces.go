@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/resmap"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// selectTargetNamespaces returns the sorted names of the namespaces
+// matching the TargetNamespaceSelector of the Kustomization.
+func (r *KustomizationReconciler) selectTargetNamespaces(ctx context.Context,
+	kustomization kustomizev1.Kustomization) ([]string, error) {
+	if kustomization.Spec.TargetNamespace != "" {
+		return nil, fmt.Errorf("targetNamespace and targetNamespaceSelector are mutually exclusive")
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(kustomization.Spec.TargetNamespaceSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid targetNamespaceSelector: %w", err)
+	}
+
+	var list corev1.NamespaceList
+	if err := r.List(ctx, &list, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, fmt.Errorf("unable to list the target namespaces: %w", err)
+	}
+
+	var namespaces []string
+	for _, ns := range list.Items {
+		if ns.DeletionTimestamp.IsZero() {
+			namespaces = append(namespaces, ns.GetName())
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// buildKustomizationInNamespaces builds the kustomization in dirPath once per
+// namespace, with the namespace set as the kustomization namespace, and merges
+// the results. The cluster-scoped objects, identical in every build, are kept
+// once. Each build runs from a kustomization.yaml written in a sibling
+// directory of dirPath, which is removed afterwards.
+func buildKustomizationInNamespaces(fs filesys.FileSystem, dirPath string, namespaces []string) (resmap.ResMap, error) {
+	rootPath := filepath.Clean(dirPath) + ".namespaces"
+	defer fs.RemoveAll(rootPath)
+
+	merged := resmap.New()
+	for _, namespace := range namespaces {
+		nsPath := filepath.Join(rootPath, namespace)
+		if err := fs.MkdirAll(nsPath); err != nil {
+			return nil, err
+		}
+		base, err := filepath.Rel(nsPath, dirPath)
+		if err != nil {
+			return nil, err
+		}
+		kus := kustypes.Kustomization{
+			TypeMeta: kustypes.TypeMeta{
+				APIVersion: kustypes.KustomizationVersion,
+				Kind:       kustypes.KustomizationKind,
+			},
+			Namespace: namespace,
+			Resources: []string{base},
+		}
+		data, err := yaml.Marshal(kus)
+		if err != nil {
+			return nil, err
+		}
+		if err := fs.WriteFile(filepath.Join(nsPath, konfig.DefaultKustomizationFileName()), data); err != nil {
+			return nil, err
+		}
+
+		m, err := buildKustomization(fs, nsPath)
+		if err != nil {
+			return nil, fmt.Errorf("build in namespace '%s' failed: %w", namespace, err)
+		}
+		for _, res := range m.Resources() {
+			if _, err := merged.GetByCurrentId(res.CurId()); err == nil {
+				continue
+			}
+			if err := merged.Append(res); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return merged, nil
+}
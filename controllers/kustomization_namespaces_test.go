@@ -0,0 +1,122 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/kustomize/api/filesys"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var _ = Describe("selectTargetNamespaces", func() {
+	var (
+		directClient client.Client
+		tenant       string
+		namespaces   []*corev1.Namespace
+	)
+
+	BeforeEach(func() {
+		var err error
+		directClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+		Expect(err).NotTo(HaveOccurred())
+
+		tenant = "tenant-" + randStringRunes(5)
+		namespaces = nil
+		for _, labels := range []map[string]string{{"tenant": tenant}, {"tenant": tenant}, {"tenant": "other"}} {
+			ns := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "selector-" + randStringRunes(5), Labels: labels},
+			}
+			Expect(directClient.Create(context.Background(), ns)).To(Succeed())
+			namespaces = append(namespaces, ns)
+		}
+	})
+
+	AfterEach(func() {
+		for _, ns := range namespaces {
+			Expect(directClient.Delete(context.Background(), ns)).To(Succeed())
+		}
+	})
+
+	It("returns the sorted names of the matching namespaces", func() {
+		r := &KustomizationReconciler{Client: directClient}
+		kustomization := kustomizev1.Kustomization{
+			Spec: kustomizev1.KustomizationSpec{
+				TargetNamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": tenant}},
+			},
+		}
+		selected, err := r.selectTargetNamespaces(context.Background(), kustomization)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(selected).To(ConsistOf(namespaces[0].Name, namespaces[1].Name))
+		Expect(selected[0] < selected[1]).To(BeTrue())
+	})
+
+	It("rejects the selector together with the target namespace", func() {
+		r := &KustomizationReconciler{Client: directClient}
+		kustomization := kustomizev1.Kustomization{
+			Spec: kustomizev1.KustomizationSpec{
+				TargetNamespace:         "apps",
+				TargetNamespaceSelector: &metav1.LabelSelector{},
+			},
+		}
+		_, err := r.selectTargetNamespaces(context.Background(), kustomization)
+		Expect(err).To(MatchError("targetNamespace and targetNamespaceSelector are mutually exclusive"))
+	})
+})
+
+var _ = Describe("buildKustomizationInNamespaces", func() {
+	It("renders the namespaced objects in each namespace", func() {
+		fs := filesys.MakeFsInMemory()
+		Expect(fs.MkdirAll("/app")).To(Succeed())
+		Expect(fs.WriteFile("/app/kustomization.yaml", []byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- resources.yaml
+`))).To(Succeed())
+		Expect(fs.WriteFile("/app/resources.yaml", []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: app-reader
+`))).To(Succeed())
+
+		m, err := buildKustomizationInNamespaces(fs, "/app", []string{"tenant-a", "tenant-b"})
+		Expect(err).NotTo(HaveOccurred())
+
+		var objects []string
+		for _, res := range m.Resources() {
+			objects = append(objects, res.GetKind()+"/"+res.GetNamespace()+"/"+res.GetName())
+		}
+		Expect(objects).To(ConsistOf(
+			"ConfigMap/tenant-a/app-config",
+			"ConfigMap/tenant-b/app-config",
+			"ClusterRole//app-reader",
+		))
+		Expect(fs.Exists("/app.namespaces")).To(BeFalse())
+	})
+})
@@ -0,0 +1,86 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// orderManifests rewrites the manifests file with the objects listed
+// in ApplyOrder first, so that kubectl applies them in that order.
+func (r *KustomizationReconciler) orderManifests(kustomization kustomizev1.Kustomization, dirPath string) error {
+	if len(kustomization.Spec.ApplyOrder) == 0 {
+		return nil
+	}
+
+	manifestsFile := filepath.Join(dirPath, fmt.Sprintf("%s.yaml", kustomization.GetUID()))
+	manifests, err := ioutil.ReadFile(manifestsFile)
+	if err != nil {
+		return err
+	}
+
+	ordered, err := orderManifests(manifests, kustomization.Spec.ApplyOrder)
+	if err != nil {
+		return fmt.Errorf("ordering manifests failed: %w", err)
+	}
+	return ioutil.WriteFile(manifestsFile, ordered, os.ModePerm)
+}
+
+// orderManifests moves the objects of the multi-doc YAML matching the
+// references at the beginning, in the order of the references, followed by
+// the other objects in their original order. References that don't match
+// any object are ignored.
+func orderManifests(manifests []byte, order []meta.NamespacedObjectKindReference) ([]byte, error) {
+	docs, err := parseManifests(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	placed := make([]bool, len(docs))
+	var result []manifest
+	for _, ref := range order {
+		for i, m := range docs {
+			if !placed[i] && m.object.Object != nil && matchesObjectReference(m.object, ref) {
+				result = append(result, m)
+				placed[i] = true
+			}
+		}
+	}
+	for i, m := range docs {
+		if !placed[i] {
+			result = append(result, m)
+		}
+	}
+	return joinManifests(result), nil
+}
+
+// matchesObjectReference returns true if the object has the kind, name and
+// namespace of the reference, and its API version when specified.
+func matchesObjectReference(obj unstructured.Unstructured, ref meta.NamespacedObjectKindReference) bool {
+	if ref.APIVersion != "" && obj.GetAPIVersion() != ref.APIVersion {
+		return false
+	}
+	return obj.GetKind() == ref.Kind && obj.GetName() == ref.Name && obj.GetNamespace() == ref.Namespace
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+var _ = Describe("orderManifests", func() {
+	manifests := []byte(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: dev
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: dev
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: backend
+  namespace: dev
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: backend
+  namespace: dev
+`)
+
+	names := func(out []byte) []string {
+		var result []string
+		for _, doc := range bytes.Split(out, []byte("\n---\n")) {
+			var obj unstructured.Unstructured
+			Expect(yaml.Unmarshal(doc, &obj.Object)).To(Succeed())
+			result = append(result, obj.GetKind()+"/"+obj.GetName())
+		}
+		return result
+	}
+
+	It("applies the listed objects first and keeps the order of the others", func() {
+		out, err := orderManifests(manifests, []meta.NamespacedObjectKindReference{
+			{Kind: "Service", Name: "backend", Namespace: "dev"},
+			{APIVersion: "apps/v1", Kind: "Deployment", Name: "backend", Namespace: "dev"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(out)).To(Equal([]string{
+			"Service/backend",
+			"Deployment/backend",
+			"Namespace/dev",
+			"ConfigMap/config",
+		}))
+	})
+
+	It("ignores the objects absent from the manifests", func() {
+		out, err := orderManifests(manifests, []meta.NamespacedObjectKindReference{
+			{Kind: "Secret", Name: "missing", Namespace: "dev"},
+			{Kind: "ConfigMap", Name: "config", Namespace: "dev"},
+			{APIVersion: "apps/v1beta1", Kind: "Deployment", Name: "backend", Namespace: "dev"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(out)).To(Equal([]string{
+			"ConfigMap/config",
+			"Namespace/dev",
+			"Deployment/backend",
+			"Service/backend",
+		}))
+	})
+})
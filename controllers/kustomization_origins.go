@@ -0,0 +1,144 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/konfig"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+	sigyaml "sigs.k8s.io/yaml"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// resourceOrigins builds the kustomization in dirPath and returns the source file
+// of each rendered object, relative to rootPath. The files are found by following
+// the resources of the kustomization.yaml files, objects produced by generators
+// or remote resources have no origin and are left out.
+// The kustomize version in use doesn't support the origin annotations,
+// the objects are matched with the files by their original ID instead.
+func resourceOrigins(fs filesys.FileSystem, rootPath, dirPath string) ([]kustomizev1.ResourceOrigin, error) {
+	files := make(map[string]string)
+	if err := collectOrigins(fs, rootPath, dirPath, files, 0); err != nil {
+		return nil, err
+	}
+
+	m, err := buildKustomization(fs, dirPath)
+	if err != nil {
+		return nil, fmt.Errorf("kustomize build failed: %w", err)
+	}
+
+	var origins []kustomizev1.ResourceOrigin
+	for _, res := range m.Resources() {
+		orgID := res.OrgId()
+		path, ok := files[originKey(orgID.Group, orgID.Kind, orgID.Namespace, orgID.Name)]
+		if !ok {
+			continue
+		}
+		origins = append(origins, kustomizev1.ResourceOrigin{
+			ID: fmt.Sprintf("%s_%s_%s_%s",
+				res.GetNamespace(),
+				strings.ReplaceAll(res.GetName(), ":", "__"),
+				res.GetGvk().Group,
+				res.GetGvk().Kind),
+			Path: path,
+		})
+	}
+	return origins, nil
+}
+
+// collectOrigins records the path of the file declaring each object listed
+// in the resources of the kustomization.yaml in dirPath, and of the
+// kustomizations it refers to, up to a fixed depth.
+func collectOrigins(fs filesys.FileSystem, rootPath, dirPath string, files map[string]string, depth int) error {
+	if depth > 10 {
+		return nil
+	}
+
+	var kfile string
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		if path := filepath.Join(dirPath, name); fs.Exists(path) && !fs.IsDir(path) {
+			kfile = path
+			break
+		}
+	}
+	if kfile == "" {
+		return nil
+	}
+
+	data, err := fs.ReadFile(kfile)
+	if err != nil {
+		return err
+	}
+	kus := kustypes.Kustomization{}
+	if err := sigyaml.Unmarshal(data, &kus); err != nil {
+		return err
+	}
+
+	for _, resource := range kus.Resources {
+		if strings.Contains(resource, "://") {
+			continue
+		}
+		path := filepath.Join(dirPath, resource)
+		if !fs.Exists(path) {
+			continue
+		}
+		if fs.IsDir(path) {
+			if err := collectOrigins(fs, rootPath, path, files, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+
+		content, err := fs.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(rootPath, path)
+		if err != nil {
+			return err
+		}
+		reader := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(content), 2048)
+		for {
+			var obj unstructured.Unstructured
+			err := reader.Decode(&obj)
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				return fmt.Errorf("failed to decode Kubernetes YAML from %s: %w", rel, err)
+			}
+			if obj.Object == nil {
+				continue
+			}
+			gvk := obj.GroupVersionKind()
+			files[originKey(gvk.Group, gvk.Kind, obj.GetNamespace(), obj.GetName())] = rel
+		}
+	}
+	return nil
+}
+
+func originKey(group, kind, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", group, kind, namespace, name)
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/api/filesys"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var _ = Describe("resourceOrigins", func() {
+	It("maps the rendered objects to their source files", func() {
+		fs := filesys.MakeFsInMemory()
+		Expect(fs.MkdirAll("/repo/apps/base")).To(Succeed())
+		files := map[string]string{
+			"/repo/apps/kustomization.yaml": `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+namespace: apps
+namePrefix: prod-
+resources:
+- ./base
+- ./service.yaml
+`,
+			"/repo/apps/service.yaml": `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+  - port: 80
+`,
+			"/repo/apps/base/kustomization.yaml": `apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- configmap.yaml
+configMapGenerator:
+- name: generated
+  literals:
+  - key=value
+`,
+			"/repo/apps/base/configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+data:
+  key: value
+`,
+		}
+		for path, content := range files {
+			Expect(fs.WriteFile(path, []byte(content))).To(Succeed())
+		}
+
+		origins, err := resourceOrigins(fs, "/repo", "/repo/apps")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(origins).To(ConsistOf(
+			kustomizev1.ResourceOrigin{ID: "apps_prod-web-config__ConfigMap", Path: filepath.Join("apps", "base", "configmap.yaml")},
+			kustomizev1.ResourceOrigin{ID: "apps_prod-web__Service", Path: filepath.Join("apps", "service.yaml")},
+		))
+	})
+})
@@ -0,0 +1,51 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// pruneOnApplyFailure runs the garbage collection after a failed apply when
+// the Kustomization opts in with PruneOnPartialFailure, and returns true if
+// the stale objects were deleted. Otherwise the garbage collection is skipped,
+// as the objects of the new revision may be missing from the cluster.
+func (r *KustomizationReconciler) pruneOnApplyFailure(ctx context.Context, kubeClient client.Client,
+	kustomization kustomizev1.Kustomization, revision, checksum string) bool {
+	log := logr.FromContext(ctx)
+	if !kustomization.Spec.Prune || kustomization.Status.Snapshot == nil ||
+		kustomization.Status.Snapshot.Checksum == checksum {
+		return false
+	}
+	if kustomization.Spec.PruneOnPartialFailure == nil || !*kustomization.Spec.PruneOnPartialFailure {
+		log.Info("Garbage collection skipped, the apply failed for some objects")
+		return false
+	}
+	if isPruneDeferred(kustomization, revision) {
+		return false
+	}
+	if err := r.prune(ctx, kubeClient, kustomization, checksum); err != nil {
+		log.Error(err, "garbage collection after a partially failed apply failed")
+		return false
+	}
+	return true
+}
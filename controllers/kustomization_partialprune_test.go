@@ -0,0 +1,105 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var _ = Describe("KustomizationReconciler pruneOnApplyFailure", func() {
+	var (
+		namespace     *corev1.Namespace
+		directClient  client.Client
+		kustomization kustomizev1.Kustomization
+		configMapKey  types.NamespacedName
+		r             *KustomizationReconciler
+	)
+
+	BeforeEach(func() {
+		var err error
+		directClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+		Expect(err).NotTo(HaveOccurred())
+
+		namespace = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "partial-prune-" + randStringRunes(5)},
+		}
+		Expect(directClient.Create(context.Background(), namespace)).To(Succeed())
+
+		kustomization = kustomizev1.Kustomization{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: kustomizev1.GroupVersion.String(),
+				Kind:       kustomizev1.KustomizationKind,
+			},
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: namespace.Name},
+			Spec:       kustomizev1.KustomizationSpec{Prune: true},
+		}
+
+		configMapKey = types.NamespacedName{Name: "app-config", Namespace: namespace.Name}
+		Expect(directClient.Create(context.Background(), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      configMapKey.Name,
+				Namespace: configMapKey.Namespace,
+				Labels:    gcLabels(kustomization.Name, kustomization.Namespace, "old"),
+			},
+		})).To(Succeed())
+
+		kustomization.Status.Snapshot, err = kustomizev1.NewSnapshot([]byte(fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: %s
+  namespace: %s
+`, configMapKey.Name, configMapKey.Namespace)), "old")
+		Expect(err).NotTo(HaveOccurred())
+
+		r = &KustomizationReconciler{
+			Scheme:        scheme.Scheme,
+			EventRecorder: record.NewFakeRecorder(10),
+		}
+	})
+
+	AfterEach(func() {
+		Expect(directClient.Delete(context.Background(), namespace)).To(Succeed())
+	})
+
+	It("skips the garbage collection when the apply failed", func() {
+		Expect(r.pruneOnApplyFailure(context.Background(), directClient, kustomization, "main/new", "new")).To(BeFalse())
+		Expect(directClient.Get(context.Background(), configMapKey, &corev1.ConfigMap{})).To(Succeed())
+	})
+
+	It("deletes the stale objects when enabled", func() {
+		enabled := true
+		kustomization.Spec.PruneOnPartialFailure = &enabled
+
+		Expect(r.pruneOnApplyFailure(context.Background(), directClient, kustomization, "main/new", "new")).To(BeTrue())
+		err := directClient.Get(context.Background(), configMapKey, &corev1.ConfigMap{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+})
@@ -0,0 +1,67 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// defaultReconcileOrder applies the objects, garbage collects the stale
+// ones and then assesses the health of the applied objects.
+var defaultReconcileOrder = []string{
+	kustomizev1.ApplyPhase,
+	kustomizev1.PrunePhase,
+	kustomizev1.HealthCheckPhase,
+}
+
+// reconcileOrder returns the phases of the Kustomization in the order they run.
+func reconcileOrder(kustomization kustomizev1.Kustomization) []string {
+	if len(kustomization.Spec.ReconcileOrder) == 0 {
+		return defaultReconcileOrder
+	}
+	return kustomization.Spec.ReconcileOrder
+}
+
+// validateReconcileOrder checks that the order lists each phase once, starting
+// with the apply. The garbage collector deletes the objects that don't carry the
+// checksum of the new revision, pruning before applying would delete all the
+// objects of the Kustomization.
+func validateReconcileOrder(order []string) error {
+	if len(order) == 0 {
+		return nil
+	}
+	if len(order) != len(defaultReconcileOrder) || order[0] != kustomizev1.ApplyPhase {
+		return fmt.Errorf("invalid reconcile order '%s', must be %s followed by %s and %s in any order",
+			strings.Join(order, ", "), kustomizev1.ApplyPhase, kustomizev1.PrunePhase, kustomizev1.HealthCheckPhase)
+	}
+	seen := map[string]bool{}
+	for _, phase := range order {
+		switch phase {
+		case kustomizev1.ApplyPhase, kustomizev1.PrunePhase, kustomizev1.HealthCheckPhase:
+		default:
+			return fmt.Errorf("invalid reconcile order, unknown phase '%s'", phase)
+		}
+		if seen[phase] {
+			return fmt.Errorf("invalid reconcile order, phase '%s' is listed more than once", phase)
+		}
+		seen[phase] = true
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var _ = Describe("reconcileOrder", func() {
+	It("defaults to apply, prune and health check", func() {
+		Expect(reconcileOrder(kustomizev1.Kustomization{})).To(Equal([]string{"Apply", "Prune", "HealthCheck"}))
+	})
+
+	It("gates the garbage collection on the health check", func() {
+		kustomization := kustomizev1.Kustomization{
+			Spec: kustomizev1.KustomizationSpec{
+				ReconcileOrder: []string{"Apply", "HealthCheck", "Prune"},
+			},
+		}
+		Expect(validateReconcileOrder(kustomization.Spec.ReconcileOrder)).To(Succeed())
+		Expect(reconcileOrder(kustomization)).To(Equal([]string{"Apply", "HealthCheck", "Prune"}))
+	})
+
+	DescribeTable("rejects the invalid orders",
+		func(order []string, msg string) {
+			err := validateReconcileOrder(order)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(msg))
+		},
+		Entry("prune before apply", []string{"Prune", "Apply", "HealthCheck"}, "must be Apply followed by"),
+		Entry("missing phase", []string{"Apply", "Prune"}, "must be Apply followed by"),
+		Entry("unknown phase", []string{"Apply", "Prune", "Wait"}, "unknown phase 'Wait'"),
+		Entry("duplicate phase", []string{"Apply", "Prune", "Prune"}, "phase 'Prune' is listed more than once"),
+	)
+})
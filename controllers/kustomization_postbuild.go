@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/resmap"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// removeMetadata deletes the post build annotations and labels from all
+// the resources, the keys of the controller group are left in place.
+func removeMetadata(m resmap.ResMap, postBuild *kustomizev1.PostBuild) {
+	annotations := removableKeys(postBuild.RemoveAnnotations)
+	labels := removableKeys(postBuild.RemoveLabels)
+	for _, res := range m.Resources() {
+		if values := res.GetAnnotations(); deleteKeys(values, annotations) {
+			res.SetAnnotations(values)
+		}
+		if values := res.GetLabels(); deleteKeys(values, labels) {
+			res.SetLabels(values)
+		}
+	}
+}
+
+// deleteKeys removes the keys from the map, it returns true if any key was removed.
+func deleteKeys(values map[string]string, keys []string) bool {
+	deleted := false
+	for _, key := range keys {
+		if _, ok := values[key]; ok {
+			delete(values, key)
+			deleted = true
+		}
+	}
+	return deleted
+}
+
+// removableKeys returns the sorted and deduplicated keys,
+// without the keys of the controller group.
+func removableKeys(keys []string) []string {
+	var result []string
+	for _, key := range keys {
+		if strings.HasPrefix(key, kustomizev1.GroupVersion.Group+"/") || containsString(result, key) {
+			continue
+		}
+		result = append(result, key)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// postBuildOptions returns the post build keys in a canonical form,
+// so that reordering or repeating the keys doesn't change the checksum.
+func postBuildOptions(postBuild *kustomizev1.PostBuild) *kustomizev1.PostBuild {
+	if postBuild == nil {
+		return nil
+	}
+	return &kustomizev1.PostBuild{
+		RemoveAnnotations: removableKeys(postBuild.RemoveAnnotations),
+		RemoveLabels:      removableKeys(postBuild.RemoveLabels),
+	}
+}
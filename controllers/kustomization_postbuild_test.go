@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/api/filesys"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var _ = Describe("removeMetadata", func() {
+	It("removes the annotations and labels from the objects", func() {
+		fs := filesys.MakeFsInMemory()
+		Expect(fs.MkdirAll("/app")).To(Succeed())
+		Expect(fs.WriteFile("/app/configmap.yaml", []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+  annotations:
+    helm.sh/hook: pre-install
+  labels:
+    app: web
+    chart: web-1.0.0
+`))).To(Succeed())
+		Expect(fs.WriteFile("/app/kustomization.yaml", []byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+commonLabels:
+  kustomize.toolkit.fluxcd.io/name: app
+resources:
+- configmap.yaml
+`))).To(Succeed())
+
+		m, err := buildKustomization(fs, "/app")
+		Expect(err).NotTo(HaveOccurred())
+		removeMetadata(m, &kustomizev1.PostBuild{
+			RemoveAnnotations: []string{"helm.sh/hook"},
+			RemoveLabels:      []string{"chart", "kustomize.toolkit.fluxcd.io/name"},
+		})
+
+		res := m.Resources()[0]
+		Expect(res.GetAnnotations()).To(BeEmpty())
+		Expect(res.GetLabels()).To(Equal(map[string]string{
+			"app":                              "web",
+			"kustomize.toolkit.fluxcd.io/name": "app",
+		}))
+	})
+
+	It("ignores the order and duplicates of the keys in the checksum", func() {
+		Expect(postBuildOptions(&kustomizev1.PostBuild{
+			RemoveLabels: []string{"chart", "app", "chart"},
+		})).To(Equal(postBuildOptions(&kustomizev1.PostBuild{
+			RemoveLabels: []string{"app", "chart"},
+		})))
+	})
+})
@@ -0,0 +1,133 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// checkPreconditions evaluates the JSONPath expression of each precondition
+// against the referenced object and returns an error for the first
+// precondition that does not match the expected value.
+// The objects are read with the given reader, which must be uncached and
+// have the identity the Kustomization is applied with. The referenced objects
+// must be in the Kustomization namespace, and the value read from the object
+// is never part of the returned error, as it ends up in the status and events.
+func checkPreconditions(ctx context.Context, reader client.Reader, kustomization kustomizev1.Kustomization) error {
+	for _, p := range kustomization.Spec.Preconditions {
+		ref := p.ObjectRef
+		if ref.Namespace != "" && ref.Namespace != kustomization.GetNamespace() {
+			return fmt.Errorf("precondition %s '%s/%s' is not allowed, the object must be in the '%s' namespace",
+				ref.Kind, ref.Namespace, ref.Name, kustomization.GetNamespace())
+		}
+
+		jp := jsonpath.New(ref.Name)
+		if err := jp.Parse(p.JSONPath); err != nil {
+			return fmt.Errorf("invalid precondition JSONPath '%s': %w", p.JSONPath, err)
+		}
+
+		objName := types.NamespacedName{Namespace: kustomization.GetNamespace(), Name: ref.Name}
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(preconditionGroupVersionKind(p))
+		if err := reader.Get(ctx, objName, obj); err != nil {
+			return fmt.Errorf("unable to get precondition %s '%s': %w", ref.Kind, objName, err)
+		}
+
+		buf := new(bytes.Buffer)
+		if err := jp.Execute(buf, obj.Object); err != nil {
+			return fmt.Errorf("precondition %s '%s' not met: %w", ref.Kind, objName, err)
+		}
+
+		if buf.String() != p.Value {
+			return fmt.Errorf("precondition %s '%s' not met: %s does not match the expected value",
+				ref.Kind, objName, p.JSONPath)
+		}
+	}
+
+	return nil
+}
+
+// preconditionWatches tracks the kinds watched for preconditions changes,
+// the number of kinds is bounded by max.
+type preconditionWatches struct {
+	mu    sync.Mutex
+	max   int
+	kinds map[schema.GroupKind]bool
+}
+
+// watchPreconditions starts a watch for the kinds of the watched preconditions,
+// unless already watched or the maximum number of watched kinds is reached.
+// Failing to start a watch is logged, the preconditions are then evaluated at
+// the retry interval.
+func (r *KustomizationReconciler) watchPreconditions(ctx context.Context, kustomization kustomizev1.Kustomization) {
+	w := &r.preconditionWatches
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.kinds == nil {
+		w.kinds = make(map[schema.GroupKind]bool)
+	}
+
+	for _, p := range kustomization.Spec.Preconditions {
+		if !p.Watch {
+			continue
+		}
+
+		gvk := preconditionGroupVersionKind(p)
+		if w.kinds[gvk.GroupKind()] {
+			continue
+		}
+		if len(w.kinds) >= w.max {
+			(logr.FromContext(ctx)).V(1).Info(fmt.Sprintf("not watching %s, the maximum of %d watched precondition kinds is reached",
+				gvk.GroupKind().String(), w.max))
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		if err := r.controller.Watch(&source.Kind{Type: obj},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForPreconditionChange)); err != nil {
+			(logr.FromContext(ctx)).Error(err, fmt.Sprintf("unable to watch %s for preconditions", gvk.GroupKind().String()))
+			continue
+		}
+		w.kinds[gvk.GroupKind()] = true
+	}
+}
+
+// preconditionGroupVersionKind returns the kind of the object referenced by
+// the precondition, defaulting to the core API group.
+func preconditionGroupVersionKind(p kustomizev1.Precondition) schema.GroupVersionKind {
+	apiVersion := p.ObjectRef.APIVersion
+	if apiVersion == "" {
+		apiVersion = "v1"
+	}
+	return schema.FromAPIVersionAndKind(apiVersion, p.ObjectRef.Kind)
+}
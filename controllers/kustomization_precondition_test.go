@@ -0,0 +1,156 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var _ = Describe("KustomizationReconciler preconditions", func() {
+	var (
+		namespace    *corev1.Namespace
+		directClient client.Client
+	)
+
+	BeforeEach(func() {
+		var err error
+		directClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+		Expect(err).NotTo(HaveOccurred())
+
+		namespace = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "precondition-" + randStringRunes(5)},
+		}
+		Expect(directClient.Create(context.Background(), namespace)).To(Succeed())
+
+		Expect(directClient.Create(context.Background(), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "feature-flags", Namespace: namespace.Name},
+			Data:       map[string]string{"enabled": "true", "secret": "s3cr3t"},
+		})).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(directClient.Delete(context.Background(), namespace)).To(Succeed())
+	})
+
+	check := func(preconditions ...kustomizev1.Precondition) error {
+		kustomization := kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{Name: "gated", Namespace: namespace.Name},
+			Spec:       kustomizev1.KustomizationSpec{Preconditions: preconditions},
+		}
+		return checkPreconditions(context.Background(), directClient, kustomization)
+	}
+
+	flags := func(jsonPath, value string) kustomizev1.Precondition {
+		return kustomizev1.Precondition{
+			ObjectRef: meta.NamespacedObjectKindReference{Kind: "ConfigMap", Name: "feature-flags"},
+			JSONPath:  jsonPath,
+			Value:     value,
+		}
+	}
+
+	It("succeeds when the preconditions are met", func() {
+		Expect(check(flags("{.data.enabled}", "true"))).To(Succeed())
+	})
+
+	It("fails without the object value when a precondition is not met", func() {
+		err := check(flags("{.data.enabled}", "true"), flags("{.data.secret}", "other"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("{.data.secret} does not match the expected value"))
+		Expect(err.Error()).NotTo(ContainSubstring("s3cr3t"))
+	})
+
+	It("fails when the object does not exist", func() {
+		p := flags("{.data.enabled}", "true")
+		p.ObjectRef.Name = "missing"
+		err := check(p)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("unable to get precondition ConfigMap"))
+	})
+
+	It("fails when the JSONPath is invalid", func() {
+		err := check(flags("{.data.enabled", "true"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("invalid precondition JSONPath"))
+	})
+
+	It("fails when the JSONPath does not match a field", func() {
+		err := check(flags("{.data.missing}", "true"))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("not met"))
+	})
+
+	It("rejects objects of another namespace", func() {
+		p := flags("{.data.enabled}", "true")
+		p.ObjectRef.Namespace = "kube-system"
+		err := check(p)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("must be in the '" + namespace.Name + "' namespace"))
+	})
+})
+
+var _ = Describe("KustomizationReconciler precondition watches", func() {
+	precondition := func(apiVersion, kind, name string, watch bool) kustomizev1.Precondition {
+		return kustomizev1.Precondition{
+			ObjectRef: meta.NamespacedObjectKindReference{APIVersion: apiVersion, Kind: kind, Name: name},
+			JSONPath:  "{.metadata.name}",
+			Value:     name,
+			Watch:     watch,
+		}
+	}
+
+	It("indexes the objects of the watched preconditions in the Kustomization namespace", func() {
+		k := &kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{Name: "gated", Namespace: "apps"},
+			Spec: kustomizev1.KustomizationSpec{Preconditions: []kustomizev1.Precondition{
+				precondition("", "ConfigMap", "feature-flags", true),
+				precondition("apps/v1", "Deployment", "database", true),
+				precondition("", "Secret", "credentials", false),
+			}},
+		}
+		Expect((&KustomizationReconciler{}).indexByPrecondition(k)).To(Equal([]string{
+			"ConfigMap/apps/feature-flags",
+			"Deployment.apps/apps/database",
+		}))
+	})
+
+	It("skips the watched kinds and the kinds over the maximum", func() {
+		r := &KustomizationReconciler{}
+		r.preconditionWatches = preconditionWatches{
+			max:   1,
+			kinds: map[schema.GroupKind]bool{{Kind: "ConfigMap"}: true},
+		}
+		// no watch is started, the reconciler has no controller
+		r.watchPreconditions(context.Background(), kustomizev1.Kustomization{
+			Spec: kustomizev1.KustomizationSpec{Preconditions: []kustomizev1.Precondition{
+				precondition("", "ConfigMap", "feature-flags", true),
+				precondition("apps/v1", "Deployment", "database", true),
+			}},
+		})
+		Expect(r.preconditionWatches.kinds).To(Equal(map[schema.GroupKind]bool{{Kind: "ConfigMap"}: true}))
+	})
+})
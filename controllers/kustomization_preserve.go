@@ -0,0 +1,117 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// mergePreservedAnnotations rewrites the manifests file with the
+// PreserveAnnotations values of the live objects.
+func (r *KustomizationReconciler) mergePreservedAnnotations(ctx context.Context, kubeClient client.Client,
+	kustomization kustomizev1.Kustomization, dirPath string) error {
+	if len(kustomization.Spec.PreserveAnnotations) == 0 {
+		return nil
+	}
+
+	manifestsFile := filepath.Join(dirPath, fmt.Sprintf("%s.yaml", kustomization.GetUID()))
+	manifests, err := ioutil.ReadFile(manifestsFile)
+	if err != nil {
+		return err
+	}
+
+	merged, count, err := preserveAnnotations(ctx, kubeClient, manifests, kustomization.Spec.PreserveAnnotations)
+	if err != nil {
+		return fmt.Errorf("preserving annotations failed: %w", err)
+	}
+	if count == 0 {
+		return nil
+	}
+
+	(logr.FromContext(ctx)).V(1).Info(fmt.Sprintf("Preserved the annotations of %d objects", count))
+	return ioutil.WriteFile(manifestsFile, merged, os.ModePerm)
+}
+
+// preserveAnnotations sets the given annotation keys present on the live objects
+// to their live values in the multi-doc YAML, and returns the updated manifests
+// and the number of objects changed. Objects absent from the cluster are left as is.
+func preserveAnnotations(ctx context.Context, kubeClient client.Client, manifests []byte, keys []string) ([]byte, int, error) {
+	objects, err := parseManifests(manifests)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var docs []manifest
+	count := 0
+	for _, m := range objects {
+		obj := m.object
+		if obj.Object == nil {
+			docs = append(docs, m)
+			continue
+		}
+
+		live := &unstructured.Unstructured{}
+		live.SetGroupVersionKind(obj.GroupVersionKind())
+		err := kubeClient.Get(ctx, types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()}, live)
+		if apierrors.IsNotFound(err) {
+			docs = append(docs, m)
+			continue
+		} else if err != nil {
+			return nil, 0, fmt.Errorf("unable to get %s: %w", objectName(obj), err)
+		}
+
+		annotations := obj.GetAnnotations()
+		changed := false
+		for _, key := range keys {
+			value, ok := live.GetAnnotations()[key]
+			if !ok || annotations[key] == value {
+				continue
+			}
+			if annotations == nil {
+				annotations = make(map[string]string)
+			}
+			annotations[key] = value
+			changed = true
+		}
+		if !changed {
+			docs = append(docs, m)
+			continue
+		}
+
+		obj.SetAnnotations(annotations)
+		out, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return nil, 0, err
+		}
+		docs = append(docs, manifest{doc: out, object: obj})
+		count++
+	}
+	return joinManifests(docs), count, nil
+}
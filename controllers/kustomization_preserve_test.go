@@ -0,0 +1,99 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+var _ = Describe("preserveAnnotations", func() {
+	var (
+		namespace    *corev1.Namespace
+		directClient client.Client
+	)
+
+	BeforeEach(func() {
+		var err error
+		directClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+		Expect(err).NotTo(HaveOccurred())
+
+		namespace = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "preserve-" + randStringRunes(5)},
+		}
+		Expect(directClient.Create(context.Background(), namespace)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(directClient.Delete(context.Background(), namespace)).To(Succeed())
+	})
+
+	It("keeps the preserved annotations of the live objects on apply", func() {
+		Expect(directClient.Create(context.Background(), &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "app-config",
+				Namespace: namespace.Name,
+				Annotations: map[string]string{
+					"example.com/owner":   "other-controller",
+					"example.com/ignored": "live",
+				},
+			},
+		})).To(Succeed())
+
+		manifests := fmt.Sprintf(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+  namespace: %[1]s
+  annotations:
+    example.com/owner: kustomization
+data:
+  key: value
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: new-config
+  namespace: %[1]s
+`, namespace.Name)
+
+		out, count, err := preserveAnnotations(context.Background(), directClient, []byte(manifests),
+			[]string{"example.com/owner"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(count).To(Equal(1))
+
+		obj := &unstructured.Unstructured{}
+		Expect(yaml.Unmarshal(bytes.Split(out, []byte("\n---\n"))[0], &obj.Object)).To(Succeed())
+		Expect(obj.GetAnnotations()).To(Equal(map[string]string{"example.com/owner": "other-controller"}))
+
+		Expect(directClient.Update(context.Background(), obj)).To(Succeed())
+		live := &corev1.ConfigMap{}
+		Expect(directClient.Get(context.Background(), client.ObjectKeyFromObject(obj), live)).To(Succeed())
+		Expect(live.Annotations).To(HaveKeyWithValue("example.com/owner", "other-controller"))
+		Expect(live.Data).To(HaveKeyWithValue("key", "value"))
+	})
+})
@@ -0,0 +1,107 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/cli-utils/pkg/object"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// quotaWildcard matches any namespace or kind in an ObjectQuota.
+const quotaWildcard = "*"
+
+// ObjectQuota limits the number of objects of a kind rendered by each
+// Kustomization of a namespace.
+type ObjectQuota struct {
+	// Namespace of the Kustomizations the quota applies to, '*' for all namespaces.
+	Namespace string
+
+	// Kind of the objects counted against the quota, '*' for all kinds.
+	Kind string
+
+	// Max is the maximum number of objects.
+	Max int
+}
+
+// String returns the quota in the '<namespace>/<kind>=<max>' format.
+func (q ObjectQuota) String() string {
+	return fmt.Sprintf("%s/%s=%d", q.Namespace, q.Kind, q.Max)
+}
+
+// ParseObjectQuotas parses the quotas in the '<namespace>/<kind>=<max>' format.
+func ParseObjectQuotas(values []string) ([]ObjectQuota, error) {
+	var quotas []ObjectQuota
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid object quota '%s', expected '<namespace>/<kind>=<max>'", value)
+		}
+		scope := strings.SplitN(parts[0], "/", 2)
+		if len(scope) != 2 || scope[0] == "" || scope[1] == "" {
+			return nil, fmt.Errorf("invalid object quota '%s', expected '<namespace>/<kind>=<max>'", value)
+		}
+		max, err := strconv.Atoi(parts[1])
+		if err != nil || max < 0 {
+			return nil, fmt.Errorf("invalid object quota '%s', the maximum must be a positive integer", value)
+		}
+		quotas = append(quotas, ObjectQuota{Namespace: scope[0], Kind: scope[1], Max: max})
+	}
+	return quotas, nil
+}
+
+// checkObjectQuotas returns an error listing the object counts of the
+// inventory that exceed the quotas of the Kustomization namespace.
+func checkObjectQuotas(quotas []ObjectQuota, kustomization kustomizev1.Kustomization,
+	inventory *kustomizev1.ResourceInventory) error {
+	if len(quotas) == 0 || inventory == nil {
+		return nil
+	}
+
+	counts := make(map[string]int)
+	for _, entry := range inventory.Entries {
+		objMeta, err := object.ParseObjMetadata(entry.ID)
+		if err != nil {
+			return fmt.Errorf("unable to parse inventory entry '%s': %w", entry.ID, err)
+		}
+		counts[objMeta.GroupKind.Kind]++
+	}
+
+	var exceeded []string
+	for _, quota := range quotas {
+		if quota.Namespace != quotaWildcard && quota.Namespace != kustomization.GetNamespace() {
+			continue
+		}
+		if quota.Kind == quotaWildcard {
+			if count := len(inventory.Entries); count > quota.Max {
+				exceeded = append(exceeded, fmt.Sprintf("%d objects (max %d)", count, quota.Max))
+			}
+			continue
+		}
+		if count := counts[quota.Kind]; count > quota.Max {
+			exceeded = append(exceeded, fmt.Sprintf("%d %s objects (max %d)", count, quota.Kind, quota.Max))
+		}
+	}
+	if len(exceeded) > 0 {
+		return fmt.Errorf("object quota exceeded: %s", strings.Join(exceeded, ", "))
+	}
+	return nil
+}
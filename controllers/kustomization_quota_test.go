@@ -0,0 +1,85 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var _ = Describe("ParseObjectQuotas", func() {
+	It("parses the namespace, kind and maximum", func() {
+		quotas, err := ParseObjectQuotas([]string{"tenant-a/*=100", "*/ClusterRole=0"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(quotas).To(Equal([]ObjectQuota{
+			{Namespace: "tenant-a", Kind: "*", Max: 100},
+			{Namespace: "*", Kind: "ClusterRole", Max: 0},
+		}))
+	})
+
+	DescribeTable("rejects invalid quotas",
+		func(value string) {
+			_, err := ParseObjectQuotas([]string{value})
+			Expect(err).To(HaveOccurred())
+		},
+		Entry("without maximum", "tenant-a/*"),
+		Entry("without kind", "tenant-a=10"),
+		Entry("with empty namespace", "/Secret=10"),
+		Entry("with negative maximum", "tenant-a/*=-1"),
+		Entry("with non numeric maximum", "tenant-a/*=ten"),
+	)
+})
+
+var _ = Describe("checkObjectQuotas", func() {
+	kustomization := kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "tenant-a"},
+	}
+	inventory := &kustomizev1.ResourceInventory{
+		Entries: []kustomizev1.ResourceRef{
+			{ID: "tenant-a_app__ConfigMap", Version: "v1"},
+			{ID: "tenant-a_web__ConfigMap", Version: "v1"},
+			{ID: "tenant-a_app_apps_Deployment", Version: "v1"},
+			{ID: "_app_rbac.authorization.k8s.io_ClusterRole", Version: "v1"},
+		},
+	}
+
+	DescribeTable("enforces the quotas of the namespace",
+		func(quotas []ObjectQuota, expectedErr string) {
+			err := checkObjectQuotas(quotas, kustomization, inventory)
+			if expectedErr == "" {
+				Expect(err).NotTo(HaveOccurred())
+				return
+			}
+			Expect(err).To(MatchError(expectedErr))
+		},
+		Entry("without quotas", nil, ""),
+		Entry("within the quotas",
+			[]ObjectQuota{{Namespace: "tenant-a", Kind: "*", Max: 4}, {Namespace: "*", Kind: "ConfigMap", Max: 2}}, ""),
+		Entry("with the quotas of other namespaces",
+			[]ObjectQuota{{Namespace: "tenant-b", Kind: "*", Max: 0}}, ""),
+		Entry("over the total quota",
+			[]ObjectQuota{{Namespace: "tenant-a", Kind: "*", Max: 3}},
+			"object quota exceeded: 4 objects (max 3)"),
+		Entry("over the kind quotas",
+			[]ObjectQuota{{Namespace: "*", Kind: "ClusterRole", Max: 0}, {Namespace: "tenant-a", Kind: "ConfigMap", Max: 1}},
+			"object quota exceeded: 1 ClusterRole objects (max 0), 2 ConfigMap objects (max 1)"),
+	)
+})
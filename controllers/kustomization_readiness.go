@@ -0,0 +1,185 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/yaml"
+)
+
+// readinessRulesKey is the key of the ConfigMap data holding the readiness rules.
+const readinessRulesKey = "rules.yaml"
+
+// readinessRule overrides the kstatus readiness of the objects of a kind, the
+// objects are ready when they have the condition, or when the JSONPath
+// expression evaluates to the value.
+type readinessRule struct {
+	// APIVersion of the objects, only the group is matched.
+	APIVersion string `json:"apiVersion"`
+
+	// Kind of the objects.
+	Kind string `json:"kind"`
+
+	// Condition the objects must have to be ready.
+	Condition *readinessCondition `json:"condition,omitempty"`
+
+	// JSONPath is the expression evaluated on the objects e.g. '{.status.phase}'.
+	JSONPath string `json:"jsonPath,omitempty"`
+
+	// Value the JSONPath expression must evaluate to.
+	Value string `json:"value,omitempty"`
+}
+
+// readinessCondition is the condition type and status of a readiness rule.
+type readinessCondition struct {
+	Type string `json:"type"`
+
+	// Status defaults to 'True'.
+	Status string `json:"status,omitempty"`
+}
+
+// parseReadinessRules decodes and validates the readiness rules.
+func parseReadinessRules(data []byte) ([]readinessRule, error) {
+	var rules []readinessRule
+	if err := yaml.UnmarshalStrict(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to decode the readiness rules: %w", err)
+	}
+	for i, rule := range rules {
+		if rule.Kind == "" {
+			return nil, fmt.Errorf("readiness rule %d has no kind", i)
+		}
+		if _, err := schema.ParseGroupVersion(rule.APIVersion); err != nil {
+			return nil, fmt.Errorf("readiness rule for %s has an invalid apiVersion: %w", rule.Kind, err)
+		}
+		if (rule.Condition == nil) == (rule.JSONPath == "") {
+			return nil, fmt.Errorf("readiness rule for %s must set either a condition or a jsonPath", rule.Kind)
+		}
+		if rule.Condition != nil && rule.Condition.Type == "" {
+			return nil, fmt.Errorf("readiness rule for %s has no condition type", rule.Kind)
+		}
+		if rule.JSONPath != "" {
+			if err := jsonpath.New(rule.Kind).Parse(rule.JSONPath); err != nil {
+				return nil, fmt.Errorf("readiness rule for %s has an invalid jsonPath: %w", rule.Kind, err)
+			}
+		}
+	}
+	return rules, nil
+}
+
+// readinessRules returns the rules of the readiness rules ConfigMap, if any.
+// The ConfigMap is read at every health check, so that the rules can be
+// changed without restarting the controller.
+func (r *KustomizationReconciler) readinessRules(ctx context.Context) ([]readinessRule, error) {
+	if r.readinessRulesRef.Name == "" {
+		return nil, nil
+	}
+	var cm corev1.ConfigMap
+	if err := r.apiReader.Get(ctx, r.readinessRulesRef, &cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unable to read the readiness rules: %w", err)
+	}
+	return parseReadinessRules([]byte(cm.Data[readinessRulesKey]))
+}
+
+// parseReadinessRulesRef parses the '<namespace>/<name>' reference of the
+// readiness rules ConfigMap, the namespace defaults to defaultNamespace.
+func parseReadinessRulesRef(ref, defaultNamespace string) types.NamespacedName {
+	if ref == "" {
+		return types.NamespacedName{}
+	}
+	if parts := strings.SplitN(ref, "/", 2); len(parts) == 2 {
+		return types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+	}
+	return types.NamespacedName{Namespace: defaultNamespace, Name: ref}
+}
+
+// findReadinessRule returns the first rule matching the group and kind.
+func findReadinessRule(rules []readinessRule, gk schema.GroupKind) *readinessRule {
+	for i, rule := range rules {
+		gv, err := schema.ParseGroupVersion(rule.APIVersion)
+		if err == nil && gv.Group == gk.Group && rule.Kind == gk.Kind {
+			return &rules[i]
+		}
+	}
+	return nil
+}
+
+// isReady evaluates the rule on the object.
+func (rule readinessRule) isReady(obj *unstructured.Unstructured) (bool, error) {
+	if rule.Condition != nil {
+		expected := rule.Condition.Status
+		if expected == "" {
+			expected = "True"
+		}
+		conditions, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+		if err != nil {
+			return false, nil
+		}
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if ok && condition["type"] == rule.Condition.Type {
+				return condition["status"] == expected, nil
+			}
+		}
+		return false, nil
+	}
+
+	jp := jsonpath.New(rule.Kind).AllowMissingKeys(true)
+	if err := jp.Parse(rule.JSONPath); err != nil {
+		return false, err
+	}
+	var buf bytes.Buffer
+	if err := jp.Execute(&buf, obj.Object); err != nil {
+		return false, err
+	}
+	return buf.String() == rule.Value, nil
+}
+
+// applyReadinessRule returns the resource status with the status of the
+// readiness rule of its kind, or the kstatus status when there is no rule.
+func applyReadinessRule(rules []readinessRule, rs *event.ResourceStatus) *event.ResourceStatus {
+	rule := findReadinessRule(rules, rs.Identifier.GroupKind)
+	if rule == nil || rs.Resource == nil {
+		return rs
+	}
+	override := *rs
+	ready, err := rule.isReady(rs.Resource)
+	switch {
+	case err != nil:
+		override.Status = status.UnknownStatus
+		override.Message = err.Error()
+	case ready:
+		override.Status = status.CurrentStatus
+	default:
+		override.Status = status.InProgressStatus
+	}
+	return &override
+}
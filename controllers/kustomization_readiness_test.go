@@ -0,0 +1,124 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/polling/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+var _ = Describe("readiness rules", func() {
+	rules, err := parseReadinessRules([]byte(`
+- apiVersion: db.example.com/v1
+  kind: Database
+  condition:
+    type: Available
+- apiVersion: cache.example.com/v1alpha1
+  kind: Cache
+  jsonPath: '{.status.phase}'
+  value: Running
+`))
+
+	database := func(conditionStatus string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "db.example.com/v1",
+			"kind":       "Database",
+			"metadata":   map[string]interface{}{"name": "orders", "namespace": "apps"},
+			"status": map[string]interface{}{
+				"conditions": []interface{}{
+					map[string]interface{}{"type": "Ready", "status": "False"},
+					map[string]interface{}{"type": "Available", "status": conditionStatus},
+				},
+			},
+		}}
+	}
+
+	cache := func(phase string) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "cache.example.com/v1alpha1",
+			"kind":       "Cache",
+			"metadata":   map[string]interface{}{"name": "sessions", "namespace": "apps"},
+			"status":     map[string]interface{}{"phase": phase},
+		}}
+	}
+
+	resourceStatus := func(obj *unstructured.Unstructured) *event.ResourceStatus {
+		return &event.ResourceStatus{
+			Identifier: object.ObjMetadata{
+				GroupKind: obj.GroupVersionKind().GroupKind(),
+				Namespace: obj.GetNamespace(),
+				Name:      obj.GetName(),
+			},
+			Status:   status.CurrentStatus,
+			Resource: obj,
+		}
+	}
+
+	It("parses the rules", func() {
+		Expect(err).NotTo(HaveOccurred())
+		Expect(rules).To(HaveLen(2))
+		Expect(findReadinessRule(rules, schema.GroupKind{Group: "db.example.com", Kind: "Database"})).To(Equal(&rules[0]))
+		Expect(findReadinessRule(rules, schema.GroupKind{Group: "apps", Kind: "Deployment"})).To(BeNil())
+	})
+
+	DescribeTable("overrides the kstatus readiness of the custom resources",
+		func(obj *unstructured.Unstructured, expected status.Status) {
+			Expect(applyReadinessRule(rules, resourceStatus(obj)).Status).To(Equal(expected))
+		},
+		Entry("with the condition", database("True"), status.CurrentStatus),
+		Entry("without the condition status", database("False"), status.InProgressStatus),
+		Entry("with the jsonPath value", cache("Running"), status.CurrentStatus),
+		Entry("without the jsonPath value", cache("Pending"), status.InProgressStatus),
+	)
+
+	It("keeps the kstatus readiness of the kinds without rule", func() {
+		obj := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]interface{}{"name": "web", "namespace": "apps"},
+		}}
+		rs := resourceStatus(obj)
+		Expect(applyReadinessRule(rules, rs)).To(BeIdenticalTo(rs))
+	})
+
+	DescribeTable("rejects invalid rules",
+		func(data string, expectedErr string) {
+			_, err := parseReadinessRules([]byte(data))
+			Expect(err).To(MatchError(ContainSubstring(expectedErr)))
+		},
+		Entry("without kind", "- apiVersion: v1\n  jsonPath: '{.status}'\n", "has no kind"),
+		Entry("without check", "- apiVersion: v1\n  kind: Pod\n", "must set either a condition or a jsonPath"),
+		Entry("with both checks", "- apiVersion: v1\n  kind: Pod\n  jsonPath: '{.status}'\n  condition:\n    type: Ready\n",
+			"must set either a condition or a jsonPath"),
+		Entry("with invalid jsonPath", "- apiVersion: v1\n  kind: Pod\n  jsonPath: '{.status'\n", "invalid jsonPath"),
+	)
+
+	It("parses the ConfigMap reference", func() {
+		Expect(parseReadinessRulesRef("", "flux-system")).To(Equal(types.NamespacedName{}))
+		Expect(parseReadinessRulesRef("readiness", "flux-system")).To(Equal(
+			types.NamespacedName{Namespace: "flux-system", Name: "readiness"}))
+		Expect(parseReadinessRulesRef("infra/readiness", "flux-system")).To(Equal(
+			types.NamespacedName{Namespace: "infra", Name: "readiness"}))
+	})
+})
@@ -0,0 +1,115 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/resmap"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// rewriteImageRegistries replaces the registry prefix of the container
+// images of all the resources that match one of the rewrites.
+func rewriteImageRegistries(m resmap.ResMap, rewrites []kustomizev1.ImageRegistryRewrite) error {
+	for _, res := range m.Resources() {
+		data, err := res.MarshalJSON()
+		if err != nil {
+			return err
+		}
+
+		var obj map[string]interface{}
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+
+		if !rewriteContainerImages(obj, rewrites) {
+			continue
+		}
+
+		data, err = json.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		if err := res.UnmarshalJSON(data); err != nil {
+			return fmt.Errorf("image registry rewrite failed for '%s': %w", res.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// rewriteContainerImages walks the object looking for container lists
+// and rewrites their images, it returns true if any image was changed.
+func rewriteContainerImages(obj interface{}, rewrites []kustomizev1.ImageRegistryRewrite) bool {
+	changed := false
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		for key, value := range o {
+			if containers, ok := value.([]interface{}); ok &&
+				containsString([]string{"containers", "initContainers", "ephemeralContainers"}, key) {
+				for _, c := range containers {
+					container, ok := c.(map[string]interface{})
+					if !ok {
+						continue
+					}
+					if image, ok := container["image"].(string); ok {
+						if newImage := rewriteImage(image, rewrites); newImage != image {
+							container["image"] = newImage
+							changed = true
+						}
+					}
+				}
+				continue
+			}
+			if rewriteContainerImages(value, rewrites) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, item := range o {
+			if rewriteContainerImages(item, rewrites) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// rewriteImage replaces the prefix of the image with the first matching rewrite.
+// Images without a registry are expanded to their Docker Hub name before matching.
+func rewriteImage(image string, rewrites []kustomizev1.ImageRegistryRewrite) string {
+	fullImage := image
+	if parts := strings.SplitN(image, "/", 2); len(parts) == 1 {
+		fullImage = "docker.io/library/" + image
+	} else if !strings.ContainsAny(parts[0], ".:") && parts[0] != "localhost" {
+		fullImage = "docker.io/" + image
+	}
+
+	for _, rewrite := range rewrites {
+		from := strings.TrimSuffix(rewrite.From, "/")
+		to := strings.TrimSuffix(rewrite.To, "/")
+		for _, candidate := range []string{image, fullImage} {
+			if strings.HasPrefix(candidate, from+"/") {
+				return to + strings.TrimPrefix(candidate, from)
+			}
+		}
+	}
+	return image
+}
@@ -0,0 +1,44 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var _ = Describe("rewriteImage", func() {
+	rewrites := []kustomizev1.ImageRegistryRewrite{
+		{From: "docker.io", To: "registry.internal"},
+		{From: "ghcr.io/fluxcd", To: "registry.internal/fluxcd-mirror"},
+	}
+
+	DescribeTable("rewrites the image registry prefix",
+		func(image, expected string) {
+			Expect(rewriteImage(image, rewrites)).To(Equal(expected))
+		},
+		Entry("docker hub official image", "nginx:1.19", "registry.internal/library/nginx:1.19"),
+		Entry("docker hub image", "stefanprodan/podinfo:5.0.0", "registry.internal/stefanprodan/podinfo:5.0.0"),
+		Entry("explicit docker hub image", "docker.io/stefanprodan/podinfo:5.0.0", "registry.internal/stefanprodan/podinfo:5.0.0"),
+		Entry("repository prefix", "ghcr.io/fluxcd/kustomize-controller:v0.7.0", "registry.internal/fluxcd-mirror/kustomize-controller:v0.7.0"),
+		Entry("no match", "ghcr.io/stefanprodan/podinfo:5.0.0", "ghcr.io/stefanprodan/podinfo:5.0.0"),
+		Entry("partial domain", "docker.iox/app:1.0", "docker.iox/app:1.0"),
+	)
+})
@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// serverApplyDryRun submits each object of the manifests with a server-side
+// apply dry-run, so that the defaulting and the admission webhooks run without
+// persisting the objects, and returns an error for the first rejected object.
+// The objects of kinds unknown to the cluster, e.g. the custom resources of
+// the CRDs of the manifests, are skipped.
+func serverApplyDryRun(ctx context.Context, kubeClient client.Client, manifests []byte) error {
+	reader := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifests), 2048)
+	for {
+		var obj unstructured.Unstructured
+		err := reader.Decode(&obj)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if obj.Object == nil {
+			continue
+		}
+
+		gvk := obj.GroupVersionKind()
+		mapping, err := kubeClient.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+		if apimeta.IsNoMatchError(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+		// kubectl applies the namespaced objects without namespace into the default namespace
+		if mapping.Scope.Name() == apimeta.RESTScopeNameNamespace && obj.GetNamespace() == "" {
+			obj.SetNamespace("default")
+		}
+
+		if err := kubeClient.Patch(ctx, &obj, client.Apply, client.DryRunAll,
+			client.FieldOwner("kustomize-controller"), client.ForceOwnership); err != nil {
+			return fmt.Errorf("%s/%s/%s rejected: %w", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+		}
+	}
+	return nil
+}
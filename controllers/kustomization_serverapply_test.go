@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("serverApplyDryRun", func() {
+	var (
+		namespace    *corev1.Namespace
+		directClient client.Client
+	)
+
+	BeforeEach(func() {
+		var err error
+		directClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+		Expect(err).NotTo(HaveOccurred())
+
+		namespace = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "server-apply-" + randStringRunes(5)},
+		}
+		Expect(directClient.Create(context.Background(), namespace)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		Expect(directClient.Delete(context.Background(), namespace)).To(Succeed())
+	})
+
+	It("validates the objects without persisting them", func() {
+		manifests := fmt.Sprintf(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+  namespace: %[1]s
+data:
+  key: value
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: app
+  namespace: %[1]s
+`, namespace.Name)
+
+		Expect(serverApplyDryRun(context.Background(), directClient, []byte(manifests))).To(Succeed())
+
+		err := directClient.Get(context.Background(),
+			types.NamespacedName{Namespace: namespace.Name, Name: "app-config"}, &corev1.ConfigMap{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("reports the first rejected object", func() {
+		manifests := fmt.Sprintf(`---
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+  namespace: %[1]s
+spec:
+  ports:
+  - port: 0
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: %[1]s
+spec:
+  ports:
+  - port: 0
+`, namespace.Name)
+
+		err := serverApplyDryRun(context.Background(), directClient, []byte(manifests))
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(HavePrefix(fmt.Sprintf("Service/%s/app rejected: ", namespace.Name)))
+		Expect(err.Error()).To(ContainSubstring("spec.ports[0].port"))
+	})
+})
@@ -0,0 +1,45 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta1"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// sourceSettleWait returns the time left before the artifact revision is
+// settled, or zero if it can be built. A revision is settled when the artifact
+// hasn't been updated for the SourceSettlePeriod, each new revision restarting
+// the period. The revisions already attempted and the pinned revisions are
+// not delayed.
+func sourceSettleWait(kustomization kustomizev1.Kustomization, artifact *sourcev1.Artifact, now time.Time) time.Duration {
+	settle := kustomization.Spec.SourceSettlePeriod
+	if settle == nil || settle.Duration <= 0 || kustomization.Spec.SourceRevision != "" {
+		return 0
+	}
+	if artifact == nil || artifact.LastUpdateTime.IsZero() ||
+		artifact.Revision == kustomization.Status.LastAttemptedRevision {
+		return 0
+	}
+	if wait := artifact.LastUpdateTime.Add(settle.Duration).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
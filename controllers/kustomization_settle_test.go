@@ -0,0 +1,55 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"time"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var _ = Describe("sourceSettleWait", func() {
+	now := time.Now()
+	artifact := &sourcev1.Artifact{
+		Revision:       "main/c3ab8ff",
+		LastUpdateTime: metav1.NewTime(now.Add(-20 * time.Second)),
+	}
+
+	DescribeTable("delays the new revisions for the settle period",
+		func(settle *metav1.Duration, sourceRevision, lastAttempted string, expected time.Duration) {
+			kustomization := kustomizev1.Kustomization{
+				Spec: kustomizev1.KustomizationSpec{
+					SourceSettlePeriod: settle,
+					SourceRevision:     sourceRevision,
+				},
+				Status: kustomizev1.KustomizationStatus{LastAttemptedRevision: lastAttempted},
+			}
+			Expect(sourceSettleWait(kustomization, artifact, now)).To(Equal(expected))
+		},
+		Entry("without settle period", nil, "", "main/a1b2c3d", time.Duration(0)),
+		Entry("with a new revision", &metav1.Duration{Duration: time.Minute}, "", "main/a1b2c3d", 40*time.Second),
+		Entry("with a settled revision", &metav1.Duration{Duration: 10 * time.Second}, "", "main/a1b2c3d", time.Duration(0)),
+		Entry("with the attempted revision", &metav1.Duration{Duration: time.Minute}, "", "main/c3ab8ff", time.Duration(0)),
+		Entry("with a pinned revision", &metav1.Duration{Duration: time.Minute}, "main/c3ab8ff", "", time.Duration(0)),
+	)
+})
@@ -0,0 +1,146 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/konfig"
+	kustypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+)
+
+var submodulePathRegexp = regexp.MustCompile(`(?m)^\s*path\s*=\s*(.+?)\s*$`)
+
+// explainMissingBases adds to a build error the local resources referenced by
+// the kustomization.yaml files that are missing or empty directories, as these
+// are usually Git submodules whose content is not included in the artifact.
+func explainMissingBases(buildErr error, fs filesys.FileSystem, rootPath, dirPath string) error {
+	missing, err := missingBases(fs, rootPath, dirPath, 0)
+	if err != nil || len(missing) == 0 {
+		return buildErr
+	}
+
+	submodules := submodulePaths(fs, rootPath)
+	var hints []string
+	for _, path := range missing {
+		if isSubmodulePath(submodules, path) {
+			hints = append(hints, fmt.Sprintf("'%s' is a Git submodule missing from the artifact", path))
+			continue
+		}
+		hints = append(hints, fmt.Sprintf("'%s' is missing or empty, it may be a Git submodule missing from the artifact", path))
+	}
+	return fmt.Errorf("%w; %s", buildErr, strings.Join(hints, "; "))
+}
+
+// missingBases returns the local resources listed in the kustomization.yaml
+// in dirPath, and in the kustomizations it refers to, that don't exist or are
+// empty directories. The paths are relative to rootPath.
+func missingBases(fs filesys.FileSystem, rootPath, dirPath string, depth int) ([]string, error) {
+	if depth > 10 {
+		return nil, nil
+	}
+
+	var kfile string
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		if path := filepath.Join(dirPath, name); fs.Exists(path) && !fs.IsDir(path) {
+			kfile = path
+			break
+		}
+	}
+	if kfile == "" {
+		return nil, nil
+	}
+
+	data, err := fs.ReadFile(kfile)
+	if err != nil {
+		return nil, err
+	}
+	kus := kustypes.Kustomization{}
+	if err := yaml.Unmarshal(data, &kus); err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, resource := range append(kus.Resources, kus.Bases...) {
+		if strings.Contains(resource, "://") {
+			continue
+		}
+		path := filepath.Join(dirPath, resource)
+		if !fs.Exists(path) || (fs.IsDir(path) && isEmptyDir(fs, path)) {
+			rel, err := filepath.Rel(rootPath, path)
+			if err != nil {
+				return nil, err
+			}
+			missing = append(missing, rel)
+			continue
+		}
+		if fs.IsDir(path) {
+			nested, err := missingBases(fs, rootPath, path, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			missing = append(missing, nested...)
+		}
+	}
+	return missing, nil
+}
+
+// isEmptyDir returns true if the directory contains no files.
+func isEmptyDir(fs filesys.FileSystem, dirPath string) bool {
+	empty := true
+	_ = fs.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			empty = false
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return empty
+}
+
+// submodulePaths returns the paths of the submodules
+// declared in the .gitmodules file at the root of the artifact.
+func submodulePaths(fs filesys.FileSystem, rootPath string) []string {
+	data, err := fs.ReadFile(filepath.Join(rootPath, ".gitmodules"))
+	if err != nil {
+		return nil
+	}
+	var paths []string
+	for _, m := range submodulePathRegexp.FindAllStringSubmatch(string(data), -1) {
+		paths = append(paths, filepath.Clean(m[1]))
+	}
+	return paths
+}
+
+// isSubmodulePath returns true if the path is a submodule or is within one.
+func isSubmodulePath(submodules []string, path string) bool {
+	for _, submodule := range submodules {
+		if path == submodule || strings.HasPrefix(path, submodule+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/api/filesys"
+)
+
+var _ = Describe("explainMissingBases", func() {
+	var fs filesys.FileSystem
+
+	BeforeEach(func() {
+		fs = filesys.MakeFsInMemory()
+		Expect(fs.MkdirAll("/repo/app")).To(Succeed())
+		Expect(fs.MkdirAll("/repo/vendor/base")).To(Succeed())
+		Expect(fs.WriteFile("/repo/.gitmodules", []byte(`[submodule "vendor/base"]
+	path = vendor/base
+	url = https://github.com/example/base
+`))).To(Succeed())
+		Expect(fs.WriteFile("/repo/app/kustomization.yaml", []byte(`apiVersion: kustomize.config.k8s.io/v1beta1
+kind: Kustomization
+resources:
+- ../vendor/base
+- ../common
+`))).To(Succeed())
+	})
+
+	It("reports the empty submodules and the missing directories", func() {
+		buildErr := errors.New("kustomize build failed: accumulating resources")
+		err := explainMissingBases(buildErr, fs, "/repo", "/repo/app")
+		Expect(errors.Is(err, buildErr)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("'vendor/base' is a Git submodule missing from the artifact"))
+		Expect(err.Error()).To(ContainSubstring("'common' is missing or empty, it may be a Git submodule"))
+	})
+
+	It("keeps the error when the bases have content", func() {
+		Expect(fs.WriteFile("/repo/vendor/base/kustomization.yaml", []byte("resources: []\n"))).To(Succeed())
+		Expect(fs.MkdirAll("/repo/common")).To(Succeed())
+		Expect(fs.WriteFile("/repo/common/configmap.yaml", []byte("kind: ConfigMap\n"))).To(Succeed())
+
+		buildErr := errors.New("kustomize build failed: accumulating resources")
+		Expect(explainMissingBases(buildErr, fs, "/repo", "/repo/app")).To(Equal(buildErr))
+	})
+})
@@ -0,0 +1,60 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// isClusterUnreachable returns true if the error is caused by the failure to
+// connect to the API server, e.g. connection refused, unknown host or timeout.
+func isClusterUnreachable(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// remoteClusterUnreachable returns true if the reconciliation of a Kustomization
+// targeting a remote cluster failed because the cluster API is unreachable.
+func remoteClusterUnreachable(kustomization kustomizev1.Kustomization, err error) bool {
+	return kustomization.Spec.KubeConfig != nil && isClusterUnreachable(err)
+}
+
+// wasClusterUnreachable returns true if the last reconciliation failed because
+// the remote cluster API was unreachable.
+func wasClusterUnreachable(lastReady *metav1.Condition) bool {
+	return lastReady != nil && lastReady.Reason == kustomizev1.ClusterUnreachableReason
+}
+
+// clusterUnreachableRetryInterval returns the interval at which the
+// reconciliation is retried while the remote cluster API is unreachable,
+// the longest of the retry interval and the controller unreachable interval.
+func (r *KustomizationReconciler) clusterUnreachableRetryInterval(kustomization kustomizev1.Kustomization) time.Duration {
+	if retryInterval := kustomization.GetRetryInterval(); retryInterval > r.unreachableInterval {
+		return retryInterval
+	}
+	return r.unreachableInterval
+}
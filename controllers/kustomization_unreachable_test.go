@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/fluxcd/pkg/apis/meta"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var _ = Describe("KustomizationReconciler unreachable cluster", func() {
+	var (
+		namespace     *corev1.Namespace
+		directClient  client.Client
+		kustomization kustomizev1.Kustomization
+	)
+
+	BeforeEach(func() {
+		var err error
+		directClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+		Expect(err).NotTo(HaveOccurred())
+
+		namespace = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "unreachable-" + randStringRunes(5)},
+		}
+		Expect(directClient.Create(context.Background(), namespace)).To(Succeed())
+
+		// reserve a local port and release it so that nothing listens on it
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		server := fmt.Sprintf("https://%s", l.Addr().String())
+		Expect(l.Close()).To(Succeed())
+
+		kubeConfig := clientcmdapi.NewConfig()
+		kubeConfig.Clusters["remote"] = &clientcmdapi.Cluster{Server: server, InsecureSkipTLSVerify: true}
+		kubeConfig.AuthInfos["admin"] = &clientcmdapi.AuthInfo{Token: "token"}
+		kubeConfig.Contexts["remote"] = &clientcmdapi.Context{Cluster: "remote", AuthInfo: "admin"}
+		kubeConfig.CurrentContext = "remote"
+		data, err := clientcmd.Write(*kubeConfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(directClient.Create(context.Background(), &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "kubeconfig", Namespace: namespace.Name},
+			Data:       map[string][]byte{"value": data},
+		})).To(Succeed())
+
+		kustomization = kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{Name: "remote", Namespace: namespace.Name},
+			Spec: kustomizev1.KustomizationSpec{
+				KubeConfig: &kustomizev1.KubeConfig{
+					SecretRef: meta.LocalObjectReference{Name: "kubeconfig"},
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		Expect(directClient.Delete(context.Background(), namespace)).To(Succeed())
+	})
+
+	It("detects the unreachable remote cluster API", func() {
+		imp := NewKustomizeImpersonation(kustomization, directClient, nil, "", "", "")
+		_, _, err := imp.GetClient(context.Background())
+		Expect(err).To(HaveOccurred())
+		Expect(remoteClusterUnreachable(kustomization, err)).To(BeTrue())
+
+		local := kustomization.DeepCopy()
+		local.Spec.KubeConfig = nil
+		Expect(remoteClusterUnreachable(*local, err)).To(BeFalse())
+	})
+
+	It("does not report other failures as unreachable", func() {
+		Expect(remoteClusterUnreachable(kustomization, fmt.Errorf("invalid manifest"))).To(BeFalse())
+	})
+
+	It("backs off while the remote cluster API is unreachable", func() {
+		r := &KustomizationReconciler{unreachableInterval: 5 * time.Minute}
+		kustomization.Spec.Interval = metav1.Duration{Duration: time.Minute}
+		Expect(r.clusterUnreachableRetryInterval(kustomization)).To(Equal(5 * time.Minute))
+
+		kustomization.Spec.RetryInterval = &metav1.Duration{Duration: 10 * time.Minute}
+		Expect(r.clusterUnreachableRetryInterval(kustomization)).To(Equal(10 * time.Minute))
+
+		Expect(wasClusterUnreachable(&metav1.Condition{Reason: kustomizev1.ClusterUnreachableReason})).To(BeTrue())
+		Expect(wasClusterUnreachable(&metav1.Condition{Reason: meta.ReconciliationFailedReason})).To(BeFalse())
+	})
+})
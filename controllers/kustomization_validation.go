@@ -0,0 +1,166 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"sync"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// validatedManifests holds, for each Kustomization, the hashes of the objects
+// applied at the last successful reconciliation, so that the server-side
+// validation can skip the objects that didn't change since then.
+// The hashes are kept in memory and are lost when the controller restarts.
+type validatedManifests struct {
+	mu      sync.Mutex
+	entries map[string]validatedEntry
+}
+
+type validatedEntry struct {
+	generation int64
+	hashes     map[string]string
+}
+
+// changed returns the manifests of the objects that differ from the last
+// applied ones and the number of objects skipped. All objects are returned
+// when the Kustomization generation changed since the last apply, as a spec
+// change such as a different service account can make them invalid.
+func (v *validatedManifests) changed(kustomization kustomizev1.Kustomization, manifests []byte) ([]byte, int, error) {
+	hashes, docs, err := hashManifests(manifests)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	v.mu.Lock()
+	entry, ok := v.entries[kustomization.GetNamespace()+"/"+kustomization.GetName()]
+	v.mu.Unlock()
+	if !ok || entry.generation != kustomization.GetGeneration() {
+		return manifests, 0, nil
+	}
+
+	var changed []manifest
+	for id, hash := range hashes {
+		if entry.hashes[id] != hash {
+			changed = append(changed, docs[id])
+		}
+	}
+	return joinManifests(changed), len(hashes) - len(changed), nil
+}
+
+// record stores the hashes of the applied objects.
+func (v *validatedManifests) record(kustomization kustomizev1.Kustomization, manifests []byte) error {
+	hashes, _, err := hashManifests(manifests)
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.entries == nil {
+		v.entries = make(map[string]validatedEntry)
+	}
+	v.entries[kustomization.GetNamespace()+"/"+kustomization.GetName()] = validatedEntry{
+		generation: kustomization.GetGeneration(),
+		hashes:     hashes,
+	}
+	return nil
+}
+
+// diff counts the changes of the manifests compared to the last applied ones.
+// When the hashes were lost on a controller restart, the objects are compared
+// with the last inventory instead, and the objects of both are counted as modified.
+func (v *validatedManifests) diff(kustomization kustomizev1.Kustomization, revision string,
+	manifests []byte, inventory *kustomizev1.ResourceInventory) (*kustomizev1.ReconcileDiff, error) {
+	hashes, _, err := hashManifests(manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	v.mu.Lock()
+	entry, ok := v.entries[kustomization.GetNamespace()+"/"+kustomization.GetName()]
+	v.mu.Unlock()
+
+	diff := &kustomizev1.ReconcileDiff{Revision: revision}
+	if !ok {
+		last := make(map[string]bool)
+		if kustomization.Status.Inventory != nil {
+			for _, ref := range kustomization.Status.Inventory.Entries {
+				last[ref.ID] = true
+			}
+		}
+		for _, ref := range inventory.Entries {
+			if last[ref.ID] {
+				diff.Modified++
+				delete(last, ref.ID)
+			} else {
+				diff.Added++
+			}
+		}
+		diff.Removed = len(last)
+		return diff, nil
+	}
+
+	for id, hash := range hashes {
+		lastHash, found := entry.hashes[id]
+		switch {
+		case !found:
+			diff.Added++
+		case lastHash != hash:
+			diff.Modified++
+		default:
+			diff.Unchanged++
+		}
+	}
+	for id := range entry.hashes {
+		if _, found := hashes[id]; !found {
+			diff.Removed++
+		}
+	}
+	return diff, nil
+}
+
+// forget removes the hashes of a deleted Kustomization.
+func (v *validatedManifests) forget(kustomization kustomizev1.Kustomization) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.entries, kustomization.GetNamespace()+"/"+kustomization.GetName())
+}
+
+// hashManifests returns the hash and the manifest of each object
+// in the multi-doc YAML, indexed by the object API version, kind,
+// namespace and name.
+func hashManifests(manifests []byte) (map[string]string, map[string]manifest, error) {
+	objects, err := parseManifests(manifests)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make(map[string]string)
+	docs := make(map[string]manifest)
+	for _, m := range objects {
+		if m.object.Object == nil {
+			continue
+		}
+		id := fmt.Sprintf("%s/%s", m.object.GetAPIVersion(), objectName(m.object))
+		hashes[id] = fmt.Sprintf("%x", sha1.Sum(m.doc))
+		docs[id] = m
+	}
+	return hashes, docs, nil
+}
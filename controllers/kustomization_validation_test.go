@@ -0,0 +1,136 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var _ = Describe("validatedManifests", func() {
+	const applied = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+  namespace: app
+data:
+  key: value
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app-secret
+  namespace: app
+`
+
+	const updated = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+  namespace: app
+data:
+  key: other
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: app-secret
+  namespace: app
+`
+
+	var kustomization kustomizev1.Kustomization
+
+	BeforeEach(func() {
+		kustomization = kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "flux-system", Generation: 1},
+		}
+	})
+
+	It("returns all objects when nothing was applied", func() {
+		v := validatedManifests{}
+		changed, skipped, err := v.changed(kustomization, []byte(applied))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(skipped).To(Equal(0))
+		Expect(string(changed)).To(Equal(applied))
+	})
+
+	It("returns only the changed objects", func() {
+		v := validatedManifests{}
+		Expect(v.record(kustomization, []byte(applied))).To(Succeed())
+
+		changed, skipped, err := v.changed(kustomization, []byte(updated))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(skipped).To(Equal(1))
+		Expect(string(changed)).To(ContainSubstring("key: other"))
+		Expect(string(changed)).NotTo(ContainSubstring("app-secret"))
+
+		changed, _, err = v.changed(kustomization, []byte(applied))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).To(BeEmpty())
+	})
+
+	It("returns all objects after a spec change", func() {
+		v := validatedManifests{}
+		Expect(v.record(kustomization, []byte(applied))).To(Succeed())
+
+		kustomization.Generation = 2
+		_, skipped, err := v.changed(kustomization, []byte(updated))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(skipped).To(Equal(0))
+	})
+
+	It("counts the changes since the last apply", func() {
+		v := validatedManifests{}
+		Expect(v.record(kustomization, []byte(applied))).To(Succeed())
+
+		manifests := updated + `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+  namespace: app
+`
+		inventory, err := kustomizev1.NewInventory([]byte(manifests))
+		Expect(err).NotTo(HaveOccurred())
+		diff, err := v.diff(kustomization, "main/abc", []byte(manifests), inventory)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*diff).To(Equal(kustomizev1.ReconcileDiff{
+			Revision:  "main/abc",
+			Added:     1,
+			Modified:  1,
+			Unchanged: 1,
+		}))
+	})
+
+	It("compares with the last inventory when the hashes are lost", func() {
+		v := validatedManifests{}
+		last, err := kustomizev1.NewInventory([]byte(applied))
+		Expect(err).NotTo(HaveOccurred())
+		kustomization.Status.Inventory = last
+
+		inventory, err := kustomizev1.NewInventory([]byte(updated))
+		Expect(err).NotTo(HaveOccurred())
+		inventory.Entries = inventory.Entries[:1]
+		diff, err := v.diff(kustomization, "main/abc", []byte(updated), inventory)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diff.Modified).To(Equal(1))
+		Expect(diff.Removed).To(Equal(1))
+	})
+})
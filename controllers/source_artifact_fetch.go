@@ -0,0 +1,118 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// errPermanentDownload wraps the download failures that are not retried.
+var errPermanentDownload = errors.New("permanent artifact download failure")
+
+// fetchArtifact downloads the artifact to a temporary file and returns its path.
+// Each attempt is bounded by the artifact download timeout, or by the
+// Kustomization timeout when not set. The failed attempts are retried up to
+// the artifact download retries, with an exponential backoff, except for the
+// client errors returned by the server.
+func (r *KustomizationReconciler) fetchArtifact(kustomization kustomizev1.Kustomization, url string) (string, error) {
+	timeout := r.artifactDownloadTimeout(kustomization)
+
+	retries := r.downloadRetries
+	if retries < 0 {
+		retries = 0
+	}
+	backoff := wait.Backoff{
+		Duration: r.downloadBackoff,
+		Factor:   2,
+		Jitter:   0.1,
+		Steps:    retries + 1,
+	}
+	var (
+		path    string
+		lastErr error
+	)
+	err := wait.ExponentialBackoff(backoff, func() (bool, error) {
+		path, lastErr = downloadArtifact(url, kustomization.Name, timeout)
+		if errors.Is(lastErr, errPermanentDownload) {
+			return false, lastErr
+		}
+		return lastErr == nil, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return "", fmt.Errorf("%w (after %d attempts)", lastErr, backoff.Steps)
+	}
+	return path, err
+}
+
+// artifactDownloadTimeout returns the timeout of an artifact download attempt.
+func (r *KustomizationReconciler) artifactDownloadTimeout(kustomization kustomizev1.Kustomization) time.Duration {
+	if r.downloadTimeout > 0 {
+		return r.downloadTimeout
+	}
+	return kustomization.GetTimeout() + (time.Second * 1)
+}
+
+// downloadArtifact makes a single attempt at downloading the artifact
+// to a temporary file, the file is removed if the download fails.
+func downloadArtifact(url, name string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create HTTP request for %s, error: %w", url, err)
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", fmt.Errorf("failed to download artifact from %s, error: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("faild to download artifact from %s, status: %s", url, resp.Status)
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			err = fmt.Errorf("%w: %s", errPermanentDownload, err.Error())
+		}
+		return "", err
+	}
+
+	f, err := ioutil.TempFile("", name+"-artifact-*.tar.gz")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file, error: %w", err)
+	}
+	_, err = io.Copy(f, resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to download artifact from %s, error: %w", url, err)
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var _ = Describe("fetchArtifact", func() {
+	var (
+		requests   int32
+		failures   int32
+		status     int
+		delay      time.Duration
+		server     *httptest.Server
+		reconciler *KustomizationReconciler
+	)
+
+	kustomization := kustomizev1.Kustomization{
+		ObjectMeta: metav1.ObjectMeta{Name: "fetch"},
+		Spec: kustomizev1.KustomizationSpec{
+			Interval: metav1.Duration{Duration: time.Minute},
+		},
+	}
+
+	BeforeEach(func() {
+		atomic.StoreInt32(&requests, 0)
+		failures = 0
+		status = http.StatusServiceUnavailable
+		delay = 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			n := atomic.AddInt32(&requests, 1)
+			time.Sleep(delay)
+			if n <= failures {
+				w.WriteHeader(status)
+				return
+			}
+			w.Write([]byte("artifact"))
+		}))
+		reconciler = &KustomizationReconciler{
+			downloadRetries: 2,
+			downloadBackoff: 10 * time.Millisecond,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("retries the failed downloads", func() {
+		failures = 2
+		path, err := reconciler.fetchArtifact(kustomization, server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		defer os.Remove(path)
+
+		data, err := ioutil.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal("artifact"))
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(3)))
+	})
+
+	It("fails after the last retry", func() {
+		failures = 3
+		_, err := reconciler.fetchArtifact(kustomization, server.URL)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("503 Service Unavailable"))
+		Expect(err.Error()).To(ContainSubstring("after 3 attempts"))
+	})
+
+	It("doesn't retry the client errors", func() {
+		failures = 1
+		status = http.StatusNotFound
+		_, err := reconciler.fetchArtifact(kustomization, server.URL)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("404 Not Found"))
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(1)))
+	})
+
+	It("times out the slow downloads", func() {
+		delay = 200 * time.Millisecond
+		reconciler.downloadTimeout = 50 * time.Millisecond
+		reconciler.downloadRetries = 1
+		_, err := reconciler.fetchArtifact(kustomization, server.URL)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("context deadline exceeded"))
+		Expect(atomic.LoadInt32(&requests)).To(Equal(int32(2)))
+	})
+})
@@ -0,0 +1,114 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta1"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+// artifactURLSource is a sourcev1.Source for an artifact that is
+// downloaded directly from the Kustomization ArtifactURL, without
+// a source-controller object.
+type artifactURLSource struct {
+	artifact *sourcev1.Artifact
+	interval metav1.Duration
+
+	// path is the downloaded artifact, extracted instead of
+	// downloading the artifact a second time.
+	path string
+}
+
+// newArtifactURLSource downloads the artifact served at the Kustomization
+// ArtifactURL, with the artifact download timeout and retries, and uses the
+// 'sha256:<checksum>' of its content as revision, so that a change of the
+// content served at the same address results in a new revision.
+// The downloaded file must be removed by the caller.
+func (r *KustomizationReconciler) newArtifactURLSource(kustomization kustomizev1.Kustomization) (*artifactURLSource, error) {
+	url := kustomization.Spec.ArtifactURL
+	path, err := r.fetchArtifact(kustomization, url)
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := fileChecksum(path)
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to get the revision of artifact %s, error: %w", url, err)
+	}
+
+	return &artifactURLSource{
+		artifact: &sourcev1.Artifact{
+			Path:           url,
+			URL:            url,
+			Revision:       "sha256:" + revision,
+			LastUpdateTime: metav1.Now(),
+		},
+		interval: kustomization.Spec.Interval,
+		path:     path,
+	}, nil
+}
+
+// GetArtifact returns the artifact pointing to the ArtifactURL.
+func (s *artifactURLSource) GetArtifact() *sourcev1.Artifact {
+	return s.artifact
+}
+
+// GetInterval returns the interval of the Kustomization.
+func (s *artifactURLSource) GetInterval() metav1.Duration {
+	return s.interval
+}
+
+// fileChecksum returns the hex encoded SHA-256 checksum of the file content.
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// checkSourceSpec returns an error when the Kustomization sets an ArtifactURL
+// while the controller doesn't allow it, or sets a SourceRef without a kind
+// or a name while it is the source of the artifact.
+func (r *KustomizationReconciler) checkSourceSpec(kustomization kustomizev1.Kustomization) error {
+	if kustomization.Spec.ArtifactURL != "" {
+		if !r.enableArtifactURL {
+			return errors.New("spec.artifactURL is not allowed, the controller must be started with --enable-artifact-url")
+		}
+		return nil
+	}
+	if ref := kustomization.Spec.SourceRef; ref.Kind == "" || ref.Name == "" {
+		return errors.New("spec.sourceRef kind and name are required when spec.artifactURL is not set")
+	}
+	return nil
+}
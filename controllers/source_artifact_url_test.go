@@ -0,0 +1,140 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
+)
+
+var _ = Describe("KustomizationReconciler artifact URL", func() {
+	var (
+		content  string
+		failures int
+		requests int
+		server   *httptest.Server
+		paths    []string
+	)
+
+	BeforeEach(func() {
+		content = "artifact-v1"
+		failures = 0
+		requests = 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			requests++
+			if failures > 0 {
+				failures--
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(content))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+		for _, path := range paths {
+			os.Remove(path)
+		}
+		paths = nil
+	})
+
+	kustomization := func(url string) kustomizev1.Kustomization {
+		return kustomizev1.Kustomization{
+			ObjectMeta: metav1.ObjectMeta{Name: "bootstrap", Namespace: "default"},
+			Spec: kustomizev1.KustomizationSpec{
+				Interval:    metav1.Duration{Duration: time.Minute},
+				ArtifactURL: url,
+			},
+		}
+	}
+
+	getSource := func(reconciler *KustomizationReconciler, url string) (*artifactURLSource, error) {
+		source, err := reconciler.getSource(context.Background(), kustomization(url))
+		if err != nil {
+			return nil, err
+		}
+		s := source.(*artifactURLSource)
+		paths = append(paths, s.path)
+		return s, nil
+	}
+
+	It("downloads the artifact once and uses its checksum as the revision", func() {
+		reconciler := &KustomizationReconciler{enableArtifactURL: true}
+		source, err := getSource(reconciler, server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(source.GetArtifact().URL).To(Equal(server.URL))
+		Expect(source.GetArtifact().Revision).To(Equal(fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("artifact-v1")))))
+		Expect(requests).To(Equal(1))
+
+		data, err := ioutil.ReadFile(source.path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal("artifact-v1"))
+
+		content = "artifact-v2"
+		source, err = getSource(reconciler, server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(source.GetArtifact().Revision).To(Equal(fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("artifact-v2")))))
+	})
+
+	It("retries the download", func() {
+		failures = 2
+		reconciler := &KustomizationReconciler{enableArtifactURL: true, downloadRetries: 2, downloadBackoff: time.Millisecond}
+		source, err := getSource(reconciler, server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(source.GetArtifact().Revision).To(Equal(fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("artifact-v1")))))
+		Expect(requests).To(Equal(3))
+	})
+
+	It("fails when the artifact is not served", func() {
+		notFound := httptest.NewServer(http.NotFoundHandler())
+		defer notFound.Close()
+		_, err := getSource(&KustomizationReconciler{}, notFound.URL)
+		Expect(err).To(MatchError(ContainSubstring("404 Not Found")))
+	})
+
+	It("rejects the artifact URL unless enabled", func() {
+		reconciler := &KustomizationReconciler{}
+		err := reconciler.checkSourceSpec(kustomization(server.URL))
+		Expect(err).To(MatchError(ContainSubstring("--enable-artifact-url")))
+
+		reconciler.enableArtifactURL = true
+		Expect(reconciler.checkSourceSpec(kustomization(server.URL))).To(Succeed())
+	})
+
+	It("requires a source reference without an artifact URL", func() {
+		reconciler := &KustomizationReconciler{enableArtifactURL: true}
+		err := reconciler.checkSourceSpec(kustomization(""))
+		Expect(err).To(MatchError(ContainSubstring("spec.sourceRef kind and name are required")))
+
+		k := kustomization("")
+		k.Spec.SourceRef = kustomizev1.CrossNamespaceSourceReference{Kind: "GitRepository", Name: "app"}
+		Expect(reconciler.checkSourceSpec(k)).To(Succeed())
+	})
+})
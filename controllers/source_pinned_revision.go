@@ -0,0 +1,39 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"strings"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta1"
+)
+
+// checkPinnedRevision returns an error if the source artifact is not the
+// artifact of the pinned revision. The revision matches either the full
+// artifact revision e.g. 'main/<commit-sha>', or its last segment e.g. '<commit-sha>'.
+// source-controller only exposes the latest artifact of a source, and garbage
+// collects the artifacts of the previous revisions, so a pinned revision can
+// be built only while the source itself is at that revision.
+func checkPinnedRevision(source sourcev1.Source, revision string) error {
+	latest := source.GetArtifact().Revision
+	if latest == revision || strings.HasSuffix(latest, "/"+revision) {
+		return nil
+	}
+	return fmt.Errorf("pinned revision '%s' is not available, the source artifact is at revision '%s', "+
+		"the source must be set to the pinned revision e.g. with the GitRepository spec.ref.commit", revision, latest)
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	sourcev1 "github.com/fluxcd/source-controller/api/v1beta1"
+)
+
+var _ = Describe("checkPinnedRevision", func() {
+	source := func(revision string) sourcev1.Source {
+		return &sourcev1.GitRepository{
+			Status: sourcev1.GitRepositoryStatus{
+				Artifact: &sourcev1.Artifact{
+					URL:      "http://source-controller/gitrepository/default/app/" + revision + ".tar.gz",
+					Revision: revision,
+				},
+			},
+		}
+	}
+
+	It("accepts the source at the pinned revision", func() {
+		Expect(checkPinnedRevision(source("main/5394cb7"), "main/5394cb7")).To(Succeed())
+	})
+
+	It("accepts the commit SHA of the pinned revision", func() {
+		Expect(checkPinnedRevision(source("main/5394cb7"), "5394cb7")).To(Succeed())
+	})
+
+	It("rejects the source at another revision", func() {
+		err := checkPinnedRevision(source("main/a1b2c3d"), "main/5394cb7")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("pinned revision 'main/5394cb7' is not available"))
+		Expect(err.Error()).To(ContainSubstring("at revision 'main/a1b2c3d'"))
+	})
+
+	It("rejects a partial commit SHA", func() {
+		Expect(checkPinnedRevision(source("main/5394cb7"), "394cb7")).NotTo(Succeed())
+	})
+
+	It("rejects the revisions of sources without a path in their URL", func() {
+		s := source("5394cb7")
+		s.GetArtifact().URL = "artifact.tar.gz"
+		Expect(checkPinnedRevision(s, "main/5394cb7")).NotTo(Succeed())
+		Expect(checkPinnedRevision(s, "5394cb7")).To(Succeed())
+	})
+})
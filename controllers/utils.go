@@ -17,9 +17,18 @@ limitations under the License.
 package controllers
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"strings"
+	"sigs.k8s.io/yaml"
 )
 
 // parseApplyOutput extracts the objects and the action
@@ -65,6 +74,165 @@ func parseApplyError(in []byte) string {
 	return errors
 }
 
+// parseDryRunFailures extracts the errors of the objects
+// rejected by the API server from the kubectl output e.g.:
+// Error from server (Invalid): error when creating "manifests.yaml": Service "backend" is invalid
+// Error from server (NotFound): error when creating "manifests.yaml": namespaces "dev" not found
+func parseDryRunFailures(in []byte) []string {
+	var failures []string
+	for _, line := range strings.Split(string(in), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Error from server") || strings.HasPrefix(line, "error:") {
+			failures = append(failures, line)
+		}
+	}
+	return failures
+}
+
+// manifest is a document of a multi-doc YAML and the object it decodes to.
+// The object is nil for the documents holding only comments.
+type manifest struct {
+	doc    []byte
+	object unstructured.Unstructured
+}
+
+// manifestSeparator separates the documents of the multi-doc YAML manifests.
+var manifestSeparator = []byte("\n---\n")
+
+// parseManifests splits the multi-doc YAML in documents, skipping the empty
+// ones and the leading and trailing separators, and decodes their objects.
+func parseManifests(data []byte) ([]manifest, error) {
+	var manifests []manifest
+	for _, doc := range bytes.Split(data, manifestSeparator) {
+		doc = bytes.TrimSuffix(bytes.TrimPrefix(doc, []byte("---\n")), []byte("\n---"))
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		var obj unstructured.Unstructured
+		if err := yaml.Unmarshal(doc, &obj.Object); err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, manifest{doc: doc, object: obj})
+	}
+	return manifests, nil
+}
+
+// joinManifests returns the multi-doc YAML of the manifests documents.
+func joinManifests(manifests []manifest) []byte {
+	docs := make([][]byte, 0, len(manifests))
+	for _, m := range manifests {
+		docs = append(docs, m.doc)
+	}
+	return bytes.Join(docs, manifestSeparator)
+}
+
+// manifestsBatch holds the file name and the number of objects of a batch.
+type manifestsBatch struct {
+	file string
+	size int
+}
+
+// splitManifests splits the multi-doc YAML manifests file in files
+// of at most batchSize objects, preserving the objects order.
+// It returns the batches and the total number of objects.
+func splitManifests(dirPath, manifestsFile string, batchSize int) ([]manifestsBatch, int, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dirPath, manifestsFile))
+	if err != nil {
+		return nil, 0, err
+	}
+	manifests, err := parseManifests(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var batches []manifestsBatch
+	for i := 0; i < len(manifests); i += batchSize {
+		end := i + batchSize
+		if end > len(manifests) {
+			end = len(manifests)
+		}
+		batch := manifestsBatch{
+			file: fmt.Sprintf("%s.batch-%d.yaml", strings.TrimSuffix(manifestsFile, filepath.Ext(manifestsFile)), len(batches)),
+			size: end - i,
+		}
+		if err := ioutil.WriteFile(filepath.Join(dirPath, batch.file), joinManifests(manifests[i:end]), os.ModePerm); err != nil {
+			return nil, 0, err
+		}
+		batches = append(batches, batch)
+	}
+	return batches, len(manifests), nil
+}
+
+// nextManifestsBatch returns the first batch holding objects that are not
+// applied yet, the objects being applied in order, and the number of objects
+// of the batches before it. It returns false when all objects are applied.
+func nextManifestsBatch(batches []manifestsBatch, applied int) (manifestsBatch, int, bool) {
+	skipped := 0
+	for _, batch := range batches {
+		if skipped+batch.size > applied {
+			return batch, skipped, true
+		}
+		skipped += batch.size
+	}
+	return manifestsBatch{}, skipped, false
+}
+
+// stripComponents moves the files of the src directory to the dst directory,
+// removing the first n components of their path as in 'tar --strip-components'.
+// Files with n or fewer path components are skipped.
+func stripComponents(src, dst string, n int) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		if len(parts) <= n {
+			return nil
+		}
+		target := filepath.Join(dst, filepath.Join(parts[n:]...))
+		if err := os.MkdirAll(filepath.Dir(target), os.ModePerm); err != nil {
+			return err
+		}
+		return os.Rename(path, target)
+	})
+}
+
+// containsManifests returns true if the directory tree
+// contains at least one YAML or JSON file.
+func containsManifests(dirPath string) (bool, error) {
+	found := false
+	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+			if !info.IsDir() {
+				found = true
+				return io.EOF
+			}
+		}
+		return nil
+	})
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	return found, nil
+}
+
+// isEmptyFile returns true if the file exists and has no content.
+func isEmptyFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Size() == 0
+}
+
 func containsString(slice []string, s string) bool {
 	for _, item := range slice {
 		if item == s {
@@ -0,0 +1,192 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("parseDryRunFailures", func() {
+	DescribeTable("extracts the errors of the rejected objects",
+		func(output string, expected []string) {
+			Expect(parseDryRunFailures([]byte(output))).To(Equal(expected))
+		},
+		Entry("no failures", `service/backend created (server dry run)
+`, nil),
+		Entry("multiple failures", `service/frontend created (server dry run)
+Error from server (Invalid): error when creating "manifests.yaml": Service "backend" is invalid: spec.type: Unsupported value: "Ingress"
+Error from server (NotFound): error when creating "manifests.yaml": namespaces "dev" not found
+`, []string{
+			`Error from server (Invalid): error when creating "manifests.yaml": Service "backend" is invalid: spec.type: Unsupported value: "Ingress"`,
+			`Error from server (NotFound): error when creating "manifests.yaml": namespaces "dev" not found`,
+		}),
+	)
+})
+
+var _ = Describe("parseManifests", func() {
+	DescribeTable("decodes the objects of the documents",
+		func(manifests string, expected []string) {
+			docs, err := parseManifests([]byte(manifests))
+			Expect(err).NotTo(HaveOccurred())
+			var names []string
+			for _, m := range docs {
+				names = append(names, m.object.GetName())
+			}
+			Expect(names).To(Equal(expected))
+		},
+		Entry("no documents", "", nil),
+		Entry("single document", "kind: ConfigMap\nmetadata:\n  name: a\n", []string{"a"}),
+		Entry("leading and trailing separators", "---\nkind: ConfigMap\nmetadata:\n  name: a\n---\nkind: ConfigMap\nmetadata:\n  name: b\n---\n", []string{"a", "b"}),
+		Entry("empty documents", "kind: ConfigMap\nmetadata:\n  name: a\n---\n\n---\n---\nkind: ConfigMap\nmetadata:\n  name: b", []string{"a", "b"}),
+		Entry("comment only document", "# comment\n---\nkind: ConfigMap\nmetadata:\n  name: a", []string{"", "a"}),
+	)
+
+	It("joins the documents unchanged", func() {
+		manifests := "kind: ConfigMap\nmetadata:\n  name: a\n---\nkind: ConfigMap\nmetadata:\n  name: b\n"
+		docs, err := parseManifests([]byte(manifests))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(joinManifests(docs))).To(Equal(manifests))
+	})
+
+	It("fails on invalid YAML", func() {
+		_, err := parseManifests([]byte("kind: ConfigMap\n---\nkind: [ConfigMap"))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("splitManifests", func() {
+	var dirPath string
+
+	BeforeEach(func() {
+		var err error
+		dirPath, err = ioutil.TempDir("", "split-manifests")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dirPath)
+	})
+
+	configMaps := func(names ...string) string {
+		var docs []string
+		for _, name := range names {
+			docs = append(docs, fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s", name))
+		}
+		return strings.Join(docs, "\n---\n")
+	}
+
+	split := func(manifests string, batchSize int) ([]string, int) {
+		Expect(ioutil.WriteFile(filepath.Join(dirPath, "manifests.yaml"), []byte(manifests), 0644)).To(Succeed())
+		batches, total, err := splitManifests(dirPath, "manifests.yaml", batchSize)
+		Expect(err).NotTo(HaveOccurred())
+
+		var names []string
+		size := 0
+		for _, batch := range batches {
+			data, err := ioutil.ReadFile(filepath.Join(dirPath, batch.file))
+			Expect(err).NotTo(HaveOccurred())
+			var batchNames []string
+			for _, line := range strings.Split(string(data), "\n") {
+				if strings.HasPrefix(line, "  name: ") {
+					batchNames = append(batchNames, strings.TrimPrefix(line, "  name: "))
+				}
+			}
+			Expect(batchNames).To(HaveLen(batch.size))
+			names = append(names, strings.Join(batchNames, ","))
+			size += batch.size
+		}
+		Expect(size).To(Equal(total))
+		return names, total
+	}
+
+	DescribeTable("splits the objects in batches, in order",
+		func(manifests string, batchSize int, expected []string) {
+			names, total := split(manifests, batchSize)
+			Expect(names).To(Equal(expected))
+			Expect(total).To(Equal(len(strings.Split(strings.Join(expected, ","), ","))))
+		},
+		Entry("batch size dividing the objects", configMaps("a", "b", "c", "d"), 2, []string{"a,b", "c,d"}),
+		Entry("last batch smaller", configMaps("a", "b", "c", "d", "e"), 2, []string{"a,b", "c,d", "e"}),
+		Entry("batch size of one", configMaps("a", "b", "c"), 1, []string{"a", "b", "c"}),
+		Entry("batch size larger than the objects", configMaps("a", "b", "c"), 10, []string{"a,b,c"}),
+		Entry("empty documents", "---\n"+configMaps("a", "b")+"\n---\n\n---\n"+configMaps("c"), 2, []string{"a,b", "c"}),
+		Entry("trailing separator", configMaps("a", "b", "c")+"\n---\n", 2, []string{"a,b", "c"}),
+		Entry("trailing separator without new line", configMaps("a", "b", "c")+"\n---", 3, []string{"a,b,c"}),
+	)
+
+	It("returns no batch for empty manifests", func() {
+		names, total := split("---\n\n---\n", 2)
+		Expect(names).To(BeEmpty())
+		Expect(total).To(BeZero())
+	})
+})
+
+var _ = Describe("nextManifestsBatch", func() {
+	batches := []manifestsBatch{{file: "batch-0", size: 2}, {file: "batch-1", size: 2}, {file: "batch-2", size: 1}}
+
+	DescribeTable("returns the first batch with objects not applied yet",
+		func(applied int, file string, skipped int, found bool) {
+			batch, s, ok := nextManifestsBatch(batches, applied)
+			Expect(ok).To(Equal(found))
+			Expect(batch.file).To(Equal(file))
+			Expect(s).To(Equal(skipped))
+		},
+		Entry("nothing applied", 0, "batch-0", 0, true),
+		Entry("first batch applied", 2, "batch-1", 2, true),
+		Entry("batch partially applied after a batch size change", 3, "batch-1", 2, true),
+		Entry("all but the last batch applied", 4, "batch-2", 4, true),
+		Entry("all applied", 5, "", 5, false),
+	)
+})
+
+var _ = Describe("stripComponents", func() {
+	It("moves the files to the stripped path", func() {
+		src, err := ioutil.TempDir("", "strip-src")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(src)
+		dst, err := ioutil.TempDir("", "strip-dst")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dst)
+
+		Expect(os.MkdirAll(filepath.Join(src, "repo-abc123", "deploy"), os.ModePerm)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(src, "repo-abc123", "deploy", "app.yaml"), []byte("kind: ConfigMap"), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(src, "README.md"), []byte("readme"), 0644)).To(Succeed())
+
+		Expect(stripComponents(src, dst, 1)).To(Succeed())
+		Expect(filepath.Join(dst, "deploy", "app.yaml")).To(BeAnExistingFile())
+		Expect(filepath.Join(dst, "README.md")).NotTo(BeAnExistingFile())
+
+		ok, err := containsManifests(filepath.Join(dst, "deploy"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeTrue())
+
+		empty, err := ioutil.TempDir("", "strip-empty")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(empty)
+		ok, err = containsManifests(empty)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ok).To(BeFalse())
+	})
+})
@@ -0,0 +1,76 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Entry records the changes made to the cluster by a reconciliation.
+// The objects are referenced by kind and name only, the manifests
+// and the kubectl output are never recorded, so that Secrets values
+// can't end up in the audit log.
+type Entry struct {
+	// Timestamp is the time the reconciliation finished.
+	Timestamp time.Time `json:"ts"`
+
+	// Kustomization is the namespaced name of the Kustomization.
+	Kustomization string `json:"kustomization"`
+
+	// Revision is the source revision that was applied.
+	Revision string `json:"revision"`
+
+	// Identity is the identity the objects were applied with,
+	// a service account, a kubeconfig or the controller itself.
+	Identity string `json:"identity"`
+
+	// Created lists the objects created by the reconciliation.
+	Created []string `json:"created,omitempty"`
+
+	// Updated lists the objects updated by the reconciliation.
+	Updated []string `json:"updated,omitempty"`
+
+	// Deleted lists the objects deleted by the garbage collector.
+	Deleted []string `json:"deleted,omitempty"`
+}
+
+// Logger writes the audit entries as JSON lines to a dedicated sink,
+// independently of the controller log level.
+type Logger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewLogger returns a Logger that appends the entries to w.
+func NewLogger(w io.Writer) *Logger {
+	return &Logger{w: w}
+}
+
+// Record writes the entry as a single JSON line.
+func (l *Logger) Record(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.w.Write(append(data, '\n'))
+	return err
+}
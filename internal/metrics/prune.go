@@ -0,0 +1,82 @@
+/*
+Copyright 2021 The Flux authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PruneRecorder records the garbage collection activity of the Kustomizations.
+type PruneRecorder struct {
+	prunedCounter       *prometheus.CounterVec
+	limitReachedCounter *prometheus.CounterVec
+	failureCounter      *prometheus.CounterVec
+}
+
+// NewPruneRecorder returns a PruneRecorder with counters labeled
+// by the kind, name and namespace of the reconciled object.
+func NewPruneRecorder() *PruneRecorder {
+	labels := []string{"kind", "name", "namespace"}
+	return &PruneRecorder{
+		prunedCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gotk_prune_objects_total",
+				Help: "The total number of objects deleted by the garbage collector.",
+			},
+			labels,
+		),
+		limitReachedCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gotk_prune_limit_reached_total",
+				Help: "The total number of garbage collections that found more objects to delete than the prune limit.",
+			},
+			labels,
+		),
+		failureCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "gotk_prune_failures_total",
+				Help: "The total number of objects the garbage collector failed to delete.",
+			},
+			labels,
+		),
+	}
+}
+
+// Collectors returns the collectors to be registered.
+func (r *PruneRecorder) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		r.prunedCounter,
+		r.limitReachedCounter,
+		r.failureCounter,
+	}
+}
+
+// RecordPruned adds the number of deleted objects to the object counter.
+func (r *PruneRecorder) RecordPruned(ref corev1.ObjectReference, count int) {
+	r.prunedCounter.WithLabelValues(ref.Kind, ref.Name, ref.Namespace).Add(float64(count))
+}
+
+// RecordLimitReached increments the prune limit counter.
+func (r *PruneRecorder) RecordLimitReached(ref corev1.ObjectReference) {
+	r.limitReachedCounter.WithLabelValues(ref.Kind, ref.Name, ref.Namespace).Inc()
+}
+
+// RecordFailures adds the number of objects that failed to be deleted to the failure counter.
+func (r *PruneRecorder) RecordFailures(ref corev1.ObjectReference, count int) {
+	r.failureCounter.WithLabelValues(ref.Kind, ref.Name, ref.Namespace).Add(float64(count))
+}
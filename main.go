@@ -18,9 +18,12 @@ package main
 
 import (
 	"os"
+	"runtime/debug"
+	"strings"
 	"time"
 
 	flag "github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
@@ -37,6 +40,8 @@ import (
 
 	kustomizev1 "github.com/fluxcd/kustomize-controller/api/v1beta1"
 	"github.com/fluxcd/kustomize-controller/controllers"
+	"github.com/fluxcd/kustomize-controller/internal/audit"
+	kmetrics "github.com/fluxcd/kustomize-controller/internal/metrics"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -45,6 +50,9 @@ var (
 	setupLog = ctrl.Log.WithName("setup")
 )
 
+// version is set at build time with -ldflags "-X main.version=<version>".
+var version = "dev"
+
 func init() {
 	_ = clientgoscheme.AddToScheme(scheme)
 
@@ -55,15 +63,34 @@ func init() {
 
 func main() {
 	var (
-		metricsAddr          string
-		eventsAddr           string
-		healthAddr           string
-		enableLeaderElection bool
-		concurrent           int
-		requeueDependency    time.Duration
-		clientOptions        client.Options
-		logOptions           logger.Options
-		watchAllNamespaces   bool
+		metricsAddr           string
+		eventsAddr            string
+		healthAddr            string
+		enableLeaderElection  bool
+		concurrent            int
+		requeueDependency     time.Duration
+		clientOptions         client.Options
+		logOptions            logger.Options
+		watchAllNamespaces    bool
+		defaultServiceAccount string
+		requiredLabels        []string
+		requiredAnnotations   []string
+		reconcileBudget       time.Duration
+		defaultInterval       time.Duration
+		defaultTimeout        time.Duration
+		minInterval           time.Duration
+		downloadTimeout       time.Duration
+		downloadRetries       int
+		unreachableInterval   time.Duration
+		objectQuotas          []string
+		gcPercent             int
+		buildHeapLimit        string
+		readinessRules        string
+		auditLogPath          string
+		maxPreconditionWatch  int
+		userAgent             string
+		maxScanDepth          int
+		enableArtifactURL     bool
 	)
 
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
@@ -76,6 +103,44 @@ func main() {
 	flag.DurationVar(&requeueDependency, "requeue-dependency", 30*time.Second, "The interval at which failing dependencies are reevaluated.")
 	flag.BoolVar(&watchAllNamespaces, "watch-all-namespaces", true,
 		"Watch for custom resources in all namespaces, if set to false it will only watch the runtime namespace.")
+	flag.StringVar(&defaultServiceAccount, "default-service-account", "",
+		"Default service account used for impersonation when a Kustomization does not specify one.")
+	flag.StringSliceVar(&requiredLabels, "required-labels", []string{},
+		"Label keys that must be set on every Kustomization, reconciliation is skipped for those missing any of them.")
+	flag.StringSliceVar(&requiredAnnotations, "required-annotations", []string{},
+		"Annotation keys that must be set on every Kustomization, reconciliation is skipped for those missing any of them.")
+	flag.DurationVar(&reconcileBudget, "default-reconcile-budget", 0,
+		"Default maximum duration of a reconciliation before a ReconcileBudgetExceeded condition is reported, zero disables the check.")
+	flag.DurationVar(&defaultInterval, "default-interval", 10*time.Minute,
+		"Default reconciliation interval of the Kustomizations that don't specify one.")
+	flag.DurationVar(&defaultTimeout, "default-timeout", 0,
+		"Default timeout of the Kustomizations that don't specify one, zero defaults the timeout to the interval.")
+	flag.DurationVar(&minInterval, "min-interval", 0,
+		"Minimum reconciliation interval, lower intervals are raised to the minimum, zero disables the check.")
+	flag.DurationVar(&downloadTimeout, "artifact-download-timeout", 0,
+		"The timeout of each artifact download attempt, zero defaults the timeout to the Kustomization timeout.")
+	flag.IntVar(&downloadRetries, "artifact-download-retries", 2,
+		"The number of times a failed artifact download is retried, with an exponential backoff starting at one second.")
+	flag.DurationVar(&unreachableInterval, "cluster-unreachable-interval", 5*time.Minute,
+		"The minimum retry interval of the Kustomizations whose remote cluster API is unreachable.")
+	flag.StringSliceVar(&objectQuotas, "object-quota", []string{},
+		"The maximum number of objects of a kind rendered by each Kustomization of a namespace, in the format '<namespace>/<kind>=<max>', '*' matches any namespace or kind.")
+	flag.IntVar(&gcPercent, "gc-percent", 0,
+		"The GOGC value of the controller process, set at startup, zero keeps the runtime setting.")
+	flag.StringVar(&buildHeapLimit, "build-heap-limit", "",
+		"The heap size of the controller process, e.g. 1Gi, shared by all the reconciliations, past which the kustomize builds in progress are cancelled, empty disables the limit.")
+	flag.StringVar(&readinessRules, "readiness-rules-configmap", "",
+		"The ConfigMap, as '<namespace>/<name>' or a name in the runtime namespace, holding the readiness rules of the health checked custom resources.")
+	flag.StringVar(&auditLogPath, "audit-log-path", "",
+		"The file the audit log entries are appended to, empty disables the audit log.")
+	flag.IntVar(&maxPreconditionWatch, "max-precondition-watches", 10,
+		"The maximum number of kinds watched for changes to the objects referenced by preconditions.")
+	flag.StringVar(&userAgent, "user-agent", "kustomize-controller/"+version,
+		"The user agent of the Kubernetes API clients used for garbage collection, health checks and drift reports, the Kustomization namespaced name is appended for the impersonated clients. The kubectl apply and validation send the kubectl user agent.")
+	flag.IntVar(&maxScanDepth, "max-scan-depth", 50,
+		"The maximum depth of the directories scanned when generating a kustomization.yaml, zero disables the limit.")
+	flag.BoolVar(&enableArtifactURL, "enable-artifact-url", false,
+		"Allow the Kustomizations to download their artifact from the arbitrary HTTP address set in spec.artifactURL.")
 	flag.Bool("log-json", false, "Set logging to JSON format.")
 	flag.CommandLine.MarkDeprecated("log-json", "Please use --log-encoding=json instead.")
 	clientOptions.BindFlags(flag.CommandLine)
@@ -96,6 +161,39 @@ func main() {
 
 	metricsRecorder := metrics.NewRecorder()
 	crtlmetrics.Registry.MustRegister(metricsRecorder.Collectors()...)
+	pruneMetricsRecorder := kmetrics.NewPruneRecorder()
+	crtlmetrics.Registry.MustRegister(pruneMetricsRecorder.Collectors()...)
+
+	quotas, err := controllers.ParseObjectQuotas(objectQuotas)
+	if err != nil {
+		setupLog.Error(err, "unable to parse the object quotas")
+		os.Exit(1)
+	}
+
+	if gcPercent > 0 {
+		debug.SetGCPercent(gcPercent)
+	}
+
+	var buildHeapLimitBytes int64
+	if buildHeapLimit != "" {
+		q, err := resource.ParseQuantity(buildHeapLimit)
+		if err != nil {
+			setupLog.Error(err, "unable to parse the build heap limit")
+			os.Exit(1)
+		}
+		buildHeapLimitBytes = q.Value()
+	}
+
+	var auditLogger *audit.Logger
+	if auditLogPath != "" {
+		auditLog, err := os.OpenFile(auditLogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			setupLog.Error(err, "unable to open the audit log")
+			os.Exit(1)
+		}
+		defer auditLog.Close()
+		auditLogger = audit.NewLogger(auditLog)
+	}
 
 	watchNamespace := ""
 	if !watchAllNamespaces {
@@ -103,6 +201,7 @@ func main() {
 	}
 
 	restConfig := client.GetConfigOrDie(clientOptions)
+	restConfig.UserAgent = userAgent
 	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
@@ -126,16 +225,38 @@ func main() {
 		EventRecorder:         mgr.GetEventRecorderFor("kustomize-controller"),
 		ExternalEventRecorder: eventRecorder,
 		MetricsRecorder:       metricsRecorder,
+		PruneMetricsRecorder:  pruneMetricsRecorder,
+		AuditLogger:           auditLogger,
 		StatusPoller:          polling.NewStatusPoller(mgr.GetClient(), mgr.GetRESTMapper()),
 	}).SetupWithManager(mgr, controllers.KustomizationReconcilerOptions{
-		MaxConcurrentReconciles:   concurrent,
-		DependencyRequeueInterval: requeueDependency,
+		MaxConcurrentReconciles:    concurrent,
+		DependencyRequeueInterval:  requeueDependency,
+		DefaultServiceAccount:      defaultServiceAccount,
+		RequiredLabels:             requiredLabels,
+		RequiredAnnotations:        requiredAnnotations,
+		DefaultReconcileBudget:     reconcileBudget,
+		DefaultInterval:            defaultInterval,
+		DefaultTimeout:             defaultTimeout,
+		MinInterval:                minInterval,
+		ArtifactDownloadTimeout:    downloadTimeout,
+		ArtifactDownloadRetries:    downloadRetries,
+		ClusterUnreachableInterval: unreachableInterval,
+		ObjectQuotas:               quotas,
+		BuildHeapLimit:             buildHeapLimitBytes,
+		ReadinessRulesConfigMap:    readinessRules,
+		RuntimeNamespace:           os.Getenv("RUNTIME_NAMESPACE"),
+		MaxPreconditionWatches:     maxPreconditionWatch,
+		UserAgent:                  userAgent,
+		MaxScanDepth:               maxScanDepth,
+		EnableArtifactURL:          enableArtifactURL,
 	}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", kustomizev1.KustomizationKind)
 		os.Exit(1)
 	}
 	// +kubebuilder:scaffold:builder
 
+	setupLog.Info("kustomize build options",
+		"flags", strings.Join(controllers.BuildFlags(controllers.DefaultBuildOptions()), " "))
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")